@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// changelogText displays changelog entries like 'rpm -q --changelog'
+type changelogText []yum.ChangeLogEntry
+
+func (p changelogText) writeText(w io.Writer) error {
+	for i, entry := range p {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		_, err := fmt.Fprintf(w, "* %s %s\n%s\n", entry.Date.Format("Mon Jan 02 2006"), entry.Author, entry.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangeLog returns the changelog of the latest package name (and version,
+// release) available from the repositories, newest entries first.
+// If since is not empty, only the entries more recent than since (a date
+// like 2015-01-31, or a [epoch:]version[-release] of the package) are returned.
+func (ctx *Context) ChangeLog(name, version, release, since string) ([]yum.ChangeLogEntry, error) {
+	pkg, err := ctx.yum.FindLatestProvider(name, version, release)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: no such package name=%q version=%q release=%q (%v)", name, version, release, err)
+	}
+
+	entries, err := ctx.yum.ChangeLog(pkg)
+	if err == yum.ErrNoMetadata {
+		return nil, fmt.Errorf("lbpkr: repository [%s] publishes no changelogs", pkg.Repository().Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if since == "" {
+		return entries, nil
+	}
+	rpm, date, err := parseChangeLogSince(pkg.Name(), since)
+	if err != nil {
+		return nil, err
+	}
+	return changelogSince(entries, pkg.Name(), rpm, date), nil
+}
+
+// addChangeLogs attaches to each update the changelog entries of the
+// available package more recent than the installed one.
+func (ctx *Context) addChangeLogs(updates []PackageUpdate) {
+	for i := range updates {
+		u := &updates[i]
+		entries, err := ctx.yum.ChangeLog(u.New)
+		if err != nil {
+			ctx.msg.Debugf("no changelog for %s: %v\n", u.New.ID(), err)
+			continue
+		}
+
+		// the newest entry of the installed package, if it is still available
+		var date time.Time
+		if old, err := ctx.yum.FindLatestProvider(u.Old.Name(), u.Old.Version(), u.Old.Release()); err == nil {
+			if prev, err := ctx.yum.ChangeLog(old); err == nil && len(prev) > 0 {
+				date = prev[0].Date
+			}
+		}
+		old := yum.NewProvides(u.New.Name(), u.Old.Version(), u.Old.Release(), u.Old.Epoch(), "EQ", nil)
+		u.ChangeLog = changelogSince(entries, u.New.Name(), old, date)
+	}
+}
+
+// parseChangeLogSince parses since as a date or as a [epoch:]version[-release] of the package name
+func parseChangeLogSince(name, since string) (yum.RPM, time.Time, error) {
+	if date, err := time.Parse("2006-01-02", since); err == nil {
+		return nil, date, nil
+	}
+
+	epoch, vers, rel := "", since, ""
+	if i := strings.Index(vers, ":"); i >= 0 {
+		epoch, vers = vers[:i], vers[i+1:]
+	}
+	if i := strings.LastIndex(vers, "-"); i >= 0 {
+		vers, rel = vers[:i], vers[i+1:]
+	}
+	if vers == "" {
+		return nil, time.Time{}, fmt.Errorf("lbpkr: invalid -since value %q (expected a date (YYYY-MM-DD) or a version)", since)
+	}
+	return yum.NewProvides(name, vers, rel, epoch, "EQ", nil), time.Time{}, nil
+}
+
+// changelogSince returns the (newest first) entries more recent than the
+// package rpm (if not nil) and than date (if not zero).
+func changelogSince(entries []yum.ChangeLogEntry, name string, rpm yum.RPM, date time.Time) []yum.ChangeLogEntry {
+	out := make([]yum.ChangeLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !date.IsZero() && !entry.Date.After(date) {
+			break
+		}
+		if rpm != nil {
+			if v := entry.RPM(name); v != nil && !yum.RPMLessThan(rpm, v) {
+				break
+			}
+		}
+		out = append(out, entry)
+	}
+	return out
+}
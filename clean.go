@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonuts/config"
+)
+
+// cachePolicy describes how long downloaded RPMs are kept in the tmp directory
+type cachePolicy struct {
+	keep    bool          // keep RPMs after they have been installed
+	maxSize int64         // maximum size of the RPM cache (0: no limit)
+	maxAge  time.Duration // maximum age of cached RPMs (0: no limit)
+}
+
+// cachePolicy reads the RPM cache retention policy from the [main] section of yum.conf
+func (ctx *Context) cachePolicy() (cachePolicy, error) {
+	policy := cachePolicy{keep: true}
+	cfg, err := config.ReadDefault(ctx.yumconf)
+	if err != nil {
+		return policy, err
+	}
+
+	const section = "main"
+	if cfg.HasOption(section, "keepcache") {
+		policy.keep, err = cfg.Bool(section, "keepcache")
+		if err != nil {
+			return policy, fmt.Errorf("lbpkr: invalid keepcache value: %v", err)
+		}
+	}
+	if cfg.HasOption(section, "cachemaxsize") {
+		v, err := cfg.String(section, "cachemaxsize")
+		if err != nil {
+			return policy, err
+		}
+		policy.maxSize, err = parseSize(v)
+		if err != nil {
+			return policy, err
+		}
+	}
+	if cfg.HasOption(section, "cachemaxage") {
+		v, err := cfg.String(section, "cachemaxage")
+		if err != nil {
+			return policy, err
+		}
+		policy.maxAge, err = parseAge(v)
+		if err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+// applyCachePolicy cleans up the caches after a successful transaction.
+// installed is the list of RPMs which have just been installed.
+func (ctx *Context) applyCachePolicy(installed []Package) error {
+	policy, err := ctx.cachePolicy()
+	if err != nil {
+		return err
+	}
+
+	if !policy.keep {
+		for _, pkg := range installed {
+			fname := filepath.Join(ctx.tmpdir, pkg.RPMFileName())
+			err = os.Remove(fname)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	removed, freed, err := pruneRPMs(ctx.tmpdir, policy.maxSize, policy.maxAge, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		ctx.msg.Infof("removed %d cached RPMs (%s)\n", len(removed), humanSize(freed))
+	}
+
+	_, err = ctx.cleanOrphanedMetadata()
+	return err
+}
+
+// CleanPackages removes all the RPMs downloaded into the tmp directory
+func (ctx *Context) CleanPackages() error {
+	rpms, err := listRPMs(ctx.tmpdir)
+	if err != nil {
+		return err
+	}
+
+	freed := int64(0)
+	for _, fi := range rpms {
+		err = os.Remove(filepath.Join(ctx.tmpdir, fi.Name()))
+		if err != nil {
+			return err
+		}
+		freed += fi.Size()
+	}
+	ctx.msg.Infof("removed %d cached RPMs (%s)\n", len(rpms), humanSize(freed))
+	return nil
+}
+
+// CleanMetadata removes the cached metadata of all repositories.
+// The metadata will be downloaded again at the next invocation.
+func (ctx *Context) CleanMetadata() error {
+	cachedir := ctx.yum.CacheDir()
+	dirs, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	freed := int64(0)
+	for _, fi := range dirs {
+		if !fi.IsDir() {
+			continue
+		}
+		dir := filepath.Join(cachedir, fi.Name())
+		freed += dirSize(dir)
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+	}
+	ctx.msg.Infof("removed metadata of %d repositories (%s)\n", len(dirs), humanSize(freed))
+	return nil
+}
+
+// ExpireCache marks the cached metadata of all repositories as expired,
+// so they are checked against the remote repositories at the next invocation.
+func (ctx *Context) ExpireCache() error {
+	cachedir := ctx.yum.CacheDir()
+	for _, name := range ctx.yum.Repositories() {
+		fname := filepath.Join(cachedir, name, "repomd.xml")
+		err := os.Remove(fname)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		ctx.msg.Debugf("expired metadata of repository %q\n", name)
+	}
+	ctx.msg.Infof("expired metadata of %d repositories\n", len(ctx.yum.Repositories()))
+	return nil
+}
+
+// cleanOrphanedMetadata removes the cached metadata of repositories which
+// are not configured anymore.
+func (ctx *Context) cleanOrphanedMetadata() ([]string, error) {
+	cachedir := ctx.yum.CacheDir()
+	dirs, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	repos := make(map[string]struct{})
+	for _, name := range ctx.yum.Repositories() {
+		repos[name] = struct{}{}
+	}
+
+	var removed []string
+	for _, fi := range dirs {
+		if _, ok := repos[fi.Name()]; ok || !fi.IsDir() {
+			continue
+		}
+		ctx.msg.Debugf("removing metadata of old repository %q\n", fi.Name())
+		err = os.RemoveAll(filepath.Join(cachedir, fi.Name()))
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, fi.Name())
+	}
+	return removed, nil
+}
+
+// listRPMs returns the RPM files under dir, oldest first
+func listRPMs(dir string) ([]os.FileInfo, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	rpms := make([]os.FileInfo, 0, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".rpm") {
+			continue
+		}
+		rpms = append(rpms, fi)
+	}
+	sort.Sort(filesByModTime(rpms))
+	return rpms, nil
+}
+
+// pruneRPMs removes the RPM files under dir older than maxAge, and then the
+// oldest ones until the total size is below maxSize.
+// a zero maxSize or maxAge disables the corresponding limit.
+func pruneRPMs(dir string, maxSize int64, maxAge time.Duration, now time.Time) ([]string, int64, error) {
+	if maxSize <= 0 && maxAge <= 0 {
+		return nil, 0, nil
+	}
+
+	rpms, err := listRPMs(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := int64(0)
+	for _, fi := range rpms {
+		total += fi.Size()
+	}
+
+	var removed []string
+	freed := int64(0)
+	for _, fi := range rpms {
+		tooOld := maxAge > 0 && now.Sub(fi.ModTime()) > maxAge
+		tooBig := maxSize > 0 && total > maxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return removed, freed, err
+		}
+		removed = append(removed, fi.Name())
+		freed += fi.Size()
+		total -= fi.Size()
+	}
+	return removed, freed, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	size := int64(0)
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// parseSize parses a size in bytes, with an optional K, M, G or T suffix (e.g. "2G")
+func parseSize(v string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(v))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+	unit := int64(1)
+	if n := len(str); n > 0 {
+		if i := strings.IndexByte("KMGT", str[n-1]); i >= 0 {
+			unit = 1 << (10 * uint(i+1))
+			str = str[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("lbpkr: invalid size %q", v)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseAge parses a duration, either as a number of days (e.g. "30" or "30d")
+// or as a time.Duration (e.g. "12h")
+func parseAge(v string) (time.Duration, error) {
+	str := strings.TrimSpace(v)
+	days := strings.TrimSuffix(str, "d")
+	if n, err := strconv.ParseFloat(days, 64); err == nil && n >= 0 {
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	age, err := time.ParseDuration(str)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("lbpkr: invalid age %q", v)
+	}
+	return age, nil
+}
+
+type filesByModTime []os.FileInfo
+
+func (p filesByModTime) Len() int           { return len(p) }
+func (p filesByModTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p filesByModTime) Less(i, j int) bool { return p[i].ModTime().Before(p[j].ModTime()) }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_changelog() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_changelog,
+		UsageLine: "changelog [options] <name> [<version> [<release>]]",
+		Short:     "display the changelog of a RPM",
+		Long: `
+changelog displays the changelog of the latest RPM matching name (and version,
+release), from the other (or other_db) metadata of its yum repository.
+
+With -since, only the entries more recent than a date (YYYY-MM-DD) or than
+a version[-release] of the RPM are displayed.
+
+ex:
+ $ lbpkr changelog LHCB_v37r3_x86_64_slc6_gcc48_opt
+ $ lbpkr changelog -since=2015-01-01 LHCB_v37r3_x86_64_slc6_gcc48_opt
+ $ lbpkr changelog -since=1.0.0-1 LHCB_v37r3_x86_64_slc6_gcc48_opt
+`,
+		Flag: *flag.NewFlagSet("lbpkr-changelog", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("since", "", "only display the entries more recent than a date (YYYY-MM-DD) or a version[-release]")
+	return cmd
+}
+
+func lbpkr_run_cmd_changelog(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	since := cmd.Flag.Lookup("since").Value.Get().(string)
+
+	name := ""
+	vers := ""
+	release := ""
+	switch len(args) {
+	case 1:
+		name = args[0]
+	case 2:
+		name = args[0]
+		vers = args[1]
+	case 3:
+		name = args[0]
+		vers = args[1]
+		release = args[2]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2|3. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	entries, err := ctx.ChangeLog(name, vers, release, since)
+	if err != nil {
+		return err
+	}
+	return ctx.render(changelogText(entries))
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -10,17 +10,35 @@ import (
 func lbpkr_make_cmd_check() *commander.Command {
 	cmd := &commander.Command{
 		Run:       lbpkr_run_cmd_check,
-		UsageLine: "check [options]",
+		UsageLine: "check [options] [<name-pattern> [<name-pattern> [...]]]",
 		Short:     "check for RPM updates from the yum repository",
 		Long: `
 check checks for RPM updates from the yum repository.
 
+If name patterns (regexps) are given, only the installed RPMs matching one of
+them (and their dependencies) are checked.
+
+If -security, -severity or -advisory are given, only the installed RPMs
+affected by the matching advisories (from the updateinfo metadata of the
+repositories) are checked.
+
+With -v, the changelog entries of each update since the installed RPM are
+also displayed (if the repositories publish changelogs).
+
 ex:
  $ lbpkr check
+ $ lbpkr check '^LHCB_v37r3_'
+ $ lbpkr check -project GAUDI
+ $ lbpkr check -security -severity Critical
+ $ lbpkr check -advisory LHCBSA-2015:0001
 `,
 		Flag: *flag.NewFlagSet("lbpkr-check", flag.ExitOnError),
 	}
 	add_default_options(cmd)
+	cmd.Flag.String("project", "", "only check the installed RPMs of a project (all platforms)")
+	cmd.Flag.Bool("security", false, "only check the RPMs affected by security advisories")
+	cmd.Flag.String("severity", "", "only check the RPMs affected by advisories of this severity")
+	cmd.Flag.String("advisory", "", "only check the RPMs affected by these advisories (comma-separated)")
 	return cmd
 }
 
@@ -29,19 +47,29 @@ func lbpkr_run_cmd_check(cmd *commander.Command, args []string) error {
 
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	project := cmd.Flag.Lookup("project").Value.Get().(string)
+	security := cmd.Flag.Lookup("security").Value.Get().(bool)
+	severity := cmd.Flag.Lookup("severity").Value.Get().(string)
+	advisories := splitAdvisories(cmd.Flag.Lookup("advisory").Value.Get().(string))
 
-	switch len(args) {
-	case 0:
-		// no-op
-	default:
-		return fmt.Errorf("lbpkr: invalid number of arguments. expected none. got=%d (%v)",
-			len(args),
-			args,
-		)
+	patterns := args
+	if project != "" {
+		patterns = append(patterns, projectPattern(project))
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg,
+		Debug(debug),
+		OutputFormat(Format(format)),
+		LockTimeout(lockTimeout),
+		FilterAdvisories(security, severity, advisories),
+	)
 	if err != nil {
 		return err
 	}
@@ -49,6 +77,26 @@ func lbpkr_run_cmd_check(cmd *commander.Command, args []string) error {
 
 	ctx.msg.Infof("checking for RPMs upgrades+updates\n")
 	checkOnly := true
-	err = ctx.Update(checkOnly)
+	updates, err := ctx.Update(checkOnly, patterns...)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		ctx.addChangeLogs(updates)
+	}
+
+	err = ctx.render(packageUpdates(updates))
+	if err != nil {
+		return err
+	}
+
+	update, upgrade := packageUpdates(updates).count()
+	if upgrade > 0 {
+		ctx.msg.Infof("packages to upgrade: %d\n", upgrade)
+	}
+	if update > 0 {
+		ctx.msg.Infof("packages to update:  %d\n", update)
+	}
 	return err
 }
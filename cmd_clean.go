@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_clean() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_clean,
+		UsageLine: "clean [options] packages|metadata|expire-cache|all",
+		Short:     "remove cached data",
+		Long: `
+clean removes cached data from the siteroot.
+
+ - packages:     remove the RPMs downloaded into $MYSITEROOT/tmp
+ - metadata:     remove the cached metadata of all repositories
+ - expire-cache: mark the cached metadata as expired, so it is checked again
+ - all:          all of the above
+
+The retention of downloaded RPMs is also controlled by the [main] section of
+$MYSITEROOT/etc/yum.conf and applied after each successful installation:
+ - keepcache=0|1        keep RPMs after they have been installed
+ - cachemaxsize=2G      maximum total size of the downloaded RPMs
+ - cachemaxage=30d      maximum age of the downloaded RPMs
+
+ex:
+ $ lbpkr clean packages
+ $ lbpkr clean all
+`,
+		Flag: *flag.NewFlagSet("lbpkr-clean", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_clean(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+
+	if len(args) == 0 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
+	}
+
+	for _, target := range args {
+		switch target {
+		case "packages", "metadata", "expire-cache", "all":
+		default:
+			cmd.Usage()
+			return fmt.Errorf("lbpkr: invalid clean target %q", target)
+		}
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg,
+		Debug(debug),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	for _, target := range args {
+		switch target {
+		case "packages":
+			err = ctx.CleanPackages()
+		case "metadata":
+			err = ctx.CleanMetadata()
+		case "expire-cache":
+			err = ctx.ExpireCache()
+		case "all":
+			err = ctx.CleanPackages()
+			if err == nil {
+				err = ctx.CleanMetadata()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
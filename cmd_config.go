@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_config() *commander.Command {
+	cmd := &commander.Command{
+		UsageLine: "config [options]",
+		Short:     "manage lbpkr.conf settings",
+		Subcommands: []*commander.Command{
+			lbpkr_make_cmd_config_get(),
+			lbpkr_make_cmd_config_ls(),
+			lbpkr_make_cmd_config_set(),
+		},
+		Flag: *flag.NewFlagSet("lbpkr-config", flag.ExitOnError),
+	}
+	return cmd
+}
+
+// EOF
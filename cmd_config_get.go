@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_config_get() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_config_get,
+		UsageLine: "get [options] <name>",
+		Short:     "display the value of a setting",
+		Long: `
+get displays the value of a setting, as seen by lbpkr.
+
+ex:
+ $ lbpkr config get repourl
+ $ lbpkr config get -siteroot=/opt/lhcb ndls
+`,
+		Flag: *flag.NewFlagSet("lbpkr-config-get", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_config_get(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	settings, _, err := settingsOf(cmd)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	if _, err = findSettingKey(name); err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", settings.Get(name))
+	return nil
+}
+
+// settingsOf returns the settings of the siteroot of a command, and that siteroot
+func settingsOf(cmd *commander.Command) (*Settings, string, error) {
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return nil, "", err
+	}
+	settings, err := loadSettings(cfg.Siteroot())
+	return settings, cfg.Siteroot(), err
+}
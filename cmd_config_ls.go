@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_config_ls() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_config_ls,
+		UsageLine: "ls [options]",
+		Short:     "list the settings and where they come from",
+		Long: `
+ls lists the settings of lbpkr, with their values and where they come from.
+
+Settings are read from the [lbpkr] section of lbpkr.conf files:
+ - /etc/lbpkr.conf                              (system)
+ - $MYSITEROOT/etc/lbpkr.conf                   (siteroot)
+ - ${XDG_CONFIG_HOME:-~/.config}/lbpkr/lbpkr.conf (user)
+User settings override siteroot settings, which override system settings.
+Environment variables override the files, and command line flags override
+everything.
+
+ex:
+ $ lbpkr config ls
+ $ lbpkr config ls -format=json
+`,
+		Flag: *flag.NewFlagSet("lbpkr-config-ls", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_config_ls(cmd *commander.Command, args []string) error {
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	settings, _, err := settingsOf(cmd)
+	if err != nil {
+		return err
+	}
+	return render(Format(format), settings.List())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_config_set() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_config_set,
+		UsageLine: "set [options] <name> [<value>]",
+		Short:     "write a setting into a lbpkr.conf file",
+		Long: `
+set writes a setting into the lbpkr.conf file of the given level
+(user, siteroot or system). Without a value, the setting is removed from
+the file.
+
+The relocation rules (relocate) can only be set at the siteroot level, and
+not once packages are installed in the siteroot (unless -force is given):
+installed files were relocated with the current rules.
+
+ex:
+ $ lbpkr config set ndls 4
+ $ lbpkr config set -level=siteroot repourl http://example.org/lhcbsoft
+ $ lbpkr config set -level=siteroot repourl
+ $ lbpkr config set -level=siteroot relocate /opt/myexp=.
+`,
+		Flag: *flag.NewFlagSet("lbpkr-config-set", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("level", UserLevel, "lbpkr.conf file to modify (user|siteroot|system)")
+	cmd.Flag.Bool("force", false, "change the relocation rules of a siteroot holding packages")
+	return cmd
+}
+
+func lbpkr_run_cmd_config_set(cmd *commander.Command, args []string) error {
+	var name, value string
+	switch len(args) {
+	case 1:
+		name = args[0]
+	case 2:
+		name, value = args[0], args[1]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	level := cmd.Flag.Lookup("level").Value.Get().(string)
+	force := cmd.Flag.Lookup("force").Value.Get().(bool)
+	settings, siteroot, err := settingsOf(cmd)
+	if err != nil {
+		return err
+	}
+	if name == "relocate" && level == SiterootLevel && !force {
+		err = checkRelocateChange(siteroot)
+		if err != nil {
+			return fmt.Errorf("%v (use -force to change them anyway)", err)
+		}
+	}
+	return settings.Set(level, name, value)
+}
@@ -2,32 +2,47 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"strconv"
+	"os"
+	"time"
 
-	graph "github.com/awalterschulze/gographviz"
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
-	"github.com/lhcb-org/lbpkr/yum"
 )
 
 func lbpkr_make_cmd_dep_graph() *commander.Command {
 	cmd := &commander.Command{
 		Run:       lbpkr_run_cmd_dep_graph,
 		UsageLine: "dep-graph [options] [<name-pattern> [<version-pattern> [<release-pattern>]]]",
-		Short:     "dump the DOT graph of installed RPM packages",
+		Short:     "dump the dependency graph of RPM packages",
 		Long: `
 dep-graph dumps the DAG of the installed RPM package(s) satisfying [<name-pattern> [<version-pattern> [<release-pattern>]]].
 
+The graph is written in the DOT, GraphML, JSON or Mermaid format (-graph-format),
+by default inferred from the extension of the output file (-o). Use -o=- to
+write the graph on stdout.
+
+With -reverse, the graph holds the RPMs requiring the matching RPMs.
+With -available, the graph holds the latest available RPMs matching the
+patterns which are not installed yet.
+
+Dependency cycles are highlighted in red, requirements no RPM provides in orange.
+
 ex:
  $ lbpkr dep-graph -o graph.dot
  $ lbpkr dep-graph GAUDI
+ $ lbpkr dep-graph -maxdepth=-1 -cluster=repository -o graph.graphml GAUDI_v25r2
+ $ lbpkr dep-graph -reverse -o=- -graph-format=mermaid ROOT_5.34.18_x86_64_slc6_gcc48_opt
+ $ lbpkr dep-graph -available -o brunel.json BRUNEL_v46r0
 `,
 		Flag: *flag.NewFlagSet("lbpkr-dep-graph", flag.ExitOnError),
 	}
 	add_default_options(cmd)
-	cmd.Flag.String("o", "graph.dot", "generate a DOT file holding the dependency graph")
+	cmd.Flag.String("o", "graph.dot", "generate a file holding the dependency graph (-: stdout)")
+	cmd.Flag.String("graph-format", "", "format of the dependency graph (dot|graphml|json|mermaid, default: from the -o extension)")
 	cmd.Flag.Int("maxdepth", 1, "maximum depth level of dependency graph (-1: all)")
+	cmd.Flag.Bool("reverse", false, "graph the RPMs requiring the matching RPMs")
+	cmd.Flag.Bool("available", false, "graph the available RPMs which are not installed")
+	cmd.Flag.String("cluster", "", "group the RPMs by repository or platform (dot and mermaid formats)")
 	return cmd
 }
 
@@ -35,9 +50,18 @@ func lbpkr_run_cmd_dep_graph(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
-	dotfname := cmd.Flag.Lookup("o").Value.Get().(string)
-	dmax := cmd.Flag.Lookup("maxdepth").Value.Get().(int)
+	fname := cmd.Flag.Lookup("o").Value.Get().(string)
+	gformat := cmd.Flag.Lookup("graph-format").Value.Get().(string)
+	cluster := cmd.Flag.Lookup("cluster").Value.Get().(string)
+	opts := depGraphOptions{
+		maxdepth:  cmd.Flag.Lookup("maxdepth").Value.Get().(int),
+		reverse:   cmd.Flag.Lookup("reverse").Value.Get().(bool),
+		available: cmd.Flag.Lookup("available").Value.Get().(bool),
+	}
 
 	name := ""
 	vers := ""
@@ -63,83 +87,55 @@ func lbpkr_run_cmd_dep_graph(cmd *commander.Command, args []string) error {
 		)
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	gformat, err = graphFormat(gformat, fname)
+	if err != nil {
+		return err
+	}
+	_, err = graphClusterKey(cluster)
 	if err != nil {
 		return err
 	}
-	defer ctx.Close()
 
-	g := graph.NewGraph()
-	g.SetName("rpms")
-	g.SetDir(true)
-
-	decorate := func(pkg yum.RPM) map[string]string {
-		return map[string]string{
-			"name":    strconv.Quote(pkg.Name()),
-			"version": strconv.Quote(pkg.Version()),
-			"release": strconv.Quote(pkg.Release()),
-			"epoch":   strconv.Quote(pkg.Epoch()),
-		}
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
 	}
+	stdout := fname == "-"
+	ctx, err := New(cfg,
+		Debug(debug),
+		OutputFormat(Format(format)),
+		LockTimeout(lockTimeout),
+		LogToStderr(stdout),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
 
-	pkgs, err := ctx.ListInstalledPackages(name, vers, release)
+	g, err := ctx.DepGraph(name, vers, release, opts)
 	if err != nil {
 		return err
 	}
 
-	str_in_slice := func(str string, slice []string) bool {
-		for _, v := range slice {
-			if str == v {
-				return true
-			}
-		}
-		return false
+	if stdout {
+		return g.Write(os.Stdout, gformat, cluster)
 	}
 
-	var process func(pkg *yum.Package, lvl int) error
-
-	process = func(pkg *yum.Package, lvl int) error {
-		var err error
-		root := strconv.Quote(pkg.ID())
-		g.AddNode("rpms", root, decorate(pkg))
-		reqs := pkg.Requires()
-		for _, req := range reqs {
-			if str_in_slice(req.Name(), yum.IGNORED_PACKAGES) {
-				continue
-			}
-			dep, err := ctx.Client().FindLatestMatchingRequire(req)
-			if err != nil {
-				ctx.msg.Infof("no package providing name=%q version=%q release=%q\n",
-					req.Name(),
-					req.Version(),
-					req.Release(),
-				)
-				continue
-			}
-			g.AddNode("rpms", strconv.Quote(dep.ID()), decorate(dep))
-			g.AddEdge(root, strconv.Quote(dep.ID()), true, nil)
-			if lvl < dmax || dmax < 0 {
-				err = process(dep, lvl+1)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	err = ctx.render(packageList(g.roots))
+	if err != nil {
 		return err
 	}
 
-	for _, pkg := range pkgs {
-		err = process(pkg, 1)
-		if err != nil {
-			return err
-		}
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	err = ioutil.WriteFile(dotfname, []byte(g.String()), 0644)
+	err = g.Write(f, gformat, cluster)
 	if err != nil {
 		return err
 	}
-
-	return err
+	ctx.msg.Infof("dependency graph (%d packages) written to [%s]\n", len(g.Nodes), fname)
+	return f.Close()
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -31,6 +32,9 @@ func lbpkr_run_cmd_deps(cmd *commander.Command, args []string) error {
 
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
 	dmax := cmd.Flag.Lookup("maxdepth").Value.Get().(int)
 
 	name := ""
@@ -55,8 +59,11 @@ func lbpkr_run_cmd_deps(cmd *commander.Command, args []string) error {
 		)
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
 	if err != nil {
 		return err
 	}
@@ -67,10 +74,12 @@ func lbpkr_run_cmd_deps(cmd *commander.Command, args []string) error {
 		return err
 	}
 
-	_, err = ctx.ListPackageDeps(pkg.Name(), pkg.Version(), pkg.Release(), dmax)
+	deps, err := ctx.ListPackageDeps(pkg.Name(), pkg.Version(), pkg.Release(), dmax)
+	// display the deps we've got so far, even if some could not be resolved.
+	rerr := ctx.render(packageList(deps))
 	if err != nil {
 		return err
 	}
 
-	return err
+	return rerr
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_diff() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_diff,
+		UsageLine: "diff [options] [<siteroot-A>] <siteroot-B>",
+		Short:     "compare the RPMs installed in two siteroots",
+		Long: `
+diff compares the RPMs installed in two siteroots, and lists the RPMs added,
+removed, or with a different version or release in <siteroot-B>.
+If only <siteroot-B> is given, it is compared with the siteroot of -siteroot
+(or $MYSITEROOT).
+
+With -snapshot, the RPMs recorded in a snapshot file (the output of
+'lbpkr installed', in the text or JSON format) are compared with the ones
+installed in <siteroot-B> (or in the siteroot of -siteroot).
+
+The siteroots are only read: read-only siteroots (e.g. on CVMFS) can be
+compared.
+
+ex:
+ $ lbpkr diff /cvmfs/lhcb.cern.ch/lib /opt/LHCbSoft
+ $ lbpkr diff -siteroot=/opt/LHCbSoft /opt/LHCbSoft-staging
+ $ lbpkr installed -format=json > snapshot.json
+ $ lbpkr diff -snapshot=snapshot.json
+`,
+		Flag: *flag.NewFlagSet("lbpkr-diff", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("snapshot", "", "compare the RPMs of a snapshot file with the installed ones")
+	return cmd
+}
+
+func lbpkr_run_cmd_diff(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	snapshot := cmd.Flag.Lookup("snapshot").Value.Get().(string)
+
+	var siteroots []string
+	switch {
+	case snapshot != "" && len(args) <= 1:
+		siteroots = append(siteroots, args...)
+	case snapshot == "" && len(args) == 1:
+		siteroots = []string{siteroot, args[0]}
+	case snapshot == "" && len(args) == 2:
+		siteroots = args
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2 (or n=0|1 with -snapshot). got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+	if len(siteroots) == 0 {
+		siteroots = []string{siteroot}
+	}
+
+	if err := checkFormat(format); err != nil {
+		return fmt.Errorf("lbpkr: invalid output format %q (%v)", format, err)
+	}
+
+	// the siteroots are only read: no Context is created for them, so
+	// read-only siteroots (e.g. on CVMFS) can be compared.
+	roots := make([]string, 0, len(siteroots))
+	for _, root := range siteroots {
+		cfg, err := NewConfigType(cfgtype, root)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, cfg.Siteroot())
+	}
+
+	var diff *SiterootDiff
+	if snapshot != "" {
+		diff, err = DiffSnapshot(snapshot, roots[0])
+	} else {
+		diff, err = DiffSiteroots(roots[0], roots[1])
+	}
+	if err != nil {
+		return err
+	}
+	return render(Format(format), diff)
+}
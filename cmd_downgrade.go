@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_downgrade() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_downgrade,
+		UsageLine: "downgrade [options] <rpmname> [<version>[-<release>]]",
+		Short:     "downgrade an installed RPM to an older build",
+		Long: `
+downgrade replaces an installed RPM with an older build from the yum repository.
+Dependencies are downgraded as needed.
+
+If no version is given, the newest build older than the installed one is selected.
+
+ex:
+ $ lbpkr downgrade LHCB_v37r3
+ $ lbpkr downgrade LHCB_v37r3 1.0.0-2
+`,
+		Flag: *flag.NewFlagSet("lbpkr-downgrade", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("force", false, "force RPM installation (by-passing any check)")
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("nodeps", false, "do not downgrade package dependencies")
+	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_downgrade(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	force := cmd.Flag.Lookup("force").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
+	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	name := ""
+	version := ""
+	release := ""
+	switch len(args) {
+	case 1:
+		name = args[0]
+	case 2:
+		name = args[0]
+		version = args[1]
+		if i := strings.LastIndex(version, "-"); i > 0 {
+			version, release = version[:i], version[i+1:]
+		}
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(
+		cfg,
+		Debug(debug),
+		EnableForce(force), EnableDryRun(dry), EnableNoDeps(nodeps),
+		EnableJustDb(justdb),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	err = ctx.Downgrade(name, version, release)
+	return err
+}
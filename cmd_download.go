@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_download() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_download,
+		UsageLine: "download [options] <rpm-1> [<rpm-2> [<rpm-3> [...]]]",
+		Short:     "download a (list of) RPM(s) from the yum repository",
+		Long: `
+download downloads a (list of) RPMs from the yum repository into a directory.
+
+ex:
+ $ lbpkr download GAUDI_v25r5
+ $ lbpkr download -destdir=./rpms -resolve GAUDI_v25r5
+ $ lbpkr download -destdir=./rpms -resolve -createrepo GAUDI_v25r5
+`,
+		Flag: *flag.NewFlagSet("lbpkr-download", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("destdir", ".", "directory where to download the RPMs")
+	cmd.Flag.Bool("resolve", false, "also download all the dependencies of the RPMs")
+	cmd.Flag.Bool("createrepo", false, "create a yum repository out of the destination directory")
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	return cmd
+}
+
+func lbpkr_run_cmd_download(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	destdir := cmd.Flag.Lookup("destdir").Value.Get().(string)
+	resolve := cmd.Flag.Lookup("resolve").Value.Get().(bool)
+	createrepo := cmd.Flag.Lookup("createrepo").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+
+	switch len(args) {
+	case 0:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
+	}
+
+	destdir, err = filepath.Abs(destdir)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), EnableDryRun(dry), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	ctx.msg.Infof("downloading RPMs %v\n", args)
+
+	err = ctx.DownloadRPMs(args, destdir, resolve)
+	if err != nil {
+		return err
+	}
+
+	if createrepo && !dry {
+		err = ctx.CreateRepo(destdir)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_env() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_env,
+		UsageLine: "env [options] <project> [<version>]",
+		Short:     "print the shell environment of an installed project",
+		Long: `
+env prints the environment (PATH, LD_LIBRARY_PATH, PYTHONPATH, CMTCONFIG, ...)
+needed to run an installed project and its externals, as a shell script or
+as an environment modulefile.
+
+Supported shells: sh, bash, zsh, csh, tcsh, fish, lmod and tcl.
+The default shell is taken from $SHELL, and the default platform from $CMTCONFIG.
+
+ex:
+ $ eval "$(lbpkr env GAUDI v25r5)"
+ $ lbpkr env -platform=x86_64_slc6_gcc48_opt -shell=csh GAUDI v25r5
+ $ lbpkr env -shell=lmod GAUDI v25r5 > modulefiles/GAUDI/v25r5.lua
+`,
+		Flag: *flag.NewFlagSet("lbpkr-env", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("platform", "", "platform of the project (default: $CMTCONFIG)")
+	cmd.Flag.String("shell", defaultShell(), "shell (or modulefile flavour) to generate the environment for")
+	return cmd
+}
+
+func lbpkr_run_cmd_env(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	platform := cmd.Flag.Lookup("platform").Value.Get().(string)
+	shell := cmd.Flag.Lookup("shell").Value.Get().(string)
+
+	project := ""
+	version := ""
+	switch len(args) {
+	case 1:
+		project = args[0]
+	case 2:
+		project = args[0]
+		version = args[1]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg,
+		Debug(debug),
+		OutputFormat(Format(format)),
+		LockTimeout(lockTimeout),
+		LogToStderr(true), // keep stdout for the script
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	env, err := ctx.ProjectEnv(project, version, platform)
+	if err != nil {
+		return err
+	}
+	return ctx.render(envScript{env, shell})
+}
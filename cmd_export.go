@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_export() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_export,
+		UsageLine: "export [options]",
+		Short:     "export the manifest of the installed RPMs",
+		Long: `
+export writes the manifest of the installed RPMs: their name, epoch, version,
+release and arch, the yum repository they come from and their checksum.
+
+The manifest can be imported into another siteroot with 'lbpkr import' to
+install exactly the same RPMs.
+
+ex:
+ $ lbpkr export -o manifest.json
+ $ lbpkr export -siteroot=/opt/LHCbSoft -o=- > manifest.json
+`,
+		Flag: *flag.NewFlagSet("lbpkr-export", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.String("o", "manifest.json", "file holding the manifest (-: stdout)")
+	return cmd
+}
+
+func lbpkr_run_cmd_export(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	fname := cmd.Flag.Lookup("o").Value.Get().(string)
+
+	if len(args) != 0 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=0. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	stdout := fname == "-"
+	ctx, err := New(cfg,
+		Debug(debug),
+		LockTimeout(lockTimeout),
+		LogToStderr(stdout),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	manifest, err := ctx.Export()
+	if err != nil {
+		return err
+	}
+
+	if stdout {
+		return WriteManifest(os.Stdout, manifest)
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = WriteManifest(f, manifest)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	ctx.msg.Infof("exported %d RPMs into %q\n", len(manifest.Packages), fname)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_files() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_files,
+		UsageLine: "files [options] <name> [<version> [<release>]]",
+		Short:     "list the files of an installed or available RPM",
+		Long: `
+files lists the files of an installed RPM (from the RPM database) or of an
+available RPM (from the filelists metadata of its yum repository).
+File names are relocated under the siteroot, like at installation.
+
+ex:
+ $ lbpkr files LHCB_v37r3_x86_64_slc6_gcc48_opt
+ $ lbpkr files -tree -size LHCB_v37r3_x86_64_slc6_gcc48_opt
+ $ lbpkr files -available BRUNEL_v46r0_x86_64_slc6_gcc48_opt
+`,
+		Flag: *flag.NewFlagSet("lbpkr-files", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("tree", false, "display the files as a tree")
+	cmd.Flag.Bool("size", false, "display the size of files (and directories, with -tree)")
+	cmd.Flag.Bool("available", false, "list the files of the available RPM, even if it is installed")
+	return cmd
+}
+
+func lbpkr_run_cmd_files(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	tree := cmd.Flag.Lookup("tree").Value.Get().(bool)
+	size := cmd.Flag.Lookup("size").Value.Get().(bool)
+	available := cmd.Flag.Lookup("available").Value.Get().(bool)
+
+	name := ""
+	vers := ""
+	release := ""
+	switch len(args) {
+	case 1:
+		name = args[0]
+	case 2:
+		name = args[0]
+		vers = args[1]
+	case 3:
+		name = args[0]
+		vers = args[1]
+		release = args[2]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2|3. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	files, err := ctx.Files(name, vers, release, available)
+	if err != nil {
+		return err
+	}
+	if tree {
+		return ctx.render(fileTree{files, size})
+	}
+	return ctx.render(fileList{files, size})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_group() *commander.Command {
+	cmd := &commander.Command{
+		UsageLine: "group [options]",
+		Short:     "manage package groups",
+		Subcommands: []*commander.Command{
+			lbpkr_make_cmd_group_info(),
+			lbpkr_make_cmd_group_install(),
+			lbpkr_make_cmd_group_list(),
+			lbpkr_make_cmd_group_remove(),
+		},
+		Flag: *flag.NewFlagSet("lbpkr-group", flag.ExitOnError),
+	}
+	return cmd
+}
+
+// EOF
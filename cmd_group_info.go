@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_group_info() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_group_info,
+		UsageLine: "info [options] <group> [<group> [...]]",
+		Short:     "display the packages of a package group",
+		Long: `
+info displays the description and the (mandatory, default, optional and
+conditional) packages of package groups.
+Installed packages are marked with '='.
+
+ex:
+ $ lbpkr group info lcg-externals
+`,
+		Flag: *flag.NewFlagSet("lbpkr-group-info", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_group_info(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+
+	if len(args) == 0 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	groups, err := ctx.Groups(args...)
+	if err != nil {
+		return err
+	}
+	return ctx.render(groupDetails(groups))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_group_list() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_group_list,
+		UsageLine: "list [options]",
+		Short:     "list the package groups of the yum repositories",
+		Long: `
+list lists the package groups published by the yum repositories, together
+with their installation status.
+
+ex:
+ $ lbpkr group list
+ $ lbpkr group list -hidden
+`,
+		Flag: *flag.NewFlagSet("lbpkr-group-list", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("hidden", false, "also list the groups which are not user-visible")
+	return cmd
+}
+
+func lbpkr_run_cmd_group_list(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	hidden := cmd.Flag.Lookup("hidden").Value.Get().(bool)
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	groups, err := ctx.Groups()
+	if err != nil {
+		return err
+	}
+
+	visible := make([]GroupInfo, 0, len(groups))
+	for _, grp := range groups {
+		if grp.UserVisible || hidden {
+			visible = append(visible, grp)
+		}
+	}
+	return ctx.render(groupInfos(visible))
+}
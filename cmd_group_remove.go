@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_group_remove() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_group_remove,
+		UsageLine: "remove [options] <group> [<group> [...]]",
+		Short:     "remove the installed packages of a package group",
+		Long: `
+remove removes the installed packages of package groups.
+
+ex:
+ $ lbpkr group remove lcg-externals
+`,
+		Flag: *flag.NewFlagSet("lbpkr-group-remove", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("force", false, "force removal of RPMs")
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_group_remove(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	force := cmd.Flag.Lookup("force").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	if len(args) == 0 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg,
+		Debug(debug),
+		EnableForce(force), EnableDryRun(dry),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	return ctx.RemoveGroups(args, force)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_import() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_import,
+		UsageLine: "import [options] <manifest>",
+		Short:     "install the RPMs of a manifest",
+		Long: `
+import installs exactly the RPMs listed in a manifest written by 'lbpkr export'.
+
+The pinned version and release of each RPM must still be available from the
+yum repositories, with the checksum recorded in the manifest: import fails
+otherwise. Dependencies are not resolved, as the manifest already holds them.
+
+ex:
+ $ lbpkr import manifest.json
+ $ lbpkr import -siteroot=/opt/LHCbSoft-copy -dry-run manifest.json
+`,
+		Flag: *flag.NewFlagSet("lbpkr-import", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_import(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	manifest, err := ReadManifest(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(
+		cfg,
+		Debug(debug),
+		EnableDryRun(dry), EnableJustDb(justdb),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	ctx.msg.Infof("importing %d RPMs from %q (exported from [%s])\n",
+		len(manifest.Packages), args[0], manifest.Siteroot,
+	)
+
+	return ctx.Import(manifest)
+}
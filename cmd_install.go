@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -18,6 +19,7 @@ install installs a (list of) RPMs from the yum repository.
 ex:
  $ lbpkr install GAUDI_v25r5
  $ lbpkr install GAUDI_v25r5 AIDA-3fe9f_3.2.1_i686_slc6_gcc48_opt
+ $ lbpkr install -downloadonly GAUDI_v25r5
 `,
 		Flag: *flag.NewFlagSet("lbpkr-install", flag.ExitOnError),
 	}
@@ -26,6 +28,9 @@ ex:
 	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
 	cmd.Flag.Bool("nodeps", false, "do not install package dependencies")
 	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("downloadonly", false, "only download the RPMs into the tmp directory, do not install them")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
 	return cmd
 }
 
@@ -33,11 +38,16 @@ func lbpkr_run_cmd_install(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	force := cmd.Flag.Lookup("force").Value.Get().(bool)
 	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
 	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
 	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	dlonly := cmd.Flag.Lookup("downloadonly").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
 
 	switch len(args) {
 	case 0:
@@ -45,12 +55,17 @@ func lbpkr_run_cmd_install(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
 	}
 
-	cfg := NewConfig(siteroot)
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
 	ctx, err := New(
 		cfg,
 		Debug(debug),
 		EnableForce(force), EnableDryRun(dry), EnableNoDeps(nodeps),
-		EnableJustDb(justdb),
+		EnableJustDb(justdb), EnableDownloadOnly(dlonly),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
 	)
 	if err != nil {
 		return err
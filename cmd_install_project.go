@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -28,6 +29,8 @@ ex:
 	cmd.Flag.String("platforms", "", "comma-separated list of (regex) platforms to install")
 	cmd.Flag.Bool("nodeps", false, "do not verify package dependencies")
 	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
 	return cmd
 }
 
@@ -35,12 +38,16 @@ func lbpkr_run_cmd_install_project(cmd *commander.Command, args []string) error
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	force := cmd.Flag.Lookup("force").Value.Get().(bool)
 	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
 	archs := cmd.Flag.Lookup("platforms").Value.Get().(string)
 	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
 	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
 
 	projname := ""
 	version := ""
@@ -65,12 +72,17 @@ func lbpkr_run_cmd_install_project(cmd *commander.Command, args []string) error
 		)
 	}
 
-	cfg := NewConfig(siteroot)
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
 	ctx, err := New(
 		cfg,
 		Debug(debug),
 		EnableForce(force), EnableDryRun(dry), EnableNoDeps(nodeps),
 		EnableJustDb(justdb),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
 	)
 	if err != nil {
 		return err
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -29,6 +30,9 @@ func lbpkr_run_cmd_installed(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 
 	name := ""
@@ -55,13 +59,21 @@ func lbpkr_run_cmd_installed(cmd *commander.Command, args []string) error {
 		)
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
 	if err != nil {
 		return err
 	}
 	defer ctx.Close()
 
-	_, err = ctx.ListInstalledPackages(name, vers, release)
+	pkgs, err := ctx.ListInstalledPackages(name, vers, release)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.render(packageList(pkgs))
 	return err
 }
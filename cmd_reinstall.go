@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_reinstall() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_reinstall,
+		UsageLine: "reinstall [options] <rpm-1> [<rpm-2> [<rpm-3> [...]]]",
+		Short:     "reinstall a (list of) installed RPM(s)",
+		Long: `
+reinstall re-downloads and reinstalls the exact same version of a (list of) installed RPMs.
+
+ex:
+ $ lbpkr reinstall GAUDI_v25r5_x86_64_slc6_gcc48_opt
+ $ lbpkr reinstall GAUDI_v25r5_x86_64_slc6_gcc48_opt-1.0.0-1
+`,
+		Flag: *flag.NewFlagSet("lbpkr-reinstall", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("force", false, "force RPM installation (by-passing any check)")
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("nodeps", false, "do not verify package dependencies")
+	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_reinstall(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	force := cmd.Flag.Lookup("force").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
+	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	switch len(args) {
+	case 0:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments (got=%d)", len(args))
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(
+		cfg,
+		Debug(debug),
+		EnableForce(force), EnableDryRun(dry), EnableNoDeps(nodeps),
+		EnableJustDb(justdb),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	ctx.msg.Infof("reinstalling RPMs %v\n", args)
+
+	err = ctx.ReinstallRPMs(args)
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_relocations() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_relocations,
+		UsageLine: "relocations [options] [<file> [<file> [...]]]",
+		Short:     "display the relocation rules of the siteroot",
+		Long: `
+relocations displays the rules relocating the files of RPMs under the siteroot,
+and how the given files (as packaged) are relocated.
+
+A file is relocated by the rule with the longest prefix matching its path.
+The rules are the ones of the configuration type (-type), unless the
+'relocate' option of the [lbpkr] section of $MYSITEROOT/etc/lbpkr.conf is set:
+
+ [lbpkr]
+ relocate = /opt/lcg/external=lcg/external, /opt/lcg=lcg/releases, /opt/LHCbSoft=.
+
+Relative paths are relative to the siteroot.
+
+ex:
+ $ lbpkr relocations
+ $ lbpkr relocations /opt/lcg/ROOT/5.34.18/x86_64-slc6-gcc48-opt/bin/root
+`,
+		Flag: *flag.NewFlagSet("lbpkr-relocations", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_relocations(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	return ctx.render(ctx.Relocations(args...))
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"regexp"
-	"strings"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -26,6 +26,8 @@ ex:
 	add_default_options(cmd)
 	cmd.Flag.Bool("force", false, "force removal of RPM")
 	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
 	return cmd
 }
 
@@ -33,9 +35,13 @@ func lbpkr_run_cmd_remove(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	force := cmd.Flag.Lookup("force").Value.Get().(bool)
 	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
 
 	rpms := make([][3]string, 0)
 	switch len(args) {
@@ -68,22 +74,20 @@ func lbpkr_run_cmd_remove(cmd *commander.Command, args []string) error {
 		}
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug), EnableForce(force), EnableDryRun(dry))
+	cfg, err := NewConfigType(cfgtype, siteroot)
 	if err != nil {
 		return err
 	}
-	defer ctx.Close()
-
-	str := []string{}
-	for _, s := range rpms {
-		str = append(str, fmt.Sprintf("%s %s %s", s[0], s[1], s[2]))
-	}
-	plural := ""
-	if len(rpms) > 1 {
-		plural = "s"
+	ctx, err := New(cfg,
+		Debug(debug),
+		EnableForce(force), EnableDryRun(dry),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
 	}
-	ctx.msg.Infof("removing RPM%s:\n%v\n", plural, strings.Join(str, "\n"))
+	defer ctx.Close()
 
 	err = ctx.RemoveRPM(rpms, force)
 	return err
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_repair() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_repair,
+		UsageLine: "repair [options] [<name-pattern>]",
+		Short:     "reinstall the installed RPMs failing verification",
+		Long: `
+repair verifies the installed RPMs and reinstalls the ones with missing or modified files.
+
+ex:
+ $ lbpkr repair
+ $ lbpkr repair GAUDI
+`,
+		Flag: *flag.NewFlagSet("lbpkr-repair", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.Bool("nodeps", false, "do not verify package dependencies")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_repair(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	pattern := ""
+	switch len(args) {
+	case 0:
+	case 1:
+		pattern = args[0]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=0|1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(
+		cfg,
+		Debug(debug),
+		EnableDryRun(dry), EnableNoDeps(nodeps),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	err = ctx.Repair(pattern)
+	return err
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -28,6 +29,9 @@ func lbpkr_run_cmd_repo_ls(cmd *commander.Command, args []string) error {
 
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
 
 	switch len(args) {
 	case 0:
@@ -39,13 +43,21 @@ func lbpkr_run_cmd_repo_ls(cmd *commander.Command, args []string) error {
 		)
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
 	if err != nil {
 		return err
 	}
 	defer ctx.Close()
 
-	err = ctx.ListRepositories()
+	repos, err := ctx.ListRepositories()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.render(repoInfos(repos))
 	return err
 }
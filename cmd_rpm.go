@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -14,9 +15,11 @@ func lbpkr_make_cmd_rpm() *commander.Command {
 		Short:     "pass through command-args to the RPM binary",
 		Long: `
 rpm passes through command-args to the RPM binary.
+In query mode (-q), file names are relocated under the siteroot.
 
 ex:
  $ lbpkr rpm -- --version
+ $ lbpkr rpm -- -ql LCG_ROOT_5.34.18_x86_64_slc6_gcc48_opt
 `,
 		Flag: *flag.NewFlagSet("lbpkr-rpm", flag.ExitOnError),
 	}
@@ -28,6 +31,8 @@ func lbpkr_run_cmd_rpm(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 
 	switch len(args) {
@@ -39,8 +44,11 @@ func lbpkr_run_cmd_rpm(cmd *commander.Command, args []string) error {
 		)
 	}
 
-	cfg := NewConfig(siteroot)
-	ctx, err := New(cfg, Debug(debug))
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout))
 	if err != nil {
 		return err
 	}
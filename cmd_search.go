@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_search() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_search,
+		UsageLine: "search [options] <term> [<term>...]",
+		Short:     "search RPM packages by name, summary, description and provides",
+		Long: `
+search lists the RPM packages (from all the yum repositories) matching all the
+given terms in their name, summary, description or provides.
+
+Terms are case-insensitive and match the beginning of words.
+The best matches (e.g. on the package name) are listed first.
+
+ex:
+ $ lbpkr search gaudi
+ $ lbpkr search root gcc48
+ $ lbpkr search -all brunel
+`,
+		Flag: *flag.NewFlagSet("lbpkr-search", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("all", false, "list all the matching versions of each package")
+	return cmd
+}
+
+func lbpkr_run_cmd_search(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	all := cmd.Flag.Lookup("all").Value.Get().(bool)
+
+	if len(args) == 0 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n>=1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	results, err := ctx.Search(args, all)
+	if err != nil {
+		return err
+	}
+	return ctx.render(searchResults(results))
+}
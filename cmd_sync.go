@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_sync() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_sync,
+		UsageLine: "sync [options] <site.yaml>",
+		Short:     "converge the siteroot towards a site specification",
+		Long: `
+sync converges the siteroot towards the site specification of a YAML file:
+ - the missing repositories are added,
+ - the missing projects and RPMs are installed,
+ - the RPMs with an installed version out of the declared range are updated
+   (or downgraded), a new version of a project replaces (and removes) its
+   installed versions out of range,
+ - with -prune (or 'prune: true'), the RPMs which are neither declared nor
+   required by a declared RPM are removed.
+
+Version ranges are comma-separated lists of constraints (<, <=, >, >=, ==, !=).
+Locks pin a RPM to a version (and release).
+
+ex:
+ $ cat site.yaml
+ repositories:
+   - name: lhcb
+     url: http://lhcbproject.web.cern.ch/lhcbproject/dist/rpm/lhcb
+ projects:
+   - name: GAUDI
+     versions: ">=v25r0, <v26r0"
+     platforms: [x86_64-slc6-gcc48-opt]
+ rpms:
+   - name: LBSCRIPTS
+     versions: ">=8.3.0"
+ locks:
+   - name: ROOT_5.34.18_x86_64_slc6_gcc48_opt
+     version: 1.0.0
+     release: "1"
+
+ $ lbpkr sync -dry-run site.yaml
+ $ lbpkr sync -prune site.yaml
+`,
+		Flag: *flag.NewFlagSet("lbpkr-sync", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("dry-run", false, "dry run. only display what would be done")
+	cmd.Flag.Bool("prune", false, "remove the RPMs which are not declared (nor required)")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
+	return cmd
+}
+
+func lbpkr_run_cmd_sync(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	prune := cmd.Flag.Lookup("prune").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	site, err := ReadSite(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(
+		cfg,
+		Debug(debug),
+		OutputFormat(Format(format)),
+		EnableDryRun(dry),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+	)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	plan, err := ctx.PlanSync(site, prune)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.render(plan)
+	if err != nil {
+		return err
+	}
+
+	if dry {
+		return nil
+	}
+	return ctx.ApplySync(plan)
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -10,20 +10,37 @@ import (
 func lbpkr_make_cmd_update() *commander.Command {
 	cmd := &commander.Command{
 		Run:       lbpkr_run_cmd_update,
-		UsageLine: "update [options]",
+		UsageLine: "update [options] [<name-pattern> [<name-pattern> [...]]]",
 		Short:     "update RPMs from the yum repository (bump the release number)",
 		Long: `
 update updates RPMs from the yum repository.
 
+If name patterns (regexps) are given, only the installed RPMs matching one of
+them (and their dependencies) are updated.
+
+If -security, -severity or -advisory are given, only the installed RPMs
+affected by the matching advisories (from the updateinfo metadata of the
+repositories) are updated.
+
 ex:
  $ lbpkr update
+ $ lbpkr update '^LHCB_v37r3_'
+ $ lbpkr update -project GAUDI
+ $ lbpkr update -security -severity Critical
+ $ lbpkr update -advisory LHCBSA-2015:0001
 `,
 		Flag: *flag.NewFlagSet("lbpkr-update", flag.ExitOnError),
 	}
 	add_default_options(cmd)
 	cmd.Flag.Bool("dry-run", false, "dry run. do not actually run the command")
+	cmd.Flag.String("project", "", "only update the installed RPMs of a project (all platforms)")
+	cmd.Flag.Bool("security", false, "only update the RPMs affected by security advisories")
+	cmd.Flag.String("severity", "", "only update the RPMs affected by advisories of this severity")
+	cmd.Flag.String("advisory", "", "only update the RPMs affected by these advisories (comma-separated)")
 	cmd.Flag.Bool("nodeps", false, "do not install package dependencies")
 	cmd.Flag.Bool("justdb", false, "update the database, but do not modify the filesystem")
+	cmd.Flag.Bool("y", false, "assume yes: do not ask for confirmation")
+	cmd.Flag.Bool("n", false, "assume no: answer no to all confirmations")
 	return cmd
 }
 
@@ -31,28 +48,37 @@ func lbpkr_run_cmd_update(cmd *commander.Command, args []string) error {
 	var err error
 
 	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
 	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
 	dry := cmd.Flag.Lookup("dry-run").Value.Get().(bool)
+	project := cmd.Flag.Lookup("project").Value.Get().(string)
+	security := cmd.Flag.Lookup("security").Value.Get().(bool)
+	severity := cmd.Flag.Lookup("severity").Value.Get().(string)
+	advisories := splitAdvisories(cmd.Flag.Lookup("advisory").Value.Get().(string))
 	nodeps := cmd.Flag.Lookup("nodeps").Value.Get().(bool)
 	justdb := cmd.Flag.Lookup("justdb").Value.Get().(bool)
+	yes := cmd.Flag.Lookup("y").Value.Get().(bool)
+	no := cmd.Flag.Lookup("n").Value.Get().(bool)
 
-	switch len(args) {
-	case 0:
-		// no-op
-	default:
-		return fmt.Errorf("lbpkr: invalid number of arguments. expected none. got=%d (%v)",
-			len(args),
-			args,
-		)
+	patterns := args
+	if project != "" {
+		patterns = append(patterns, projectPattern(project))
 	}
 
-	cfg := NewConfig(siteroot)
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
 	ctx, err := New(cfg,
 		Debug(debug),
 		EnableDryRun(dry),
 		EnableNoDeps(nodeps),
 		EnableJustDb(justdb),
 		EnablePackageMode(InstallMode|UpdateMode),
+		EnableLockMode(ExclusiveLock), LockTimeout(lockTimeout),
+		AssumeYes(yes), AssumeNo(no),
+		FilterAdvisories(security, severity, advisories),
 	)
 	if err != nil {
 		return err
@@ -61,6 +87,6 @@ func lbpkr_run_cmd_update(cmd *commander.Command, args []string) error {
 
 	ctx.msg.Infof("updating RPMs\n")
 	checkOnly := false
-	err = ctx.Update(checkOnly)
+	_, err = ctx.Update(checkOnly, patterns...)
 	return err
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_updateinfo() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_updateinfo,
+		UsageLine: "updateinfo [options] [<advisory> [<advisory> [...]]]",
+		Short:     "list the advisories published by the yum repositories",
+		Long: `
+updateinfo lists the advisories (security fixes, bug fixes, enhancements)
+published by the yum repositories and affecting the installed RPMs.
+
+ex:
+ $ lbpkr updateinfo
+ $ lbpkr updateinfo -security -severity Critical
+ $ lbpkr updateinfo -all
+ $ lbpkr updateinfo -info LHCBSA-2015:0001
+`,
+		Flag: *flag.NewFlagSet("lbpkr-updateinfo", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("all", false, "list all advisories, including those not affecting installed RPMs")
+	cmd.Flag.Bool("info", false, "display the full description of advisories")
+	cmd.Flag.Bool("security", false, "only list security advisories")
+	cmd.Flag.String("severity", "", "only list advisories of this severity")
+	return cmd
+}
+
+func lbpkr_run_cmd_updateinfo(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	all := cmd.Flag.Lookup("all").Value.Get().(bool)
+	info := cmd.Flag.Lookup("info").Value.Get().(bool)
+	security := cmd.Flag.Lookup("security").Value.Get().(bool)
+	severity := cmd.Flag.Lookup("severity").Value.Get().(string)
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	filter := advisoryFilter{
+		security: security,
+		severity: severity,
+		ids:      args,
+	}
+
+	// explicitly requested advisories are displayed even if not applicable
+	advs, err := ctx.UpdateInfo(filter, all || len(args) > 0)
+	if err != nil {
+		return err
+	}
+
+	if info {
+		return ctx.render(advisoryDetails(advs))
+	}
+	return ctx.render(advisoryInfos(advs))
+}
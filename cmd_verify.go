@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_verify() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_verify,
+		UsageLine: "verify [options] [<name-pattern>]",
+		Short:     "verify installed files against the RPM database",
+		Long: `
+verify checks the files of installed RPM packages against the RPM database.
+
+The size, mode, digest, link target and modification time of each file are
+checked and any difference is reported with rpm-style codes:
+ S: size, M: mode, 5: digest, L: link target, T: modification time
+
+ex:
+ $ lbpkr verify
+ $ lbpkr verify GAUDI
+ GAUDI_v25r1_x86_64_slc6_gcc48_opt-1.0.0-1:
+ S.5....T.    /opt/cern-sw/lhcb/GAUDI/GAUDI_v25r1/InstallArea/x86_64-slc6-gcc48-opt/scripts/gaudirun.py
+`,
+		Flag: *flag.NewFlagSet("lbpkr-verify", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	return cmd
+}
+
+func lbpkr_run_cmd_verify(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+
+	pattern := ""
+	switch len(args) {
+	case 0:
+	case 1:
+		pattern = args[0]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=0|1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	problems, err := ctx.Verify(pattern)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.render(verifyProblems(problems))
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("lbpkr: %d files failed verification", len(problems))
+	}
+	return err
+}
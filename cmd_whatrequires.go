@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_whatrequires() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_whatrequires,
+		UsageLine: "whatrequires [options] <name> [<version>]",
+		Short:     "list the RPMs requiring a given RPM",
+		Long: `
+whatrequires lists the RPMs (from all the yum repositories) with a requirement
+satisfied by the given RPM.
+
+ex:
+ $ lbpkr whatrequires ROOT_5.34.18_x86_64_slc6_gcc48_opt
+ $ lbpkr whatrequires -recursive GAUDI_v25r5_x86_64_slc6_gcc48_opt
+ $ lbpkr whatrequires -installed -recursive ROOT_5.34.18_x86_64_slc6_gcc48_opt
+`,
+		Flag: *flag.NewFlagSet("lbpkr-whatrequires", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Bool("recursive", false, "also list the RPMs requiring the requiring RPMs, and so on")
+	cmd.Flag.Bool("installed", false, "only consider installed RPMs")
+	return cmd
+}
+
+func lbpkr_run_cmd_whatrequires(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	recursive := cmd.Flag.Lookup("recursive").Value.Get().(bool)
+	installed := cmd.Flag.Lookup("installed").Value.Get().(bool)
+
+	name := ""
+	vers := ""
+	switch len(args) {
+	case 1:
+		name = args[0]
+	case 2:
+		name = args[0]
+		vers = args[1]
+	default:
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1|2. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	pkgs, err := ctx.WhatRequires(name, vers, recursive, installed)
+	// display the packages we've got so far, even if some could not be resolved.
+	rerr := ctx.render(packageList(pkgs))
+	if err != nil {
+		return err
+	}
+	return rerr
+}
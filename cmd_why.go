@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+func lbpkr_make_cmd_why() *commander.Command {
+	cmd := &commander.Command{
+		Run:       lbpkr_run_cmd_why,
+		UsageLine: "why [options] <package>",
+		Short:     "show why an installed RPM is installed",
+		Long: `
+why prints every chain of requirements leading from an explicitly installed
+RPM (an RPM no other installed RPM requires) down to the given installed RPM.
+Only the first 20 chains are displayed, unless -max says otherwise.
+
+ex:
+ $ lbpkr why GAUDI_v25r5_x86_64_slc6_gcc48_opt
+ $ lbpkr why -max=0 ROOT_5.34.18_x86_64_slc6_gcc48_opt
+`,
+		Flag: *flag.NewFlagSet("lbpkr-why", flag.ExitOnError),
+	}
+	add_default_options(cmd)
+	cmd.Flag.Int("max", 20, "maximum number of chains to display (0: no limit)")
+	return cmd
+}
+
+func lbpkr_run_cmd_why(cmd *commander.Command, args []string) error {
+	var err error
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	cfgtype := cmd.Flag.Lookup("type").Value.Get().(string)
+	lockTimeout := cmd.Flag.Lookup("lock-timeout").Value.Get().(time.Duration)
+	format := cmd.Flag.Lookup("format").Value.Get().(string)
+	debug := cmd.Flag.Lookup("v").Value.Get().(bool)
+	max := cmd.Flag.Lookup("max").Value.Get().(int)
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("lbpkr: invalid number of arguments. expected n=1. got=%d (%v)",
+			len(args),
+			args,
+		)
+	}
+
+	cfg, err := NewConfigType(cfgtype, siteroot)
+	if err != nil {
+		return err
+	}
+	ctx, err := New(cfg, Debug(debug), OutputFormat(Format(format)), LockTimeout(lockTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	chains, err := ctx.Why(args[0], max)
+	if err != nil {
+		return err
+	}
+	if len(chains) == 1 && len(chains[0]) == 1 {
+		ctx.msg.Infof("%s was explicitly installed\n", args[0])
+	}
+	return ctx.render(requireChains(chains))
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gonuts/config"
+)
+
+// ConfigFunc creates a Config for a siteroot.
+// An empty siteroot selects the default siteroot of the configuration.
+type ConfigFunc func(siteroot string) (Config, error)
+
+var cfgreg struct {
+	sync.RWMutex
+	types map[string]ConfigFunc
+}
+
+// RegisterConfig registers a Config implementation under a type name, so
+// it can be selected with -type (or $LBPKR_CONFIG_TYPE).
+// RegisterConfig panics if the type is already registered.
+func RegisterConfig(typ string, fct ConfigFunc) {
+	cfgreg.Lock()
+	defer cfgreg.Unlock()
+	if _, dup := cfgreg.types[typ]; dup {
+		panic(fmt.Errorf("lbpkr: config type [%s] already registered", typ))
+	}
+	cfgreg.types[typ] = fct
+}
+
+// ConfigTypes returns the names of the registered Config implementations
+func ConfigTypes() []string {
+	cfgreg.RLock()
+	defer cfgreg.RUnlock()
+	types := make([]string, 0, len(cfgreg.types))
+	for typ := range cfgreg.types {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewConfigType returns a configuration value of the given type.
+// An empty type selects $LBPKR_CONFIG_TYPE, or the LHCb configuration.
+func NewConfigType(typ, siteroot string) (Config, error) {
+	if typ == "" {
+		typ = os.Getenv("LBPKR_CONFIG_TYPE")
+	}
+	if typ == "" {
+		typ = "lhcb"
+	}
+	cfgreg.RLock()
+	fct, ok := cfgreg.types[typ]
+	cfgreg.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("lbpkr: unknown config type [%s] (expected one of: %s)",
+			typ, strings.Join(ConfigTypes(), "|"),
+		)
+	}
+	return fct(siteroot)
+}
+
+func init() {
+	cfgreg.types = make(map[string]ConfigFunc)
+	RegisterConfig("lhcb", func(siteroot string) (Config, error) {
+		cfg := NewConfig(siteroot).(*lhcbConfig)
+		settings, err := loadSettings(cfg.siteroot)
+		if err != nil {
+			return nil, err
+		}
+		if url := settings.Get("repourl"); url != "" {
+			cfg.repourl = url
+		}
+		for _, opt := range []struct {
+			name string
+			ptr  *bool
+		}{
+			{"debug", &cfg.debug},
+			{"rpmupdate", &cfg.rpmupdate},
+		} {
+			v, src, ok := settings.Lookup(opt.name)
+			if !ok {
+				continue
+			}
+			*opt.ptr, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("lbpkr: invalid %s value %q (%s): %v", opt.name, v, src, err)
+			}
+		}
+		return cfg, nil
+	})
+	RegisterConfig("generic", newGenericConfig)
+}
+
+// genericConfig is a configuration entirely described by a file:
+//
+//	[experiment]
+//	name = myexp
+//	siteroot = /opt/myexp
+//	repourl = http://example.org/rpm
+//	relocate = /opt/myexp=., /opt/ext=externals
+//
+//	[repo.myexp]
+//	url = http://example.org/rpm/myexp
+//
+// Relocation targets are relative to the siteroot.
+// The optional debug and rpmupdate booleans of [experiment] default to false.
+// The file is $LBPKR_EXPERIMENT_FILE, or ${siteroot}/etc/experiment.cfg.
+type genericConfig struct {
+	fname     string
+	name      string
+	siteroot  string
+	defroot   string
+	repourl   string
+	relocate  string // relocation rules, as written in the file
+	relocs    Relocations
+	repos     [][2]string // (name, url) pairs
+	debug     bool
+	rpmupdate bool // install/update switch
+}
+
+func newGenericConfig(siteroot string) (Config, error) {
+	if siteroot == "" {
+		siteroot = strings.Split(os.Getenv("MYSITEROOT"), string(os.PathListSeparator))[0]
+	}
+
+	fname := os.Getenv("LBPKR_EXPERIMENT_FILE")
+	if fname == "" {
+		if siteroot == "" {
+			return nil, fmt.Errorf("lbpkr: generic config needs $LBPKR_EXPERIMENT_FILE or a siteroot")
+		}
+		fname = filepath.Join(siteroot, "etc", "experiment.cfg")
+	}
+
+	cfg, err := readGenericConfig(fname)
+	if err != nil {
+		return nil, err
+	}
+	cfg.siteroot = siteroot
+	if cfg.siteroot == "" {
+		cfg.siteroot = cfg.defroot
+	}
+	if cfg.siteroot == "" {
+		return nil, fmt.Errorf("lbpkr: no siteroot given and no default siteroot in [%s]", fname)
+	}
+	cfg.relocs, err = parseRelocations(cfg.relocate, cfg.siteroot)
+	if err != nil {
+		return nil, fmt.Errorf("%v [%s]", err, fname)
+	}
+	return cfg, nil
+}
+
+// readGenericConfig reads the description of a generic configuration
+func readGenericConfig(fname string) (*genericConfig, error) {
+	f, err := config.ReadDefault(fname)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: could not read experiment file [%s]: %v", fname, err)
+	}
+
+	const section = "experiment"
+	cfg := &genericConfig{fname: fname}
+	get := func(option string) string {
+		if !f.HasOption(section, option) {
+			return ""
+		}
+		v, err := f.String(section, option)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(v)
+	}
+
+	cfg.name = get("name")
+	if cfg.name == "" {
+		return nil, fmt.Errorf("lbpkr: invalid experiment file [%s]: no [%s] name", fname, section)
+	}
+	cfg.defroot = get("siteroot")
+	cfg.repourl = get("repourl")
+
+	cfg.relocate = get("relocate")
+
+	for _, opt := range []struct {
+		name string
+		v    *bool
+	}{
+		{"debug", &cfg.debug},
+		{"rpmupdate", &cfg.rpmupdate},
+	} {
+		if !f.HasOption(section, opt.name) {
+			continue
+		}
+		*opt.v, err = f.Bool(section, opt.name)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid experiment file [%s]: invalid %s value: %v", fname, opt.name, err)
+		}
+	}
+
+	for _, s := range f.Sections() {
+		if !strings.HasPrefix(s, "repo.") {
+			continue
+		}
+		name := strings.TrimPrefix(s, "repo.")
+		url, err := f.String(s, "url")
+		if err != nil || name == "" {
+			return nil, fmt.Errorf("lbpkr: invalid experiment file [%s]: repository [%s] with no url", fname, name)
+		}
+		cfg.repos = append(cfg.repos, [2]string{name, strings.TrimSpace(url)})
+	}
+	sort.Sort(repoPairs(cfg.repos))
+	return cfg, nil
+}
+
+func (cfg *genericConfig) Siteroot() string {
+	return cfg.siteroot
+}
+
+func (cfg *genericConfig) RepoUrl() string {
+	return cfg.repourl
+}
+
+func (cfg *genericConfig) Debug() bool {
+	return cfg.debug
+}
+
+func (cfg *genericConfig) RpmUpdate() bool {
+	return cfg.rpmupdate
+}
+
+func (cfg *genericConfig) Name() string {
+	return cfg.name
+}
+
+func (cfg *genericConfig) DefaultSiteroot() string {
+	return cfg.defroot
+}
+
+// Relocations returns the default relocation rules of the siteroot
+func (cfg *genericConfig) Relocations() Relocations {
+	return cfg.relocs
+}
+
+func (cfg *genericConfig) InitYum(ctx *Context) error {
+	var err error
+	err = os.MkdirAll(ctx.yumreposd, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range cfg.repos {
+		f, err := os.Create(filepath.Join(ctx.yumreposd, repo[0]+".repo"))
+		if err != nil {
+			return err
+		}
+
+		err = ctx.writeYumRepo(f, map[string]string{
+			"name": repo[0],
+			"url":  repo[1],
+		})
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+type repoPairs [][2]string
+
+func (p repoPairs) Len() int           { return len(p) }
+func (p repoPairs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p repoPairs) Less(i, j int) bool { return p[i][0] < p[j][0] }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errAborted is returned when the user declines a confirmation prompt
+var errAborted = fmt.Errorf("lbpkr: operation aborted")
+
+// AssumeYes answers 'yes' to all confirmation prompts
+func AssumeYes(yes bool) func(*Context) {
+	return func(ctx *Context) {
+		ctx.prompt.yes = yes
+	}
+}
+
+// AssumeNo answers 'no' to all confirmation prompts
+func AssumeNo(no bool) func(*Context) {
+	return func(ctx *Context) {
+		ctx.prompt.no = no
+	}
+}
+
+// isTerminal returns whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the user whether to proceed with an operation.
+// confirm only prompts when stdin is a terminal: otherwise, the operation
+// proceeds unless 'no' was assumed.
+func (ctx *Context) confirm(question string) error {
+	switch {
+	case ctx.prompt.no:
+		ctx.msg.Infof("%s [y/N]: n (assumed)\n", question)
+		return errAborted
+	case ctx.prompt.yes:
+		ctx.msg.Debugf("%s [y/N]: y (assumed)\n", question)
+		return nil
+	case !ctx.prompt.tty:
+		return nil
+	}
+
+	if ctx.prompt.stdin == nil {
+		ctx.prompt.stdin = bufio.NewReader(os.Stdin)
+	}
+	for {
+		fmt.Fprintf(ctx.prompt.stdout, "%s [y/N]: ", question)
+		line, err := ctx.prompt.stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintf(ctx.prompt.stdout, "\n")
+			return errAborted
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return nil
+		case "", "n", "no":
+			return errAborted
+		}
+	}
+}
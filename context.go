@@ -69,6 +69,7 @@ type Context struct {
 	bindir    string
 	libdir    string
 	initfile  string
+	lockfile  string
 
 	extstatus map[string]External
 	reqext    []string
@@ -76,17 +77,43 @@ type Context struct {
 
 	installdb map[[3]string]struct{} // list of installed packages
 
+	settings *Settings   // lbpkr.conf settings of the siteroot
+	relocs   Relocations // relocation rules of the siteroot
+	relocsrc string      // where the relocation rules come from
+
 	// options for the rpm binary
 	options struct {
-		Force   bool // force rpm installation (by-passing any check)
-		DryRun  bool // dry run. do not actually run the command
-		NoDeps  bool // do not install package dependencies
-		JustDb  bool // update the database, but do not modify the filesystem
-		Package Mode // update mode of packages (Install|Update|Upgrade)
+		Force     bool // force rpm installation (by-passing any check)
+		DryRun    bool // dry run. do not actually run the command
+		NoDeps    bool // do not install package dependencies
+		NoResolve bool // do not resolve package dependencies, but let rpm check them
+		JustDb    bool // update the database, but do not modify the filesystem
+		DlOnly    bool // only download packages, do not install them
+		Package   Mode // update mode of packages (Install|Update|Upgrade)
 	}
 
 	ndls int // number of concurrent downloads
 
+	format Format // output format of query commands
+	stderr bool   // write log messages to stderr, keeping stdout for the output
+
+	advisories advisoryFilter // advisories selected for updates
+
+	// confirmation prompts
+	prompt struct {
+		yes    bool          // assume 'yes' to all prompts
+		no     bool          // assume 'no' to all prompts
+		tty    bool          // whether stdin is attached to a terminal
+		stdin  *bufio.Reader // where answers are read from
+		stdout io.Writer     // where questions are written to
+	}
+
+	lock struct {
+		mode    LockMode      // kind of lock to acquire on the siteroot
+		timeout time.Duration // how long to wait for the lock (<0: forever)
+		held    *siteLock
+	}
+
 	sigch   chan os.Signal
 	submux  sync.RWMutex // mutex on subcommands
 	subcmds []*exec.Cmd  // list of subcommands launched by lbpkr
@@ -136,6 +163,41 @@ func EnableJustDb(justdb bool) func(*Context) {
 	}
 }
 
+// EnableDownloadOnly only downloads packages into the tmp directory, without installing them
+func EnableDownloadOnly(dlonly bool) func(*Context) {
+	return func(ctx *Context) {
+		ctx.options.DlOnly = dlonly
+	}
+}
+
+// OutputFormat sets the output format of query commands (text|json)
+func OutputFormat(format Format) func(*Context) {
+	return func(ctx *Context) {
+		ctx.format = format
+	}
+}
+
+// LogToStderr writes log messages to stderr, keeping stdout for the output of the command
+func LogToStderr(stderr bool) func(*Context) {
+	return func(ctx *Context) {
+		ctx.stderr = stderr
+	}
+}
+
+// EnableLockMode sets the kind of lock to acquire on the siteroot (NoLock|SharedLock|ExclusiveLock)
+func EnableLockMode(mode LockMode) func(*Context) {
+	return func(ctx *Context) {
+		ctx.lock.mode = mode
+	}
+}
+
+// LockTimeout sets how long to wait for the siteroot lock. (<0: wait forever)
+func LockTimeout(timeout time.Duration) func(*Context) {
+	return func(ctx *Context) {
+		ctx.lock.timeout = timeout
+	}
+}
+
 func New(cfg Config, options ...func(*Context)) (*Context, error) {
 	var err error
 	siteroot := cfg.Siteroot()
@@ -156,17 +218,43 @@ func New(cfg Config, options ...func(*Context)) (*Context, error) {
 		bindir:    filepath.Join(siteroot, "usr", "bin"),
 		libdir:    filepath.Join(siteroot, "lib"),
 		initfile:  filepath.Join(siteroot, "etc", "repoinit"),
+		lockfile:  filepath.Join(siteroot, "var", "lock", "lbpkr.lock"),
 		installdb: nil,
 		ndls:      runtime.NumCPU(),
+		format:    TextFormat,
 		sigch:     make(chan os.Signal),
 		subcmds:   make([]*exec.Cmd, 0),
 		atexit:    make([]func(), 0),
 	}
+	ctx.lock.mode = SharedLock
+	ctx.lock.timeout = defaultLockTimeout
+	ctx.prompt.tty = isTerminal(os.Stdin)
+	ctx.prompt.stdout = os.Stdout
+	if cfg.Debug() {
+		ctx.msg.SetLevel(logger.DEBUG)
+	}
 
 	for _, opt := range options {
 		opt(&ctx)
 	}
 
+	if ctx.prompt.yes && ctx.prompt.no {
+		return nil, fmt.Errorf("lbpkr: -y and -n are mutually exclusive")
+	}
+
+	switch ctx.format {
+	case TextFormat:
+	case JSONFormat:
+		// keep stdout for the JSON document
+		ctx.stderr = true
+	default:
+		return nil, fmt.Errorf("lbpkr: invalid output format %q (expected text|json)", ctx.format)
+	}
+
+	if ctx.stderr {
+		ctx.msg = logger.NewLogger("lbpkr", ctx.msg.Level(), os.Stderr)
+	}
+
 	for _, dir := range []string{
 		siteroot,
 		ctx.dbpath,
@@ -184,6 +272,31 @@ func New(cfg Config, options ...func(*Context)) (*Context, error) {
 	}
 	os.Setenv("PATH", os.Getenv("PATH")+string(os.PathListSeparator)+ctx.bindir)
 
+	ctx.settings, err = loadSettings(siteroot)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.applySettings()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.relocs, ctx.relocsrc, err = ctx.loadRelocations()
+	if err != nil {
+		return nil, err
+	}
+
+	// prevent concurrent lbpkr processes from stepping on each other's toes
+	ctx.lock.held, err = lockSiteroot(ctx.lockfile, ctx.lock.mode, ctx.lock.timeout, ctx.msg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			ctx.lock.held.Release()
+		}
+	}()
+
 	ctx.initSignalHandler()
 
 	// make sure the db is initialized
@@ -240,7 +353,11 @@ func (ctx *Context) Close() error {
 		return nil
 	}
 
-	return ctx.yum.Close()
+	err := ctx.yum.Close()
+	if lerr := ctx.lock.held.Release(); lerr != nil && err == nil {
+		err = lerr
+	}
+	return err
 }
 
 func (ctx *Context) Client() *yum.Client {
@@ -475,6 +592,9 @@ exactarch=1
 obsoletes=1
 plugins=1
 gpgcheck=0
+keepcache=1
+#cachemaxsize=2G
+#cachemaxage=30d
 installroot=%s
 reposdir=/etc/yum.repos.d
 `
@@ -499,12 +619,33 @@ enabled=1
 	return err
 }
 
-// checkUpdates checks whether packages could be updated/upgraded in the repository
-func (ctx *Context) checkUpdates(checkOnly bool) error {
+// checkUpdates checks whether packages could be updated/upgraded in the repository.
+// checkUpdates returns the list of available updates when checkOnly is true.
+// If patterns is not empty, only the installed packages matching one of the
+// patterns (and their dependencies) are considered.
+func (ctx *Context) checkUpdates(checkOnly bool, patterns []string) ([]PackageUpdate, error) {
 	var err error
 	pkgs, err := ctx.listInstalledPackages()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(patterns) > 0 {
+		pkgs, err = ctx.filterInstalledPackages(pkgs, patterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !ctx.advisories.empty() {
+		pkgs, err = ctx.filterAdvisoryPackages(pkgs)
+		if err != nil {
+			return nil, err
+		}
+		if len(pkgs) <= 0 {
+			ctx.msg.Infof("no installed package affected by the selected advisories\n")
+			return nil, err
+		}
 	}
 
 	if !checkOnly && ctx.options.DryRun {
@@ -513,12 +654,6 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 
 	ctx.options.Force = false
 
-	type Manifest struct {
-		Old  yum.RPM
-		New  yum.RPM
-		Mode Mode
-	}
-
 	type cmpFunc func(i, j yum.RPM) bool
 	var (
 		upgradeFunc cmpFunc = yum.RPMLessThan
@@ -566,20 +701,20 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 	}
 
 	updateLbpkr := false
-	manifest := make([]Manifest, 0, len(pkglist))
+	manifest := make([]PackageUpdate, 0, len(pkglist))
 	toprocess := make([]Package, 0, len(pkglist))
 	for _, rpms := range pkglist {
 		sort.Sort(rpms)
 		pkg := rpms[len(rpms)-1]
 		update, err := ctx.yum.FindLatestProvider(pkg.Name(), "", "")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if update.Name() == "lbpkr" {
 			if checkOnly {
 				if yum.RPMLessThan(pkg, update) {
 					manifest = append(manifest,
-						Manifest{
+						PackageUpdate{
 							Old:  pkg,
 							New:  update,
 							Mode: UpgradeMode,
@@ -594,7 +729,7 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 				err = ctx.InstallPackage(Package{Package: update, Mode: UpgradeMode})
 				ctx.options.Force = false
 				if err != nil || len(pkglist) == 1 {
-					return err
+					return nil, err
 				}
 				updateLbpkr = true
 				continue
@@ -604,7 +739,7 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 			switch {
 			case updateFunc(pkg, update):
 				manifest = append(manifest,
-					Manifest{
+					PackageUpdate{
 						Old:  pkg,
 						New:  update,
 						Mode: UpdateMode,
@@ -612,7 +747,7 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 				)
 			case upgradeFunc(pkg, update):
 				manifest = append(manifest,
-					Manifest{
+					PackageUpdate{
 						Old:  pkg,
 						New:  update,
 						Mode: UpgradeMode,
@@ -627,45 +762,74 @@ func (ctx *Context) checkUpdates(checkOnly bool) error {
 
 	// if only the 'lbpkr' package was updated, then don't consider it as an error
 	if updateLbpkr && len(toprocess) <= 0 {
-		return err
+		return nil, err
 	}
 
 	if checkOnly {
-		upgrade := 0
-		update := 0
-		w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
-		for _, m := range manifest {
-			mode := "update"
-			if m.Mode == UpgradeMode {
-				mode = "upgrade"
-				upgrade++
-			} else {
-				update++
+		sort.Sort(packageUpdates(manifest))
+		return manifest, err
+	}
+
+	err = ctx.InstallPackages(toprocess)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.msg.Infof("packages %sd: %d\n", compare.Name, len(toprocess))
+	return nil, err
+}
+
+// filterInstalledPackages returns the installed packages whose name matches
+// one of patterns (regexps), together with their installed dependencies.
+func (ctx *Context) filterInstalledPackages(pkgs [][3]string, patterns []string) ([][3]string, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid pattern %q: %v", pattern, err)
+		}
+		res = append(res, re)
+	}
+
+	names := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		match := false
+		for _, re := range res {
+			if re.MatchString(pkg[0]) {
+				match = true
+				break
 			}
-			fmt.Fprintf(w, "%s\t%s-%s\t-> %s-%s\t(%v)\n",
-				m.Old.Name(),
-				m.Old.Version(), m.Old.Release(),
-				m.New.Version(), m.New.Release(),
-				mode,
-			)
 		}
-		w.Flush()
-		if upgrade > 0 {
-			ctx.msg.Infof("packages to upgrade: %d\n", upgrade)
+		if !match {
+			continue
 		}
-		if update > 0 {
-			ctx.msg.Infof("packages to update:  %d\n", update)
+		names[pkg[0]] = struct{}{}
+
+		p, err := ctx.yum.FindLatestProvider(pkg[0], pkg[1], pkg[2])
+		if err != nil {
+			ctx.msg.Debugf("no provider for installed package %s-%s-%s: %v\n", pkg[0], pkg[1], pkg[2], err)
+			continue
+		}
+		deps, err := ctx.yum.PackageDeps(p, -1)
+		if err != nil {
+			ctx.msg.Debugf("package-deps error for %s: %v\n", p.ID(), err)
+		}
+		for _, dep := range deps {
+			names[dep.Name()] = struct{}{}
 		}
-		return err
 	}
 
-	err = ctx.InstallPackages(toprocess)
-	if err != nil {
-		return err
+	if len(names) <= 0 {
+		return nil, fmt.Errorf("lbpkr: no installed package matching %v", patterns)
 	}
 
-	ctx.msg.Infof("packages %sd: %d\n", compare.Name, len(toprocess))
-	return err
+	selected := make([][3]string, 0, len(names))
+	for _, pkg := range pkgs {
+		if _, ok := names[pkg[0]]; ok {
+			selected = append(selected, pkg)
+		}
+	}
+	return selected, nil
 }
 
 // getNotInstalledPackageDeps returns the list of dependencies for package pkg which have not
@@ -872,10 +1036,8 @@ func (ctx *Context) InstallProject(name, version, release, platforms string) err
 	}
 
 	if platforms == "" {
-		// if no CMTCONFIG defined, we'll default to "ALL"
-		// CMTCONFIG is of the form        'x86_64-slc6-gcc48-opt'
-		// but the RPM-platform names are: 'x86_64_slc6_gcc48_opt'
-		platforms = strings.Replace(os.Getenv("CMTCONFIG"), "-", "_", -1)
+		// if no CMTCONFIG (nor platforms setting) defined, we'll default to "ALL"
+		platforms = ctx.defaultPlatforms()
 	}
 
 	archs := make([]string, 0, 2)
@@ -897,7 +1059,10 @@ func (ctx *Context) InstallProject(name, version, release, platforms string) err
 		if len(archs) > 0 {
 			arch = "(" + strings.Join(archs, "|") + ")"
 		}
-		pname := regexp.MustCompile(name + `_(?P<ProjectVersion>.*?)_(?P<ProjectArch>` + arch + ")")
+		pname, err := regexp.Compile(name + `_(?P<ProjectVersion>.*?)_(?P<ProjectArch>` + arch + ")")
+		if err != nil {
+			return fmt.Errorf("lbpkr: invalid platforms %q: %v", platforms, err)
+		}
 		for _, v := range plist {
 			sub := pname.FindStringSubmatch(v.Name())
 			if len(sub) <= 0 {
@@ -958,7 +1123,7 @@ func (ctx *Context) InstallPackages(packages []Package) error {
 
 	for _, pkg := range packages {
 		dodeps := " and dependencies"
-		if ctx.options.NoDeps {
+		if ctx.options.NoDeps || ctx.options.NoResolve {
 			dodeps = " (w/o dependencies)"
 		}
 		ctx.msg.Infof("installing %s%s\n", pkg.RPMName(), dodeps)
@@ -966,8 +1131,8 @@ func (ctx *Context) InstallPackages(packages []Package) error {
 			pkgs = append(pkgs, pkg)
 			continue
 		}
-		if ctx.options.NoDeps {
-			// user requested to NOT install dependencies
+		if ctx.options.NoDeps || ctx.options.NoResolve {
+			// user requested to NOT install dependencies, or they are part of packages
 			pkgs = append(pkgs, pkg)
 			continue
 		}
@@ -988,21 +1153,19 @@ func (ctx *Context) InstallPackages(packages []Package) error {
 		pkgs = append(pkgs, p)
 	}
 
-	npkgs := len(pkgs)
-	ctx.msg.Infof("found %d RPMs to install:\n", npkgs)
-	pkgnames := make([]string, 0, npkgs)
-	for _, p := range pkgs {
-		pkgnames = append(pkgnames, p.RPMName())
-	}
-	sort.Strings(pkgnames)
-	for i, rpm := range pkgnames {
-		ctx.msg.Infof("\t[%03d/%03d] %s\n", i+1, npkgs, rpm)
-	}
-
 	if len(pkgs) <= 0 {
+		ctx.msg.Infof("found 0 RPMs to install\n")
 		return fmt.Errorf("no RPM to install")
 	}
 
+	tx := ctx.newTransaction(pkgs)
+	tx.preview(ctx)
+
+	err = tx.checkDiskSpace(ctx)
+	if err != nil {
+		return err
+	}
+
 	if ctx.options.DryRun {
 		ctx.msg.Infof("no RPM installed (dry-run)\n")
 		return nil
@@ -1019,14 +1182,141 @@ func (ctx *Context) InstallPackages(packages []Package) error {
 		return nil
 	}
 
+	err = ctx.confirm("is this ok?")
+	if err != nil {
+		return err
+	}
+
 	// download the packages
 	err = ctx.downloadPackages(filtered, ctx.tmpdir)
 	if err != nil {
 		return err
 	}
 
+	if ctx.options.DlOnly {
+		ctx.msg.Infof("downloaded %d RPMs into %q (download-only)\n", len(filtered), ctx.tmpdir)
+		return nil
+	}
+
 	// install these packages
 	err = ctx.installPackages(filtered, ctx.tmpdir)
+	if err != nil {
+		return err
+	}
+
+	errc := ctx.applyCachePolicy(filtered)
+	if errc != nil {
+		ctx.msg.Warnf("could not clean up caches: %v\n", errc)
+	}
+	return err
+}
+
+// DownloadRPMs downloads a list of RPMs into dir.
+// If resolve is true, all the dependencies of these RPMs are downloaded as well,
+// whether they are already installed or not.
+func (ctx *Context) DownloadRPMs(rpms []string, dir string, resolve bool) error {
+	pkgs, size, err := ctx.downloadSet(rpms, dir, resolve)
+	if err != nil {
+		return err
+	}
+
+	ctx.msg.Infof("found %d RPMs to download:\n", len(pkgs))
+	for i, pkg := range pkgs {
+		ctx.msg.Infof("\t[%03d/%03d] %s (%s)\n", i+1, len(pkgs), pkg.RPMName(), humanSize(pkg.PackageSize()))
+	}
+	ctx.msg.Infof("total download size: %s\n", humanSize(size))
+
+	if ctx.options.DryRun {
+		ctx.msg.Infof("no RPM downloaded (dry-run)\n")
+		return nil
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = checkDiskSpace([]diskRequirement{{dir: dir, size: size}})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.downloadPackages(pkgs, dir)
+	if err != nil {
+		return err
+	}
+
+	ctx.msg.Infof("downloaded %d RPMs into %q\n", len(pkgs), dir)
+	return err
+}
+
+// downloadSet returns the packages DownloadRPMs would fetch into dir, sorted by
+// file name, together with the number of bytes still to download.
+// Files already present in dir with the expected size are not counted.
+func (ctx *Context) downloadSet(rpms []string, dir string, resolve bool) ([]Package, int64, error) {
+	pkgset := make(map[string]Package)
+	for _, rpm := range rpms {
+		args := splitRPM(rpm)
+		pkg, err := ctx.yum.FindLatestProvider(args[0], args[1], args[2])
+		if err != nil {
+			return nil, 0, err
+		}
+		if !resolve {
+			pkgset[pkg.RPMFileName()] = Package{pkg, InstallMode}
+			continue
+		}
+		deps, err := ctx.yum.RequiredPackages(pkg, -1)
+		if err != nil {
+			ctx.msg.Errorf("required-packages error: %v\n", err)
+			return nil, 0, err
+		}
+		for _, dep := range deps {
+			pkgset[dep.RPMFileName()] = Package{dep, InstallMode}
+		}
+	}
+
+	names := make([]string, 0, len(pkgset))
+	for name := range pkgset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pkgs := make([]Package, 0, len(names))
+	size := int64(0)
+	for _, name := range names {
+		pkg := pkgset[name]
+		pkgs = append(pkgs, pkg)
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err == nil && fi.Size() == pkg.PackageSize() {
+			continue
+		}
+		size += pkg.PackageSize()
+	}
+	return pkgs, size, nil
+}
+
+// CreateRepo generates the yum repository metadata for the RPMs under dir
+func (ctx *Context) CreateRepo(dir string) error {
+	var err error
+	createrepo := ""
+	for _, name := range []string{"createrepo_c", "createrepo"} {
+		createrepo, err = exec.LookPath(name)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("lbpkr: could not find createrepo_c nor createrepo in $PATH")
+	}
+
+	ctx.msg.Infof("creating yum repository in %q...\n", dir)
+	cmd := newCommand(createrepo, dir)
+	out, err := cmd.CombinedOutput()
+	ctx.msg.Debugf("%s\n", string(out))
+	if err != nil {
+		ctx.msg.Errorf("createrepo command failed: %v\n%v\n", err, string(out))
+		return err
+	}
 	return err
 }
 
@@ -1040,17 +1330,15 @@ func (ctx *Context) ListPackages(name, version, release string) ([]*yum.Package,
 	}
 
 	sort.Sort(yum.Packages(pkgs))
-	for _, pkg := range pkgs {
-		fmt.Printf("%s\n", pkg.ID())
-	}
-
-	ctx.msg.Infof("Total matching: %d\n", len(pkgs))
 	return pkgs, err
 }
 
-// Update checks whether updates are available and installs them if requested
-func (ctx *Context) Update(checkOnly bool) error {
-	return ctx.checkUpdates(checkOnly)
+// Update checks whether updates are available and installs them if requested.
+// Update returns the list of available updates when checkOnly is true.
+// If patterns is not empty, only the installed packages matching one of the
+// patterns (and their dependencies) are considered.
+func (ctx *Context) Update(checkOnly bool, patterns ...string) ([]PackageUpdate, error) {
+	return ctx.checkUpdates(checkOnly, patterns)
 }
 
 // ListInstalledPackages lists all installed packages satisfying the name/vers/release patterns
@@ -1060,29 +1348,32 @@ func (ctx *Context) ListInstalledPackages(name, version, release string) ([]*yum
 	if err != nil {
 		return nil, err
 	}
-	filter := func(pkg [3]string) bool { return true }
-	if release != "" && version != "" && name != "" {
-		re_name := regexp.MustCompile(name)
-		re_vers := regexp.MustCompile(version)
-		re_release := regexp.MustCompile(release)
-		filter = func(pkg [3]string) bool {
-			return re_name.MatchString(pkg[0]) &&
-				re_vers.MatchString(pkg[1]) &&
-				re_release.MatchString(pkg[2])
+	patterns := []struct {
+		kind    string
+		pattern string
+	}{
+		{"name", name},
+		{"version", version},
+		{"release", release},
+	}
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		if p.pattern == "" {
+			break
 		}
-	} else if version != "" && name != "" {
-		re_name := regexp.MustCompile(name)
-		re_vers := regexp.MustCompile(version)
-		filter = func(pkg [3]string) bool {
-			return re_name.MatchString(pkg[0]) &&
-				re_vers.MatchString(pkg[1])
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid %s pattern %q: %v", p.kind, p.pattern, err)
 		}
-
-	} else if name != "" {
-		re_name := regexp.MustCompile(name)
-		filter = func(pkg [3]string) bool {
-			return re_name.MatchString(pkg[0])
+		res = append(res, re)
+	}
+	filter := func(pkg [3]string) bool {
+		for i, re := range res {
+			if !re.MatchString(pkg[i]) {
+				return false
+			}
 		}
+		return true
 	}
 
 	pkgs := make([]*yum.Package, 0, len(installed))
@@ -1097,19 +1388,15 @@ func (ctx *Context) ListInstalledPackages(name, version, release string) ([]*yum
 		pkgs = append(pkgs, p)
 	}
 	if len(pkgs) <= 0 {
-		fmt.Printf("** No Match found **\n")
 		return nil, err
 	}
 
 	sort.Sort(yum.Packages(pkgs))
-	for _, pkg := range pkgs {
-		fmt.Printf("%s\n", pkg.ID())
-	}
 	return pkgs, err
 }
 
 // Provides lists all installed packages providing filename
-func (ctx *Context) Provides(filename string) ([]*yum.Package, error) {
+func (ctx *Context) Provides(filename string) ([]ProvidedFile, error) {
 	var err error
 	re_file, err := regexp.Compile(filename)
 	if err != nil {
@@ -1130,11 +1417,7 @@ func (ctx *Context) Provides(filename string) ([]*yum.Package, error) {
 		rpms = append(rpms, pkg)
 	}
 
-	type pair struct {
-		pkg  *yum.Package
-		file string
-	}
-	list := make([]pair, 0)
+	list := make([]ProvidedFile, 0)
 	for _, rpm := range rpms {
 		rpmfile := filepath.Join(ctx.tmpdir, rpm.RPMFileName())
 		if _, errstat := os.Stat(rpmfile); errstat != nil {
@@ -1152,11 +1435,11 @@ func (ctx *Context) Provides(filename string) ([]*yum.Package, error) {
 		}
 		scan := bufio.NewScanner(bytes.NewBuffer(out))
 		for scan.Scan() {
-			file := ctx.cfg.RelocateFile(scan.Text())
+			file := ctx.relocs.Relocate(scan.Text())
 			if re_file.MatchString(file) {
-				list = append(list, pair{
-					pkg:  rpm,
-					file: ctx.cfg.RelocateFile(file),
+				list = append(list, ProvidedFile{
+					Package: rpm,
+					File:    file,
 				})
 				break
 			}
@@ -1168,25 +1451,15 @@ func (ctx *Context) Provides(filename string) ([]*yum.Package, error) {
 		}
 	}
 	if len(list) <= 0 {
-		fmt.Printf("** No Match found **\n")
 		return nil, err
 	}
 
-	pkgs := make([]string, 0, len(list))
-	for _, p := range list {
-		pkgs = append(pkgs,
-			fmt.Sprintf("%s (%s)", p.pkg.ID(), p.file),
-		)
-	}
-
-	sort.Strings(pkgs)
-	for _, p := range pkgs {
-		fmt.Printf("%s\n", p)
-	}
-	return rpms, err
+	return list, err
 }
 
-// ListPackageDeps lists all the dependencies of the given RPM package
+// ListPackageDeps lists all the dependencies of the given RPM package.
+// If some dependencies could not be resolved, ListPackageDeps returns the
+// dependencies found so far together with an error.
 func (ctx *Context) ListPackageDeps(name, version, release string, depthmax int) ([]*yum.Package, error) {
 	var err error
 	pkg, err := ctx.yum.FindLatestProvider(name, version, release)
@@ -1196,18 +1469,11 @@ func (ctx *Context) ListPackageDeps(name, version, release string, depthmax int)
 
 	deps, depsErr := ctx.yum.PackageDeps(pkg, depthmax)
 	// do not handle the depsErr error just yet.
-	// printout the deps we've got so far.
-	if depsErr != nil {
-		err = depsErr
-	}
-
+	// return the deps we've got so far.
 	sort.Sort(yum.Packages(deps))
-	for _, pkg := range deps {
-		fmt.Printf("%s\n", pkg.ID())
-	}
 
 	if depsErr != nil {
-		return nil, fmt.Errorf("lbpkr: could not find dependencies for package=%q (%v)", pkg.ID(), depsErr)
+		return deps, fmt.Errorf("lbpkr: could not find dependencies for package=%q (%v)", pkg.ID(), depsErr)
 	}
 
 	return deps, err
@@ -1227,15 +1493,48 @@ func (ctx *Context) RemoveRPM(rpms [][3]string, force bool) error {
 		args = append(args, "--test")
 	}
 
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return err
+	}
+
+	nevras := make([]string, 0, len(rpms))
 	for _, id := range rpms {
 		pkg, err := ctx.yum.FindLatestProvider(id[0], id[1], id[2])
 		if err != nil {
 			return err
 		}
 
+		found := false
+		for _, inst := range installed {
+			if inst[0] != pkg.Name() ||
+				(id[1] != "" && inst[1] != id[1]) ||
+				(id[2] != "" && inst[2] != id[2]) {
+				continue
+			}
+			nevras = append(nevras, inst[0]+"-"+inst[1]+"-"+inst[2])
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("lbpkr: package %q is not installed", strings.Trim(strings.Join(id[:], "-"), "-"))
+		}
+
 		required = append(required, pkg.Requires()...)
 		args = append(args, pkg.Name())
 	}
+	sort.Strings(nevras)
+
+	ctx.msg.Infof("found %d RPMs to remove:\n", len(nevras))
+	for i, nevra := range nevras {
+		ctx.msg.Infof("\t[%03d/%03d] %s\n", i+1, len(nevras), nevra)
+	}
+
+	if !ctx.options.DryRun {
+		err = ctx.confirm("is this ok?")
+		if err != nil {
+			return err
+		}
+	}
 
 	_, err = ctx.rpm(true, args...)
 	if err != nil {
@@ -1288,40 +1587,54 @@ func (ctx *Context) RemoveRPM(rpms [][3]string, force bool) error {
 }
 
 // Rpm runs the rpm command.
+// In query mode, the file names printed by rpm are relocated under the siteroot.
 func (ctx *Context) Rpm(args ...string) error {
-	_, err := ctx.rpm(true, args...)
+	_, query := rpmModes(args)
+	if !query {
+		_, err := ctx.rpm(true, args...)
+		return err
+	}
+	out, err := ctx.rpm(false, args...)
+	_, werr := os.Stdout.Write([]byte(ctx.relocs.RelocateText(string(out))))
+	if err == nil {
+		err = werr
+	}
 	return err
 }
 
-// rpm wraps the invocation of the rpm command
-func (ctx *Context) rpm(display bool, args ...string) ([]byte, error) {
-	install_mode := false
-	query_mode := false
+// rpmModes returns whether rpm arguments install or query packages
+func rpmModes(args []string) (install, query bool) {
 	for _, arg := range args {
 		if len(arg) < 2 {
 			continue
 		}
 		if arg[:2] == "-i" || arg[:2] == "-U" {
-			install_mode = true
+			install = true
 			continue
 		}
 		if arg[:2] == "-q" {
-			query_mode = true
+			query = true
 		}
 	}
+	return install, query
+}
+
+// rpm wraps the invocation of the rpm command
+func (ctx *Context) rpm(display bool, args ...string) ([]byte, error) {
+	install_mode, query_mode := rpmModes(args)
 
-	// FIXME(sbinet)
 	// when in query-mode, rpm --dbpath ... will print the filenames without
 	// relocating them.
-	// we should fix that up as that may be utterly confusing.
 	// e.g. it would print:
 	//  /opt/lcg/blas/20110419-e1974/x86_64-slc6-gcc48-opt/lib/libBLAS.a
 	// instead of:
 	//  $MYSITEROOT/lcg/releases/blas/20110419-e1974/x86_64-slc6-gcc48-opt/lib/libBLAS.a
+	// ctx.Rpm relocates that output; other callers listing files should go
+	// through ctx.rpmFiles (or ctx.relocs.Relocate).
 
 	rpmargs := []string{"--dbpath", ctx.dbpath}
 	if !query_mode && install_mode {
-		rpmargs = append(rpmargs, ctx.cfg.RelocateArgs()...)
+		rpmargs = append(rpmargs, ctx.relocs.Args()...)
 	}
 	rpmargs = append(rpmargs, args...)
 
@@ -1334,29 +1647,17 @@ func (ctx *Context) rpm(display bool, args ...string) ([]byte, error) {
 	// 	Pdeathsig: syscall.SIGINT,
 	// }
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	defer stdout.Close()
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-	defer stderr.Close()
-
+	// cmd.Wait returns once all the output has been copied.
+	// stdout and stderr share the same writer, so it is not written concurrently.
 	var out bytes.Buffer
+	var w io.Writer = &out
 	if display {
-		tee := io.MultiWriter(os.Stdout, &out)
-
-		go io.Copy(tee, stdout)
-		go io.Copy(tee, stderr)
-	} else {
-		go io.Copy(&out, stdout)
-		go io.Copy(&out, stderr)
+		w = io.MultiWriter(os.Stdout, &out)
 	}
-	err = cmd.Start()
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	err := cmd.Start()
 	if err != nil {
 		return nil, err
 	}
@@ -1450,33 +1751,54 @@ func (ctx *Context) initInstalledPackages() {
 
 // listInstalledPackages checks whether a given RPM package is already installed
 func (ctx *Context) listInstalledPackages() ([][3]string, error) {
-	list := make([][3]string, 0)
-	args := []string{"-qa", "--queryformat", "%{NAME} %{VERSION} %{RELEASE}\n"}
-	out, err := ctx.rpm(false, args...)
+	out, err := ctx.rpm(false, rpmQueryInstalled...)
 	if err != nil {
 		return nil, err
 	}
+	return parseInstalledPackages(out)
+}
+
+// rpmQueryInstalled are the rpm arguments listing the installed packages
+var rpmQueryInstalled = []string{"-qa", "--queryformat", "%{NAME} %{VERSION} %{RELEASE}\n"}
 
+// parseInstalledPackages parses the output of rpm -qa with rpmQueryInstalled
+func parseInstalledPackages(out []byte) ([][3]string, error) {
+	list := make([][3]string, 0)
 	scan := bufio.NewScanner(bytes.NewBuffer(out))
 	for scan.Scan() {
 		line := scan.Text()
 		pkg := strings.Split(line, " ")
 		if len(pkg) != 3 {
-			err = fmt.Errorf("lbpkr: invalid line %q", line)
-			return nil, err
+			return nil, fmt.Errorf("lbpkr: invalid line %q", line)
 		}
 		for i, p := range pkg {
 			pkg[i] = strings.Trim(p, " \n\r\t")
 		}
 		list = append(list, [3]string{pkg[0], pkg[1], pkg[2]})
 	}
-	err = scan.Err()
+	err := scan.Err()
 	if err != nil {
 		return nil, err
 	}
 	return list, err
 }
 
+// queryInstalledPackages lists the packages installed in a siteroot, without
+// setting up a Context: the RPM database is only read, no directory, lock
+// file nor yum metadata is created (e.g. for read-only CVMFS siteroots).
+func queryInstalledPackages(siteroot string) ([][3]string, error) {
+	err := checkSiteroot(siteroot)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"--dbpath", filepath.Join(siteroot, "var", "lib", "rpm")}, rpmQueryInstalled...)
+	out, err := newCommand("rpm", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: could not list packages of siteroot [%s]: %v", siteroot, err)
+	}
+	return parseInstalledPackages(out)
+}
+
 // downloadPackages downloads a list of packages
 func (ctx *Context) downloadPackages(pkgs []Package, dir string) error {
 	var err error
@@ -1691,8 +2013,9 @@ func (ctx *Context) AddRepository(name, repo string) error {
 		return err
 	}
 
-	// make sure the new repo is correct
-	ctx, err = New(ctx.cfg, Debug(false))
+	// make sure the new repo is correct.
+	// we already hold the siteroot lock.
+	ctx, err = New(ctx.cfg, Debug(false), EnableLockMode(NoLock))
 	if err != nil {
 		return err
 	}
@@ -1727,24 +2050,25 @@ func (ctx *Context) RemoveRepository(name string) error {
 }
 
 // ListRepositories lists all repositories.
-func (ctx *Context) ListRepositories() error {
+func (ctx *Context) ListRepositories() ([]RepoInfo, error) {
 	var err error
 	reposdir, err := os.Open(ctx.yumreposd)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer reposdir.Close()
 
 	dirs, err := reposdir.Readdir(-1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	repos := make([]RepoInfo, 0, len(dirs))
 	for _, fi := range dirs {
 		fname := filepath.Join(ctx.yumreposd, fi.Name())
 		cfg, err := config.ReadDefault(fname)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		for _, section := range cfg.Sections() {
 			if section == config.DEFAULT_SECTION {
@@ -1752,24 +2076,24 @@ func (ctx *Context) ListRepositories() error {
 			}
 			name, err := cfg.String(section, "name")
 			if err != nil {
-				return err
+				return nil, err
 			}
 			baseurl, err := cfg.String(section, "baseurl")
 			if err != nil {
-				return err
+				return nil, err
 			}
-			isEnabled, err := cfg.Bool(section, "enabled")
+			enabled, err := cfg.Bool(section, "enabled")
 			if err != nil {
-				return err
-			}
-			enabled := "disabled"
-			if isEnabled {
-				enabled = "enabled"
+				return nil, err
 			}
-			fmt.Printf("%s: %q (%s)\n", name, baseurl, enabled)
+			repos = append(repos, RepoInfo{
+				Name:    name,
+				URL:     baseurl,
+				Enabled: enabled,
+			})
 		}
 	}
-	return err
+	return repos, err
 }
 
 // EOF
@@ -0,0 +1,608 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	graph "github.com/awalterschulze/gographviz"
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// GraphFormats lists the supported output formats of dependency graphs
+var GraphFormats = []string{"dot", "graphml", "json", "mermaid"}
+
+// DepNode is a package (or a missing requirement) of a dependency graph
+type DepNode struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	Release    string `json:"release,omitempty"`
+	Epoch      string `json:"epoch,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	Installed  bool   `json:"installed"`
+	Missing    bool   `json:"missing,omitempty"` // requirement no package provides
+	Cycle      bool   `json:"cycle,omitempty"`   // part of a dependency cycle
+}
+
+// DepEdge is a requirement of a package (From) satisfied by another one (To)
+type DepEdge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Missing bool   `json:"missing,omitempty"`
+	Cycle   bool   `json:"cycle,omitempty"`
+}
+
+// DepGraph is a dependency graph of RPM packages
+type DepGraph struct {
+	Roots []string   `json:"roots"` // IDs of the packages the graph was built from
+	Nodes []*DepNode `json:"nodes"`
+	Edges []*DepEdge `json:"edges"`
+
+	roots []*yum.Package
+	nodes map[string]*DepNode
+	edges map[[2]string]*DepEdge
+}
+
+func newDepGraph() *DepGraph {
+	return &DepGraph{
+		Roots: make([]string, 0),
+		Nodes: make([]*DepNode, 0),
+		Edges: make([]*DepEdge, 0),
+		nodes: make(map[string]*DepNode),
+		edges: make(map[[2]string]*DepEdge),
+	}
+}
+
+// addNode adds node to the graph, unless a node with the same ID already exists
+func (g *DepGraph) addNode(node *DepNode) *DepNode {
+	if n, ok := g.nodes[node.ID]; ok {
+		return n
+	}
+	g.nodes[node.ID] = node
+	g.Nodes = append(g.Nodes, node)
+	return node
+}
+
+// addPackage adds a package node to the graph
+func (g *DepGraph) addPackage(pkg *yum.Package, installed bool) *DepNode {
+	node := &DepNode{
+		ID:        pkg.ID(),
+		Name:      pkg.Name(),
+		Version:   pkg.Version(),
+		Release:   pkg.Release(),
+		Epoch:     pkg.Epoch(),
+		Platform:  rpmPlatform(pkg.Name()),
+		Installed: installed,
+	}
+	if repo := pkg.Repository(); repo != nil {
+		node.Repository = repo.Name
+	}
+	return g.addNode(node)
+}
+
+// addMissing adds a requirement of pkg no package provides
+func (g *DepGraph) addMissing(pkg *yum.Package, req *yum.Requires) {
+	id := req.Name()
+	if req.Version() != "" {
+		id += "-" + req.Version()
+		if req.Release() != "" {
+			id += "-" + req.Release()
+		}
+	}
+	g.addNode(&DepNode{
+		ID:      id,
+		Name:    req.Name(),
+		Version: req.Version(),
+		Release: req.Release(),
+		Missing: true,
+	})
+	edge := g.addEdge(pkg.ID(), id)
+	edge.Missing = true
+}
+
+// addEdge adds an edge from -> to, unless it already exists
+func (g *DepGraph) addEdge(from, to string) *DepEdge {
+	key := [2]string{from, to}
+	if e, ok := g.edges[key]; ok {
+		return e
+	}
+	e := &DepEdge{From: from, To: to}
+	g.edges[key] = e
+	g.Edges = append(g.Edges, e)
+	return e
+}
+
+// sort sorts the nodes and edges of the graph by ID
+func (g *DepGraph) sort() {
+	sort.Sort(depNodes(g.Nodes))
+	sort.Sort(depEdges(g.Edges))
+}
+
+// markCycles flags the nodes and edges of the graph which are part of a
+// dependency cycle (i.e. of a strongly connected component of more than
+// one node, or of a node requiring itself).
+func (g *DepGraph) markCycles() {
+	succ := make(map[string][]string, len(g.Nodes))
+	for _, e := range g.Edges {
+		succ[e.From] = append(succ[e.From], e.To)
+	}
+
+	// Tarjan's strongly connected components algorithm
+	index := 0
+	indices := make(map[string]int, len(g.Nodes))
+	lowlink := make(map[string]int, len(g.Nodes))
+	onstack := make(map[string]bool, len(g.Nodes))
+	stack := make([]string, 0)
+	component := make(map[string]int, len(g.Nodes))
+	ncomps := 0
+
+	var connect func(id string)
+	connect = func(id string) {
+		indices[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onstack[id] = true
+
+		for _, next := range succ[id] {
+			if _, ok := indices[next]; !ok {
+				connect(next)
+				if lowlink[next] < lowlink[id] {
+					lowlink[id] = lowlink[next]
+				}
+			} else if onstack[next] && indices[next] < lowlink[id] {
+				lowlink[id] = indices[next]
+			}
+		}
+
+		if lowlink[id] == indices[id] {
+			for {
+				n := len(stack) - 1
+				top := stack[n]
+				stack = stack[:n]
+				onstack[top] = false
+				component[top] = ncomps
+				if top == id {
+					break
+				}
+			}
+			ncomps++
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if _, ok := indices[node.ID]; !ok {
+			connect(node.ID)
+		}
+	}
+
+	size := make(map[int]int, ncomps)
+	for _, c := range component {
+		size[c]++
+	}
+	for _, e := range g.Edges {
+		c := component[e.From]
+		if c != component[e.To] || (size[c] == 1 && e.From != e.To) {
+			continue
+		}
+		e.Cycle = true
+		g.nodes[e.From].Cycle = true
+		g.nodes[e.To].Cycle = true
+	}
+}
+
+// reRPMPlatform matches the platform suffix of a package name (e.g. ROOT_5.34.18_x86_64_slc6_gcc48_opt)
+var reRPMPlatform = regexp.MustCompile(`_((?:x86_64|i686)_[^_]+_[^_]+_[^_]+)$`)
+
+// rpmPlatform returns the platform of a package, from its name ("noarch" if it has none)
+func rpmPlatform(name string) string {
+	sub := reRPMPlatform.FindStringSubmatch(name)
+	if sub == nil {
+		return "noarch"
+	}
+	return sub[1]
+}
+
+// depGraphOptions configures the packages included in a dependency graph
+type depGraphOptions struct {
+	maxdepth  int  // maximum depth of the graph (-1: all)
+	reverse   bool // graph the packages requiring the matching packages
+	available bool // graph the available (not installed) packages
+}
+
+// DepGraph returns the dependency graph of the installed packages matching
+// the name/version/release patterns (or of the available, not installed,
+// packages if opts.available is set).
+// In reverse mode, the graph holds the packages requiring the matching
+// packages instead of the packages they require (only installed ones, unless
+// opts.available is set). Edges always go from a package to its requirement.
+func (ctx *Context) DepGraph(name, version, release string, opts depGraphOptions) (*DepGraph, error) {
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	isInstalled := make(map[[3]string]bool, len(installed))
+	for _, pkg := range installed {
+		isInstalled[pkg] = true
+	}
+	installedPkg := func(pkg *yum.Package) bool {
+		return isInstalled[[3]string{pkg.Name(), pkg.Version(), pkg.Release()}]
+	}
+
+	var roots []*yum.Package
+	if opts.available {
+		pkgs, err := ctx.yum.ListPackages(name, version, release)
+		if err != nil {
+			return nil, err
+		}
+		// only graph the latest version of each package
+		sort.Sort(yum.Packages(pkgs))
+		for i, pkg := range pkgs {
+			if i+1 < len(pkgs) && pkgs[i+1].Name() == pkg.Name() {
+				continue
+			}
+			if !installedPkg(pkg) {
+				roots = append(roots, pkg)
+			}
+		}
+	} else {
+		roots, err = ctx.ListInstalledPackages(name, version, release)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ignored := make(map[string]bool, len(yum.IGNORED_PACKAGES))
+	for _, v := range yum.IGNORED_PACKAGES {
+		ignored[v] = true
+	}
+
+	type item struct {
+		pkg *yum.Package
+		lvl int
+	}
+	g := newDepGraph()
+	queue := make([]item, 0, len(roots))
+	queued := make(map[string]bool)
+	for _, pkg := range roots {
+		g.Roots = append(g.Roots, pkg.ID())
+		g.roots = append(g.roots, pkg)
+		g.addPackage(pkg, installedPkg(pkg))
+		queue = append(queue, item{pkg, 1})
+		queued[pkg.ID()] = true
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		expand := cur.lvl < opts.maxdepth || opts.maxdepth < 0
+
+		var next []*yum.Package
+		if opts.reverse {
+			pkgs, err := ctx.yum.FindRequiring(cur.pkg)
+			if err != nil {
+				return nil, err
+			}
+			for _, pkg := range pkgs {
+				if !opts.available && !installedPkg(pkg) {
+					continue
+				}
+				g.addPackage(pkg, installedPkg(pkg))
+				g.addEdge(pkg.ID(), cur.pkg.ID())
+				next = append(next, pkg)
+			}
+		} else {
+			for _, req := range cur.pkg.Requires() {
+				if ignored[req.Name()] {
+					continue
+				}
+				dep, err := ctx.yum.FindLatestMatchingRequire(req)
+				if err != nil {
+					ctx.msg.Infof("no package providing name=%q version=%q release=%q (required by %s)\n",
+						req.Name(),
+						req.Version(),
+						req.Release(),
+						cur.pkg.ID(),
+					)
+					g.addMissing(cur.pkg, req)
+					continue
+				}
+				g.addPackage(dep, installedPkg(dep))
+				g.addEdge(cur.pkg.ID(), dep.ID())
+				next = append(next, dep)
+			}
+		}
+
+		if !expand {
+			continue
+		}
+		for _, pkg := range next {
+			if queued[pkg.ID()] {
+				continue
+			}
+			queued[pkg.ID()] = true
+			queue = append(queue, item{pkg, cur.lvl + 1})
+		}
+	}
+
+	g.markCycles()
+	g.sort()
+	return g, nil
+}
+
+// graphFormat returns the output format of a graph written to fname:
+// format if not empty, or the format matching the extension of fname.
+func graphFormat(format, fname string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fname)) {
+		case ".graphml", ".xml":
+			format = "graphml"
+		case ".json":
+			format = "json"
+		case ".mmd", ".mermaid":
+			format = "mermaid"
+		default:
+			format = "dot"
+		}
+	}
+	for _, v := range GraphFormats {
+		if v == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("lbpkr: unknown graph format %q (expected one of %v)", format, GraphFormats)
+}
+
+// Write writes the graph in the given format (dot, graphml, json or mermaid).
+// If cluster is "repository" or "platform", nodes are grouped accordingly
+// (in the dot and mermaid formats).
+func (g *DepGraph) Write(w io.Writer, format, cluster string) error {
+	key, err := graphClusterKey(cluster)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "dot":
+		return g.writeDOT(w, key)
+	case "graphml":
+		return g.writeGraphML(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	case "mermaid":
+		return g.writeMermaid(w, key)
+	}
+	return fmt.Errorf("lbpkr: unknown graph format %q (expected one of %v)", format, GraphFormats)
+}
+
+// graphClusterKey returns the function grouping nodes by cluster
+// ("repository" or "platform"), or nil if cluster is empty.
+func graphClusterKey(cluster string) (func(n *DepNode) string, error) {
+	switch cluster {
+	case "":
+		return nil, nil
+	case "repository":
+		return func(n *DepNode) string { return n.Repository }, nil
+	case "platform":
+		return func(n *DepNode) string { return n.Platform }, nil
+	}
+	return nil, fmt.Errorf("lbpkr: unknown graph cluster %q (expected repository or platform)", cluster)
+}
+
+// clusters groups the nodes of the graph by cluster key.
+// Nodes with an empty key are not part of any cluster.
+func (g *DepGraph) clusters(key func(n *DepNode) string) ([]string, map[string][]*DepNode) {
+	names := make([]string, 0)
+	nodes := make(map[string][]*DepNode)
+	for _, n := range g.Nodes {
+		k := ""
+		if key != nil {
+			k = key(n)
+		}
+		if _, ok := nodes[k]; !ok {
+			names = append(names, k)
+		}
+		nodes[k] = append(nodes[k], n)
+	}
+	sort.Strings(names)
+	return names, nodes
+}
+
+func (g *DepGraph) writeDOT(w io.Writer, key func(n *DepNode) string) error {
+	gv := graph.NewGraph()
+	gv.SetName("rpms")
+	gv.SetDir(true)
+
+	names, clusters := g.clusters(key)
+	for i, name := range names {
+		parent := "rpms"
+		if name != "" {
+			parent = fmt.Sprintf("cluster_%d", i)
+			gv.AddSubGraph("rpms", parent, map[string]string{"label": strconv.Quote(name)})
+		}
+		for _, n := range clusters[name] {
+			attrs := map[string]string{
+				"name":    strconv.Quote(n.Name),
+				"version": strconv.Quote(n.Version),
+				"release": strconv.Quote(n.Release),
+				"epoch":   strconv.Quote(n.Epoch),
+			}
+			switch {
+			case n.Missing:
+				attrs["style"] = "dashed"
+				attrs["color"] = "orange"
+			case n.Cycle:
+				attrs["color"] = "red"
+			case !n.Installed:
+				attrs["style"] = "dotted"
+			}
+			gv.AddNode(parent, strconv.Quote(n.ID), attrs)
+		}
+	}
+
+	for _, e := range g.Edges {
+		var attrs map[string]string
+		switch {
+		case e.Missing:
+			attrs = map[string]string{"style": "dashed", "color": "orange"}
+		case e.Cycle:
+			attrs = map[string]string{"color": "red"}
+		}
+		gv.AddEdge(strconv.Quote(e.From), strconv.Quote(e.To), true, attrs)
+	}
+
+	_, err := io.WriteString(w, gv.String())
+	return err
+}
+
+func (g *DepGraph) writeGraphML(w io.Writer) error {
+	var err error
+	pr := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+	esc := func(s string) string {
+		buf := new(bytes.Buffer)
+		xml.EscapeText(buf, []byte(s))
+		return buf.String()
+	}
+
+	keys := []struct{ id, target, typ string }{
+		{"name", "node", "string"},
+		{"version", "node", "string"},
+		{"release", "node", "string"},
+		{"epoch", "node", "string"},
+		{"repository", "node", "string"},
+		{"platform", "node", "string"},
+		{"installed", "node", "boolean"},
+		{"missing", "all", "boolean"},
+		{"cycle", "all", "boolean"},
+	}
+
+	pr("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	pr("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	for _, k := range keys {
+		pr("  <key id=%q for=%q attr.name=%q attr.type=%q/>\n", k.id, k.target, k.id, k.typ)
+	}
+	pr("  <graph id=\"rpms\" edgedefault=\"directed\">\n")
+	for _, n := range g.Nodes {
+		pr("    <node id=\"%s\">\n", esc(n.ID))
+		for _, v := range [][2]string{
+			{"name", n.Name},
+			{"version", n.Version},
+			{"release", n.Release},
+			{"epoch", n.Epoch},
+			{"repository", n.Repository},
+			{"platform", n.Platform},
+			{"installed", strconv.FormatBool(n.Installed)},
+			{"missing", strconv.FormatBool(n.Missing)},
+			{"cycle", strconv.FormatBool(n.Cycle)},
+		} {
+			if v[1] == "" {
+				continue
+			}
+			pr("      <data key=%q>%s</data>\n", v[0], esc(v[1]))
+		}
+		pr("    </node>\n")
+	}
+	for _, e := range g.Edges {
+		pr("    <edge source=\"%s\" target=\"%s\">\n", esc(e.From), esc(e.To))
+		pr("      <data key=\"missing\">%v</data>\n", e.Missing)
+		pr("      <data key=\"cycle\">%v</data>\n", e.Cycle)
+		pr("    </edge>\n")
+	}
+	pr("  </graph>\n")
+	pr("</graphml>\n")
+	return err
+}
+
+func (g *DepGraph) writeMermaid(w io.Writer, key func(n *DepNode) string) error {
+	var err error
+	pr := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+	label := func(s string) string {
+		return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+	}
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	pr("graph LR\n")
+	names, clusters := g.clusters(key)
+	for i, name := range names {
+		indent := "  "
+		if name != "" {
+			pr("  subgraph c%d[%s]\n", i, label(name))
+			indent = "    "
+		}
+		for _, n := range clusters[name] {
+			pr("%s%s[%s]\n", indent, ids[n.ID], label(n.ID))
+		}
+		if name != "" {
+			pr("  end\n")
+		}
+	}
+
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Missing {
+			arrow = "-.->"
+		}
+		pr("  %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+
+	var missing, cycles []string
+	for _, n := range g.Nodes {
+		switch {
+		case n.Missing:
+			missing = append(missing, ids[n.ID])
+		case n.Cycle:
+			cycles = append(cycles, ids[n.ID])
+		}
+	}
+	if len(missing) > 0 {
+		pr("  classDef missing stroke:orange,stroke-dasharray:5 5\n")
+		pr("  class %s missing\n", strings.Join(missing, ","))
+	}
+	if len(cycles) > 0 {
+		pr("  classDef cycle stroke:red\n")
+		pr("  class %s cycle\n", strings.Join(cycles, ","))
+	}
+	return err
+}
+
+type depNodes []*DepNode
+
+func (p depNodes) Len() int           { return len(p) }
+func (p depNodes) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p depNodes) Less(i, j int) bool { return p[i].ID < p[j].ID }
+
+type depEdges []*DepEdge
+
+func (p depEdges) Len() int      { return len(p) }
+func (p depEdges) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p depEdges) Less(i, j int) bool {
+	if p[i].From != p[j].From {
+		return p[i].From < p[j].From
+	}
+	return p[i].To < p[j].To
+}
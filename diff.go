@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// PackageChange is a difference between the packages installed in two siteroots
+type PackageChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"` // version-release in the first siteroot
+	New  string `json:"new,omitempty"` // version-release in the second siteroot
+}
+
+// SiterootDiff lists the differences between the packages installed in two
+// siteroots (or snapshots of a siteroot)
+type SiterootDiff struct {
+	Old            string          `json:"old"`
+	New            string          `json:"new"`
+	Added          []PackageChange `json:"added"`
+	Removed        []PackageChange `json:"removed"`
+	VersionChanged []PackageChange `json:"version_changed"`
+	ReleaseChanged []PackageChange `json:"release_changed"`
+}
+
+// Empty returns whether both package sets are identical
+func (diff *SiterootDiff) Empty() bool {
+	return len(diff.Added)+len(diff.Removed)+len(diff.VersionChanged)+len(diff.ReleaseChanged) == 0
+}
+
+func (diff *SiterootDiff) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", diff.Old, diff.New)
+	if diff.Empty() {
+		_, err := fmt.Fprintf(w, "** No difference found **\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, group := range []struct {
+		title   string
+		changes []PackageChange
+	}{
+		{"added", diff.Added},
+		{"removed", diff.Removed},
+		{"version changed", diff.VersionChanged},
+		{"release changed", diff.ReleaseChanged},
+	} {
+		if len(group.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s (%d):\n", group.title, len(group.changes))
+		for _, c := range group.changes {
+			switch {
+			case c.Old == "":
+				fmt.Fprintf(tw, "  + %s\t%s\n", c.Name, c.New)
+			case c.New == "":
+				fmt.Fprintf(tw, "  - %s\t%s\n", c.Name, c.Old)
+			default:
+				fmt.Fprintf(tw, "  ~ %s\t%s -> %s\n", c.Name, c.Old, c.New)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// diffInstalled compares two sets of installed (name, version, release) packages
+func diffInstalled(old, cur [][3]string) *SiterootDiff {
+	diff := &SiterootDiff{
+		Added:          make([]PackageChange, 0),
+		Removed:        make([]PackageChange, 0),
+		VersionChanged: make([]PackageChange, 0),
+		ReleaseChanged: make([]PackageChange, 0),
+	}
+
+	byName := func(pkgs [][3]string) map[string][]yum.RPM {
+		set := make(map[string][]yum.RPM)
+		for _, pkg := range pkgs {
+			set[pkg[0]] = append(set[pkg[0]], yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil))
+		}
+		return set
+	}
+	olds := byName(old)
+	news := byName(cur)
+
+	names := make([]string, 0, len(olds)+len(news))
+	for name := range olds {
+		names = append(names, name)
+	}
+	for name := range news {
+		if _, dup := olds[name]; !dup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	vr := func(rpm yum.RPM) string { return rpm.Version() + "-" + rpm.Release() }
+	for _, name := range names {
+		// drop the versions installed on both sides
+		added := onlyIn(news[name], olds[name])
+		removed := onlyIn(olds[name], news[name])
+		sort.Sort(rpmsByVersion(added))
+		sort.Sort(rpmsByVersion(removed))
+
+		// pair the remaining versions, oldest with oldest
+		for len(added) > 0 && len(removed) > 0 {
+			o, n := removed[0], added[0]
+			removed, added = removed[1:], added[1:]
+			c := PackageChange{Name: name, Old: vr(o), New: vr(n)}
+			if o.Version() == n.Version() {
+				diff.ReleaseChanged = append(diff.ReleaseChanged, c)
+			} else {
+				diff.VersionChanged = append(diff.VersionChanged, c)
+			}
+		}
+		for _, rpm := range added {
+			diff.Added = append(diff.Added, PackageChange{Name: name, New: vr(rpm)})
+		}
+		for _, rpm := range removed {
+			diff.Removed = append(diff.Removed, PackageChange{Name: name, Old: vr(rpm)})
+		}
+	}
+	return diff
+}
+
+// onlyIn returns the RPMs of a which are not in b (with their multiplicity)
+func onlyIn(a, b []yum.RPM) []yum.RPM {
+	count := make(map[string]int, len(b))
+	for _, rpm := range b {
+		count[rpm.RPMName()]++
+	}
+	var out []yum.RPM
+	for _, rpm := range a {
+		if count[rpm.RPMName()] > 0 {
+			count[rpm.RPMName()]--
+			continue
+		}
+		out = append(out, rpm)
+	}
+	return out
+}
+
+// DiffSiteroots compares the packages installed in two siteroots.
+// The siteroots are only read (see queryInstalledPackages).
+func DiffSiteroots(oldroot, newroot string) (*SiterootDiff, error) {
+	old, err := queryInstalledPackages(oldroot)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := queryInstalledPackages(newroot)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffInstalled(old, cur)
+	diff.Old = oldroot
+	diff.New = newroot
+	return diff, nil
+}
+
+// DiffSnapshot compares the packages recorded in a snapshot file with the
+// ones installed in a siteroot.
+// A snapshot is the output of 'lbpkr installed' (in the text or JSON format).
+func DiffSnapshot(fname, siteroot string) (*SiterootDiff, error) {
+	old, err := readSnapshot(fname)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := queryInstalledPackages(siteroot)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffInstalled(old, cur)
+	diff.Old = fname
+	diff.New = siteroot
+	return diff, nil
+}
+
+// readSnapshot reads the list of installed packages of a snapshot file
+func readSnapshot(fname string) ([][3]string, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var infos []PackageInfo
+		err = json.Unmarshal(trimmed, &infos)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid snapshot [%s]: %v", fname, err)
+		}
+		pkgs := make([][3]string, 0, len(infos))
+		for _, info := range infos {
+			pkgs = append(pkgs, [3]string{info.Name, info.Version, info.Release})
+		}
+		return pkgs, nil
+	}
+
+	pkgs := make([][3]string, 0)
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		// skip empty lines, comments and log messages
+		if line == "" || strings.HasPrefix(line, "#") || strings.Contains(line, " ") {
+			continue
+		}
+		i := strings.LastIndex(line, "-")
+		j := -1
+		if i > 0 {
+			j = strings.LastIndex(line[:i], "-")
+		}
+		if j <= 0 {
+			return nil, fmt.Errorf("lbpkr: invalid snapshot [%s]: invalid package %q (expected name-version-release)", fname, line)
+		}
+		pkgs = append(pkgs, [3]string{line[:j], line[j+1 : i], line[i+1:]})
+	}
+	return pkgs, scan.Err()
+}
+
+// checkSiteroot checks that a siteroot holds a RPM database
+func checkSiteroot(siteroot string) error {
+	if !path_exists(filepath.Join(siteroot, "var", "lib", "rpm")) {
+		return fmt.Errorf("lbpkr: no RPM database under siteroot [%s]", siteroot)
+	}
+	return nil
+}
+
+// rpmsByVersion sorts RPMs of the same name by version
+type rpmsByVersion []yum.RPM
+
+func (p rpmsByVersion) Len() int           { return len(p) }
+func (p rpmsByVersion) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p rpmsByVersion) Less(i, j int) bool { return yum.RPMLessThan(p[i], p[j]) }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// Downgrade replaces the installed package name with an older build available
+// from the repositories, downgrading its dependencies as needed.
+// If version (and release) are empty, the newest build older than the
+// installed one is selected.
+func (ctx *Context) Downgrade(name, version, release string) error {
+	var err error
+
+	tx, err := ctx.planDowngrade(name, version, release)
+	if err != nil {
+		return err
+	}
+
+	tx.preview(ctx)
+
+	err = tx.checkDiskSpace(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.options.DryRun {
+		ctx.msg.Infof("no RPM downgraded (dry-run)\n")
+		return nil
+	}
+
+	err = ctx.confirm("is this ok?")
+	if err != nil {
+		return err
+	}
+
+	todo := make([]Package, 0, len(tx.Items))
+	for _, item := range tx.Items {
+		todo = append(todo, item.Package)
+	}
+
+	err = ctx.downloadPackages(todo, ctx.tmpdir)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-Uvh", "--oldpackage"}
+	if ctx.options.Force || ctx.options.NoDeps {
+		args = append(args, "--nodeps")
+	}
+	if ctx.options.JustDb {
+		args = append(args, "--justdb")
+	}
+	for _, pkg := range todo {
+		args = append(args, filepath.Join(ctx.tmpdir, pkg.RPMFileName()))
+	}
+
+	ctx.msg.Infof("downgrading [%d] RPMs...\n", len(todo))
+	out, err := ctx.rpm(true, args...)
+	if err != nil {
+		ctx.msg.Errorf("rpm downgrade command failed: %v\n%v\n", err, string(out))
+		return err
+	}
+	return err
+}
+
+// planDowngrade computes the transaction replacing the installed package name
+// with an older build, along with the dependencies of that build which are
+// not satisfied by the installed packages.
+func (ctx *Context) planDowngrade(name, version, release string) (*Transaction, error) {
+	installed, err := ctx.installedRPMs()
+	if err != nil {
+		return nil, err
+	}
+
+	cur, ok := installed[name]
+	if !ok {
+		return nil, fmt.Errorf("lbpkr: package %q is not installed", name)
+	}
+
+	pkgs, err := ctx.yum.ListPackages("^"+regexp.QuoteMeta(name)+"$", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var target *yum.Package
+	sort.Sort(yum.Packages(pkgs))
+	for _, pkg := range pkgs {
+		if !yum.RPMLessThan(pkg, cur) {
+			continue
+		}
+		if version != "" && pkg.Version() != version {
+			continue
+		}
+		if release != "" && pkg.Release() != release {
+			continue
+		}
+		target = pkg
+	}
+	if target == nil {
+		return nil, fmt.Errorf("lbpkr: no build of %s older than %s-%s available",
+			name, cur.Version(), cur.Release(),
+		)
+	}
+	ctx.msg.Infof("downgrading %s-%s-%s to %s\n", name, cur.Version(), cur.Release(), target.RPMName())
+
+	deps := []*yum.Package{target}
+	if !ctx.options.NoDeps {
+		deps, err = ctx.downgradeDeps(target, installed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx := &Transaction{Items: make([]TransactionItem, 0, len(deps))}
+	for _, dep := range deps {
+		item := ctx.newTransactionItem(Package{dep, InstallMode})
+		item.Action = InstallAction
+		if _, ok := installed[dep.Name()]; ok {
+			item.Action = DowngradeAction
+		}
+		tx.Items = append(tx.Items, item)
+	}
+	sort.Sort(transactionItems(tx.Items))
+	return tx, nil
+}
+
+// downgradeDeps returns target and the packages to install along with it.
+// An installed package satisfying a requirement is kept. Otherwise, the
+// newest provider satisfying the requirement, and not newer than the
+// installed version (if any), is selected.
+func (ctx *Context) downgradeDeps(target *yum.Package, installed map[string]yum.RPM) ([]*yum.Package, error) {
+	ignored := make(map[string]bool, len(yum.IGNORED_PACKAGES))
+	for _, v := range yum.IGNORED_PACKAGES {
+		ignored[v] = true
+	}
+
+	selected := map[string]*yum.Package{target.Name(): target}
+	deps := []*yum.Package{target}
+	for i := 0; i < len(deps); i++ {
+		pkg := deps[i]
+		for _, req := range pkg.Requires() {
+			if ignored[req.Name()] {
+				continue
+			}
+			if p, ok := selected[req.Name()]; ok {
+				if !req.ProvideMatches(p) {
+					return nil, fmt.Errorf("lbpkr: conflicting versions of %s required (%s requires %s, %s selected)",
+						req.Name(), pkg.RPMName(), req.ID(), p.RPMName(),
+					)
+				}
+				continue
+			}
+			cur, isInstalled := installed[req.Name()]
+			if isInstalled && req.ProvideMatches(cur) {
+				continue
+			}
+
+			dep, err := ctx.downgradeProvider(req, cur)
+			if err != nil {
+				return nil, fmt.Errorf("%v (required by %s)", err, pkg.RPMName())
+			}
+			if dep == nil {
+				// virtual provide, satisfied by an installed package
+				continue
+			}
+			if p, ok := selected[dep.Name()]; ok {
+				if p.ID() != dep.ID() {
+					return nil, fmt.Errorf("lbpkr: conflicting versions of %s required (%s and %s)",
+						dep.Name(), p.RPMName(), dep.RPMName(),
+					)
+				}
+				continue
+			}
+			selected[dep.Name()] = dep
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+// downgradeProvider returns the newest package satisfying req and not newer
+// than cur (the installed version, if any).
+// downgradeProvider returns nil if req is a virtual provide of an installed package.
+func (ctx *Context) downgradeProvider(req *yum.Requires, cur yum.RPM) (*yum.Package, error) {
+	pkgs, err := ctx.yum.ListPackages("^"+regexp.QuoteMeta(req.Name())+"$", "", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		// not a package name: look for a package providing it
+		pkg, err := ctx.yum.FindLatestMatchingRequire(req)
+		if err != nil || pkg == nil {
+			return nil, fmt.Errorf("lbpkr: no package provides %s", req.ID())
+		}
+		if ctx.isRPMInstalled(pkg.Name(), "", "") {
+			return nil, nil
+		}
+		return pkg, nil
+	}
+
+	var dep *yum.Package
+	for _, pkg := range pkgs {
+		if !req.ProvideMatches(pkg) {
+			continue
+		}
+		if cur != nil && yum.RPMLessThan(cur, pkg) {
+			continue
+		}
+		if dep == nil || yum.RPMLessThan(dep, pkg) {
+			dep = pkg
+		}
+	}
+	if dep == nil {
+		if cur != nil {
+			return nil, fmt.Errorf("lbpkr: no build of %s satisfies %s without upgrading the installed %s",
+				req.Name(), req.ID(), cur.RPMName(),
+			)
+		}
+		return nil, fmt.Errorf("lbpkr: no build of %s satisfies %s", req.Name(), req.ID())
+	}
+	return dep, nil
+}
+
+// installedRPMs returns the newest installed version of each installed package, by name
+func (ctx *Context) installedRPMs() (map[string]yum.RPM, error) {
+	pkgs, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	rpms := make(map[string]yum.RPM, len(pkgs))
+	for _, pkg := range pkgs {
+		rpm := yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil)
+		if old, ok := rpms[pkg[0]]; ok && !yum.RPMLessThan(old, rpm) {
+			continue
+		}
+		rpms[pkg[0]] = rpm
+	}
+	return rpms, err
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// EnvVar is an environment variable of a project runtime environment.
+// Either Value is set, or Paths are prepended to the current value.
+type EnvVar struct {
+	Name  string   `json:"name"`
+	Value string   `json:"value,omitempty"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Environment is the runtime environment of an installed project
+type Environment struct {
+	Project  string   `json:"project"`
+	Version  string   `json:"version"`
+	Platform string   `json:"platform"`
+	Packages []string `json:"packages"` // installed RPMs of the project and its externals
+	Vars     []EnvVar `json:"vars"`
+}
+
+// Shells lists the supported output formats of environment scripts
+var Shells = []string{"sh", "bash", "zsh", "csh", "tcsh", "fish", "lmod", "tcl"}
+
+// envScript displays an environment as a script for a given shell
+type envScript struct {
+	*Environment
+	shell string
+}
+
+func (env envScript) writeText(w io.Writer) error {
+	return env.Write(w, env.shell)
+}
+
+// Write writes the environment as a script for the given shell
+// (sh, bash, zsh, csh, tcsh, fish) or as an Lmod (lmod) or Tcl (tcl) modulefile.
+func (env *Environment) Write(w io.Writer, shell string) error {
+	var err error
+	pr := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+
+	header := fmt.Sprintf("environment of %s %s (%s), generated by lbpkr", env.Project, env.Version, env.Platform)
+	switch shell {
+	case "sh", "bash", "zsh":
+		pr("# %s\n", header)
+		for _, v := range env.Vars {
+			if len(v.Paths) == 0 {
+				pr("export %s=%s\n", v.Name, shQuote(v.Value))
+				continue
+			}
+			pr("export %s=%s\"${%s:+:$%s}\"\n", v.Name, shQuote(strings.Join(v.Paths, ":")), v.Name, v.Name)
+		}
+
+	case "csh", "tcsh":
+		pr("# %s\n", header)
+		for _, v := range env.Vars {
+			if len(v.Paths) == 0 {
+				pr("setenv %s %s\n", v.Name, shQuote(v.Value))
+				continue
+			}
+			paths := shQuote(strings.Join(v.Paths, ":"))
+			pr("if ( $?%s ) then\n", v.Name)
+			pr("  setenv %s %s:\"${%s}\"\n", v.Name, paths, v.Name)
+			pr("else\n")
+			pr("  setenv %s %s\n", v.Name, paths)
+			pr("endif\n")
+		}
+
+	case "fish":
+		pr("# %s\n", header)
+		for _, v := range env.Vars {
+			if len(v.Paths) == 0 {
+				pr("set -gx %s %s\n", v.Name, shQuote(v.Value))
+				continue
+			}
+			paths := make([]string, 0, len(v.Paths))
+			for _, p := range v.Paths {
+				paths = append(paths, shQuote(p))
+			}
+			pr("set -gx %s %s $%s\n", v.Name, strings.Join(paths, " "), v.Name)
+		}
+
+	case "lmod":
+		pr("-- %s\n", header)
+		pr("whatis(%q)\n", "Name: "+env.Project)
+		pr("whatis(%q)\n", "Version: "+env.Version)
+		for _, v := range env.Vars {
+			if len(v.Paths) == 0 {
+				pr("setenv(%q, %q)\n", v.Name, v.Value)
+				continue
+			}
+			// prepend in reverse order, so the first path ends up first
+			for i := len(v.Paths) - 1; i >= 0; i-- {
+				pr("prepend_path(%q, %q)\n", v.Name, v.Paths[i])
+			}
+		}
+
+	case "tcl":
+		pr("#%%Module1.0\n")
+		pr("## %s\n", header)
+		pr("module-whatis {%s %s}\n", env.Project, env.Version)
+		for _, v := range env.Vars {
+			if len(v.Paths) == 0 {
+				pr("setenv %s {%s}\n", v.Name, v.Value)
+				continue
+			}
+			for i := len(v.Paths) - 1; i >= 0; i-- {
+				pr("prepend-path %s {%s}\n", v.Name, v.Paths[i])
+			}
+		}
+
+	default:
+		return fmt.Errorf("lbpkr: unknown shell %q (expected one of %v)", shell, Shells)
+	}
+	return err
+}
+
+// shQuote quotes a string for POSIX-like shells
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// defaultShell returns the shell of the user, as given by $SHELL
+func defaultShell() string {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	for _, v := range Shells {
+		if v == shell {
+			return shell
+		}
+	}
+	return "sh"
+}
+
+// cmtconfig converts an RPM platform name (x86_64_slc6_gcc48_opt) into a
+// CMTCONFIG value (x86_64-slc6-gcc48-opt)
+func cmtconfig(platform string) string {
+	arch := ""
+	for _, v := range []string{"x86_64", "i686"} {
+		if strings.HasPrefix(platform, v) {
+			arch = v
+			platform = strings.TrimPrefix(platform, v)
+			break
+		}
+	}
+	return arch + strings.Replace(platform, "_", "-", -1)
+}
+
+// ProjectEnv computes the runtime environment of an installed project and its
+// externals, from the files installed by their RPMs.
+// If version or platform are empty, they are inferred from the installed RPMs
+// (and $CMTCONFIG, or the first of the platforms setting, for the platform).
+func (ctx *Context) ProjectEnv(project, version, platform string) (*Environment, error) {
+	if platform == "" {
+		platform = strings.TrimSpace(strings.Split(ctx.defaultPlatforms(), ",")[0])
+	}
+
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	vers := `[^_]+`
+	if version != "" {
+		vers = regexp.QuoteMeta(version)
+	}
+	plat := `.+`
+	if platform != "" {
+		plat = regexp.QuoteMeta(platform)
+	}
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(project) + "_(" + vers + ")_(" + plat + ")$")
+
+	var candidates [][3]string
+	for _, pkg := range installed {
+		if strings.HasSuffix(pkg[0], "_index") || !re.MatchString(pkg[0]) {
+			continue
+		}
+		candidates = append(candidates, pkg)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("lbpkr: no installed project with name=%q version=%q and platform=%q",
+			project, version, platform,
+		)
+	case 1:
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, pkg := range candidates {
+			names = append(names, pkg[0])
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("lbpkr: several installed projects match name=%q version=%q and platform=%q (%s). please specify the version and platform",
+			project, version, platform, strings.Join(names, ", "),
+		)
+	}
+
+	root := candidates[0]
+	sub := re.FindStringSubmatch(root[0])
+	env := &Environment{
+		Project:  project,
+		Version:  sub[1],
+		Platform: sub[2],
+	}
+
+	// collect the installed externals of the project
+	rpms := [][3]string{root}
+	if p, err := ctx.yum.FindLatestProvider(root[0], root[1], root[2]); err == nil {
+		deps, err := ctx.yum.PackageDeps(p, -1)
+		if err != nil {
+			ctx.msg.Warnf("could not retrieve the dependencies of %s: %v\n", p.RPMName(), err)
+		}
+		names := make(map[string]struct{}, len(deps))
+		for _, dep := range deps {
+			names[dep.Name()] = struct{}{}
+		}
+		var externals [][3]string
+		for _, pkg := range installed {
+			if _, ok := names[pkg[0]]; ok {
+				externals = append(externals, pkg)
+			}
+		}
+		sort.Sort(rpmIDs(externals))
+		rpms = append(rpms, externals...)
+	} else {
+		ctx.msg.Warnf("no provider for %s-%s-%s: only the project itself is considered\n", root[0], root[1], root[2])
+	}
+
+	paths := map[string][]string{}
+	seen := map[string]struct{}{}
+	add := func(name, dir string) {
+		key := name + ":" + dir
+		if _, dup := seen[key]; dup {
+			return
+		}
+		seen[key] = struct{}{}
+		paths[name] = append(paths[name], dir)
+	}
+
+	for _, rpm := range rpms {
+		id := rpm[0] + "-" + rpm[1] + "-" + rpm[2]
+		env.Packages = append(env.Packages, id)
+		files, err := ctx.rpmFiles(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.Mode&syscall.S_IFMT != syscall.S_IFREG && f.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+				continue
+			}
+			if name, dir := envPathFor(f); name != "" {
+				add(name, dir)
+			}
+		}
+	}
+
+	env.Vars = append(env.Vars,
+		EnvVar{Name: "MYSITEROOT", Value: ctx.siteroot},
+		EnvVar{Name: "CMTCONFIG", Value: cmtconfig(env.Platform)},
+	)
+	for _, name := range []string{"PATH", "LD_LIBRARY_PATH", "PYTHONPATH"} {
+		if len(paths[name]) > 0 {
+			env.Vars = append(env.Vars, EnvVar{Name: name, Paths: paths[name]})
+		}
+	}
+	return env, nil
+}
+
+// envPathFor returns the name of the search path variable which should
+// contain (a parent directory of) an installed file, and that directory.
+func envPathFor(f rpmFile) (string, string) {
+	dir := filepath.Dir(f.Name)
+	base := filepath.Base(f.Name)
+	switch filepath.Base(dir) {
+	case "bin", "scripts":
+		if f.Mode&0111 != 0 {
+			return "PATH", dir
+		}
+	case "lib", "lib64":
+		if strings.Contains(base, ".so") {
+			return "LD_LIBRARY_PATH", dir
+		}
+	}
+
+	if strings.HasSuffix(base, ".py") {
+		for _, top := range []string{"/site-packages/", "/python/"} {
+			if i := strings.Index(f.Name, top); i >= 0 {
+				return "PYTHONPATH", f.Name[:i+len(top)-1]
+			}
+		}
+	}
+	return "", ""
+}
+
+// rpmIDs sorts (name, version, release) triplets by name
+type rpmIDs [][3]string
+
+func (p rpmIDs) Len() int           { return len(p) }
+func (p rpmIDs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p rpmIDs) Less(i, j int) bool { return p[i][0] < p[j][0] }
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// FileInfo is a (relocated) file of a package, as displayed by the files command
+type FileInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`           // file, dir, link or ghost
+	Size   int64  `json:"size"`           // -1 if unknown
+	LinkTo string `json:"link,omitempty"` // target of symbolic links
+}
+
+// PackageFiles lists the files of an installed or available package
+type PackageFiles struct {
+	Package   string     `json:"package"`
+	Installed bool       `json:"installed"`
+	Size      int64      `json:"size"` // installed size of the package
+	Files     []FileInfo `json:"files"`
+}
+
+// fileList displays the files of a package, one per line
+type fileList struct {
+	*PackageFiles
+	size bool // display file sizes
+}
+
+func (p fileList) writeText(w io.Writer) error {
+	for _, f := range p.Files {
+		name := f.Name
+		if f.LinkTo != "" {
+			name += " -> " + f.LinkTo
+		}
+		var err error
+		if p.size {
+			_, err = fmt.Fprintf(w, "%10s  %s\n", fileSize(f), name)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if p.size {
+		_, err := fmt.Fprintf(w, "%10s  total (%s)\n", humanSize(p.Size), p.Package)
+		return err
+	}
+	return nil
+}
+
+// fileTree displays the files of a package as a tree
+type fileTree struct {
+	*PackageFiles
+	size bool // display file (and directory) sizes
+}
+
+// fileNode is a file or directory of a fileTree
+type fileNode struct {
+	name     string
+	file     *FileInfo
+	size     int64 // -1 if unknown
+	children map[string]*fileNode
+}
+
+func (node *fileNode) child(name string) *fileNode {
+	c, ok := node.children[name]
+	if !ok {
+		c = &fileNode{name: name, children: make(map[string]*fileNode)}
+		node.children[name] = c
+	}
+	return c
+}
+
+// computeSize sets (and returns) the size of directories from the size of their content
+func (node *fileNode) computeSize() int64 {
+	if len(node.children) == 0 {
+		if node.file == nil || node.file.Type == "dir" {
+			node.size = 0
+		} else {
+			node.size = node.file.Size
+		}
+		return node.size
+	}
+	node.size = 0
+	for _, c := range node.children {
+		n := c.computeSize()
+		if n < 0 || node.size < 0 {
+			node.size = -1
+			continue
+		}
+		node.size += n
+	}
+	return node.size
+}
+
+func (p fileTree) writeText(w io.Writer) error {
+	if len(p.Files) == 0 {
+		return nil
+	}
+
+	top := commonDir(p.Files)
+	root := &fileNode{name: top, children: make(map[string]*fileNode)}
+	for i := range p.Files {
+		f := &p.Files[i]
+		rel := strings.TrimPrefix(strings.TrimPrefix(f.Name, top), "/")
+		if rel == "" {
+			continue
+		}
+		node := root
+		for _, name := range strings.Split(rel, "/") {
+			node = node.child(name)
+		}
+		node.file = f
+	}
+	if root.computeSize() < 0 {
+		// sizes of the files of available packages are unknown
+		root.size = p.Size
+	}
+
+	var err error
+	pr := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+
+	label := func(node *fileNode) string {
+		name := node.name
+		if node.file != nil && node.file.LinkTo != "" {
+			name += " -> " + node.file.LinkTo
+		}
+		if !p.size {
+			return name
+		}
+		size := "-"
+		if node.size >= 0 {
+			size = humanSize(node.size)
+		}
+		return "[" + size + "] " + name
+	}
+
+	var walk func(node *fileNode, indent string)
+	walk = func(node *fileNode, indent string) {
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			c := node.children[name]
+			branch, next := "├── ", "│   "
+			if i == len(names)-1 {
+				branch, next = "└── ", "    "
+			}
+			pr("%s%s%s\n", indent, branch, label(c))
+			walk(c, indent+next)
+		}
+	}
+
+	pr("%s\n", label(root))
+	walk(root, "")
+	return err
+}
+
+// commonDir returns the deepest directory containing all the files
+func commonDir(files []FileInfo) string {
+	dir := ""
+	for i, f := range files {
+		d := f.Name
+		if f.Type != "dir" {
+			d = path.Dir(d)
+		}
+		if i == 0 {
+			dir = d
+			continue
+		}
+		for dir != "/" && dir != "." && d != dir && !strings.HasPrefix(d, dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// fileSize returns the human readable size of a file
+func fileSize(f FileInfo) string {
+	if f.Size < 0 {
+		return "-"
+	}
+	return humanSize(f.Size)
+}
+
+// Files returns the (relocated) files of the package name (and version,
+// release): from the RPM database if it is installed, or from the file lists
+// metadata of the repositories otherwise (or if available is true).
+func (ctx *Context) Files(name, version, release string, available bool) (*PackageFiles, error) {
+	if !available {
+		rpm, err := ctx.findInstalled(name, version, release)
+		if err != nil {
+			return nil, err
+		}
+		if rpm != nil {
+			return ctx.installedFiles(rpm)
+		}
+	}
+
+	pkg, err := ctx.yum.FindLatestProvider(name, version, release)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: no such package name=%q version=%q release=%q (%v)", name, version, release, err)
+	}
+
+	files, err := ctx.yum.Files(pkg)
+	if err == yum.ErrNoMetadata {
+		return nil, fmt.Errorf("lbpkr: repository [%s] publishes no file lists", pkg.Repository().Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &PackageFiles{
+		Package: pkg.ID(),
+		Size:    pkg.InstalledSize(),
+		Files:   make([]FileInfo, 0, len(files)),
+	}
+	for _, f := range files {
+		list.Files = append(list.Files, FileInfo{
+			Name: ctx.relocs.Relocate(f.Name),
+			Type: f.Type,
+			Size: -1,
+		})
+	}
+	return list, nil
+}
+
+// findInstalled returns the latest installed RPM matching name (and version,
+// release), or nil if there is none.
+func (ctx *Context) findInstalled(name, version, release string) (yum.RPM, error) {
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var found yum.RPM
+	for _, pkg := range installed {
+		if pkg[0] != name ||
+			(version != "" && pkg[1] != version) ||
+			(release != "" && pkg[2] != release) {
+			continue
+		}
+		rpm := yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil)
+		if found == nil || yum.RPMLessThan(found, rpm) {
+			found = rpm
+		}
+	}
+	return found, nil
+}
+
+// installedFiles returns the files of an installed RPM, from the RPM database
+func (ctx *Context) installedFiles(rpm yum.RPM) (*PackageFiles, error) {
+	files, err := ctx.rpmFiles(rpm.RPMName())
+	if err != nil {
+		return nil, err
+	}
+
+	list := &PackageFiles{
+		Package:   rpm.RPMName(),
+		Installed: true,
+		Files:     make([]FileInfo, 0, len(files)),
+	}
+	for _, f := range files {
+		info := FileInfo{Name: f.Name, Type: "file", Size: f.Size, LinkTo: f.LinkTo}
+		switch {
+		case f.Flags&rpmFileGhost != 0:
+			info.Type = "ghost"
+		case f.Mode&syscall.S_IFMT == syscall.S_IFDIR:
+			info.Type = "dir"
+			info.Size = 0
+		case f.Mode&syscall.S_IFMT == syscall.S_IFLNK:
+			info.Type = "link"
+		}
+		list.Size += info.Size
+		list.Files = append(list.Files, info)
+	}
+	sort.Sort(fileInfos(list.Files))
+	return list, nil
+}
+
+type fileInfos []FileInfo
+
+func (p fileInfos) Len() int           { return len(p) }
+func (p fileInfos) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p fileInfos) Less(i, j int) bool { return p[i].Name < p[j].Name }
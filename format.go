@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// Format is the output format of query commands (text|json)
+type Format string
+
+const (
+	TextFormat Format = "text"
+	JSONFormat Format = "json"
+)
+
+// textWriter is implemented by query results which know how to display
+// themselves in the (default) text format.
+type textWriter interface {
+	writeText(w io.Writer) error
+}
+
+// render displays the result of a query command on stdout, in the output
+// format of the Context.
+func (ctx *Context) render(v textWriter) error {
+	return render(ctx.format, v)
+}
+
+// render writes v on stdout, in the given format
+func render(format Format, v textWriter) error {
+	switch format {
+	case JSONFormat:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	default:
+		return v.writeText(os.Stdout)
+	}
+}
+
+// PackageInfo is the description of a RPM package, as displayed by query commands
+type PackageInfo struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Release    string `json:"release"`
+	Epoch      string `json:"epoch"`
+	Arch       string `json:"arch"`
+	Repository string `json:"repository"`
+	URL        string `json:"url"`
+}
+
+func newPackageInfo(pkg *yum.Package) PackageInfo {
+	info := PackageInfo{
+		Name:    pkg.Name(),
+		Version: pkg.Version(),
+		Release: pkg.Release(),
+		Epoch:   pkg.Epoch(),
+		Arch:    pkg.Arch(),
+	}
+	if repo := pkg.Repository(); repo != nil {
+		info.Repository = repo.Name
+		info.URL = pkg.Url()
+	}
+	return info
+}
+
+// packageList is a list of RPM packages, sorted by name and version
+type packageList []*yum.Package
+
+func (p packageList) MarshalJSON() ([]byte, error) {
+	infos := make([]PackageInfo, 0, len(p))
+	for _, pkg := range p {
+		infos = append(infos, newPackageInfo(pkg))
+	}
+	return json.Marshal(infos)
+}
+
+func (p packageList) writeText(w io.Writer) error {
+	if len(p) <= 0 {
+		_, err := fmt.Fprintf(w, "** No Match found **\n")
+		return err
+	}
+	for _, pkg := range p {
+		_, err := fmt.Fprintf(w, "%s\n", pkg.ID())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProvidedFile is a file provided by an installed RPM package
+type ProvidedFile struct {
+	Package *yum.Package
+	File    string
+}
+
+type providedFiles []ProvidedFile
+
+func (p providedFiles) MarshalJSON() ([]byte, error) {
+	type jsonFile struct {
+		PackageInfo
+		File string `json:"file"`
+	}
+	files := make([]jsonFile, 0, len(p))
+	for _, f := range p {
+		files = append(files, jsonFile{
+			PackageInfo: newPackageInfo(f.Package),
+			File:        f.File,
+		})
+	}
+	return json.Marshal(files)
+}
+
+func (p providedFiles) writeText(w io.Writer) error {
+	if len(p) <= 0 {
+		_, err := fmt.Fprintf(w, "** No Match found **\n")
+		return err
+	}
+
+	lines := make([]string, 0, len(p))
+	for _, f := range p {
+		lines = append(lines, fmt.Sprintf("%s (%s)", f.Package.ID(), f.File))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		_, err := fmt.Fprintf(w, "%s\n", line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PackageUpdate describes an available update for an installed RPM package
+type PackageUpdate struct {
+	Old  yum.RPM      // installed package
+	New  *yum.Package // package available in the repositories
+	Mode Mode         // UpdateMode or UpgradeMode
+
+	ChangeLog []yum.ChangeLogEntry // changelog entries since the installed package, if requested
+}
+
+type packageUpdates []PackageUpdate
+
+func (p packageUpdates) Len() int           { return len(p) }
+func (p packageUpdates) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p packageUpdates) Less(i, j int) bool { return p[i].Old.Name() < p[j].Old.Name() }
+
+// count returns the number of updates and upgrades
+func (p packageUpdates) count() (update, upgrade int) {
+	for _, u := range p {
+		if u.Mode == UpgradeMode {
+			upgrade++
+		} else {
+			update++
+		}
+	}
+	return update, upgrade
+}
+
+func (p packageUpdates) MarshalJSON() ([]byte, error) {
+	type jsonInstalled struct {
+		Version string `json:"version"`
+		Release string `json:"release"`
+		Epoch   string `json:"epoch"`
+	}
+	type jsonUpdate struct {
+		Name      string        `json:"name"`
+		Mode      string        `json:"mode"`
+		Installed jsonInstalled `json:"installed"`
+		Available PackageInfo   `json:"available"`
+
+		ChangeLog []yum.ChangeLogEntry `json:"changelog,omitempty"`
+	}
+	updates := make([]jsonUpdate, 0, len(p))
+	for _, u := range p {
+		mode := "update"
+		if u.Mode == UpgradeMode {
+			mode = "upgrade"
+		}
+		updates = append(updates, jsonUpdate{
+			Name: u.Old.Name(),
+			Mode: mode,
+			Installed: jsonInstalled{
+				Version: u.Old.Version(),
+				Release: u.Old.Release(),
+				Epoch:   u.Old.Epoch(),
+			},
+			Available: newPackageInfo(u.New),
+			ChangeLog: u.ChangeLog,
+		})
+	}
+	return json.Marshal(updates)
+}
+
+func (p packageUpdates) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+	for _, u := range p {
+		mode := "update"
+		if u.Mode == UpgradeMode {
+			mode = "upgrade"
+		}
+		fmt.Fprintf(tw, "%s\t%s-%s\t-> %s-%s\t(%v)\n",
+			u.Old.Name(),
+			u.Old.Version(), u.Old.Release(),
+			u.New.Version(), u.New.Release(),
+			mode,
+		)
+		if len(u.ChangeLog) == 0 {
+			continue
+		}
+		// changelog lines are not part of the table
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		for _, entry := range u.ChangeLog {
+			fmt.Fprintf(w, "    * %s %s\n", entry.Date.Format("Mon Jan 02 2006"), entry.Author)
+			for _, line := range strings.Split(entry.Text, "\n") {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// RepoInfo describes a configured yum repository
+type RepoInfo struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Enabled bool   `json:"enabled"`
+}
+
+type repoInfos []RepoInfo
+
+func (p repoInfos) writeText(w io.Writer) error {
+	for _, repo := range p {
+		enabled := "disabled"
+		if repo.Enabled {
+			enabled = "enabled"
+		}
+		_, err := fmt.Fprintf(w, "%s: %q (%s)\n", repo.Name, repo.URL, enabled)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
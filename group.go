@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// GroupInfo is the description of a package group, as displayed by query commands
+type GroupInfo struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Default     bool               `json:"default"`
+	UserVisible bool               `json:"uservisible"`
+	Repository  string             `json:"repository"`
+	Status      string             `json:"status"` // installed, partial or available
+	Packages    []GroupPackageInfo `json:"packages"`
+}
+
+// GroupPackageInfo describes a package member of a group
+type GroupPackageInfo struct {
+	yum.GroupPackage
+	Installed string `json:"installed,omitempty"` // installed RPM, if any
+}
+
+type groupInfos []GroupInfo
+
+func (p groupInfos) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, grp := range p {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", grp.ID, grp.Status, grp.Name)
+	}
+	return tw.Flush()
+}
+
+// groupDetails displays the full description of groups
+type groupDetails []GroupInfo
+
+func (p groupDetails) writeText(w io.Writer) error {
+	for i, grp := range p {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, "group:       %s\n", grp.ID)
+		fmt.Fprintf(w, "name:        %s\n", grp.Name)
+		fmt.Fprintf(w, "status:      %s\n", grp.Status)
+		fmt.Fprintf(w, "repository:  %s\n", grp.Repository)
+		if grp.Description != "" {
+			fmt.Fprintf(w, "description: %s\n", grp.Description)
+		}
+		for _, typ := range []string{
+			yum.MandatoryPackage,
+			yum.DefaultPackage,
+			yum.OptionalPackage,
+			yum.ConditionalPackage,
+		} {
+			header := false
+			for _, pkg := range grp.Packages {
+				if pkg.Type != typ {
+					continue
+				}
+				if !header {
+					fmt.Fprintf(w, "%s packages:\n", typ)
+					header = true
+				}
+				mark := " "
+				if pkg.Installed != "" {
+					mark = "="
+				}
+				fmt.Fprintf(w, "  %s %s", mark, pkg.Name)
+				if pkg.Requires != "" {
+					fmt.Fprintf(w, " (if %s)", pkg.Requires)
+				}
+				fmt.Fprintf(w, "\n")
+			}
+		}
+	}
+	return nil
+}
+
+// Groups returns the package groups published by the repositories.
+// If ids is not empty, only the groups with these IDs (or names) are returned.
+func (ctx *Context) Groups(ids ...string) ([]GroupInfo, error) {
+	groups, err := ctx.findGroups(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := ctx.installedRPMs()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]GroupInfo, 0, len(groups))
+	for _, grp := range groups {
+		info := GroupInfo{
+			ID:          grp.ID,
+			Name:        grp.Name,
+			Description: grp.Description,
+			Default:     grp.Default,
+			UserVisible: grp.UserVisible,
+			Repository:  grp.Repository,
+			Packages:    make([]GroupPackageInfo, 0, len(grp.Packages)),
+		}
+		for _, pkg := range grp.Packages {
+			pinfo := GroupPackageInfo{GroupPackage: pkg}
+			if rpm, ok := installed[pkg.Name]; ok {
+				pinfo.Installed = rpm.RPMName()
+			}
+			info.Packages = append(info.Packages, pinfo)
+		}
+
+		// a group with no mandatory packages is made of its default ones
+		required := grp.PackageNames(yum.MandatoryPackage)
+		if len(required) == 0 {
+			required = grp.PackageNames(yum.DefaultPackage)
+		}
+		found := 0
+		for _, name := range required {
+			if _, ok := installed[name]; ok {
+				found++
+			}
+		}
+		switch {
+		case found > 0 && found == len(required):
+			info.Status = "installed"
+		case found > 0:
+			info.Status = "partial"
+		default:
+			info.Status = "available"
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// findGroups returns the groups with the given IDs (or names), or all groups if ids is empty
+func (ctx *Context) findGroups(ids []string) ([]*yum.Group, error) {
+	groups, err := ctx.yum.Groups()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+
+	found := make([]*yum.Group, 0, len(ids))
+	for _, id := range ids {
+		var grp *yum.Group
+		for _, g := range groups {
+			if g.ID == id || strings.EqualFold(g.Name, id) {
+				grp = g
+				break
+			}
+		}
+		if grp == nil {
+			return nil, fmt.Errorf("lbpkr: no such group %q", id)
+		}
+		found = append(found, grp)
+	}
+	return found, nil
+}
+
+// InstallGroups installs the mandatory and default packages of a list of groups.
+// Optional packages are also installed if optional is true.
+func (ctx *Context) InstallGroups(ids []string, optional bool) error {
+	groups, err := ctx.findGroups(ids)
+	if err != nil {
+		return err
+	}
+
+	installed, err := ctx.installedRPMs()
+	if err != nil {
+		return err
+	}
+
+	types := []string{yum.MandatoryPackage, yum.DefaultPackage}
+	if optional {
+		types = append(types, yum.OptionalPackage)
+	}
+
+	names := make(map[string]string) // package name -> package type
+	for _, grp := range groups {
+		ctx.msg.Infof("installing group %s (%s)\n", grp.ID, grp.Name)
+		for _, typ := range types {
+			for _, name := range grp.PackageNames(typ) {
+				if _, dup := names[name]; !dup {
+					names[name] = typ
+				}
+			}
+		}
+	}
+
+	// conditional packages are installed together with the package they depend on
+	for _, grp := range groups {
+		for _, pkg := range grp.Packages {
+			if pkg.Type != yum.ConditionalPackage {
+				continue
+			}
+			_, selected := names[pkg.Requires]
+			_, isInstalled := installed[pkg.Requires]
+			if selected || isInstalled {
+				names[pkg.Name] = pkg.Type
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	pkgs := make([]Package, 0, len(keys))
+	for _, name := range keys {
+		pkg, err := ctx.yum.FindLatestProvider(name, "", "")
+		if err != nil {
+			if names[name] == yum.MandatoryPackage {
+				return fmt.Errorf("lbpkr: mandatory package %q not available: %v", name, err)
+			}
+			ctx.msg.Warnf("%s package %q not available: %v\n", names[name], name, err)
+			continue
+		}
+		pkgs = append(pkgs, Package{pkg, InstallMode})
+	}
+
+	return ctx.InstallPackages(pkgs)
+}
+
+// RemoveGroups removes the installed packages of a list of groups
+func (ctx *Context) RemoveGroups(ids []string, force bool) error {
+	groups, err := ctx.findGroups(ids)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{})
+	for _, grp := range groups {
+		ctx.msg.Infof("removing group %s (%s)\n", grp.ID, grp.Name)
+		for _, pkg := range grp.Packages {
+			names[pkg.Name] = struct{}{}
+		}
+	}
+
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return err
+	}
+
+	rpms := make([][3]string, 0, len(names))
+	for _, pkg := range installed {
+		if _, ok := names[pkg[0]]; ok {
+			rpms = append(rpms, pkg)
+		}
+	}
+
+	if len(rpms) <= 0 {
+		ctx.msg.Infof("no package of the group(s) is installed\n")
+		return nil
+	}
+
+	return ctx.RemoveRPM(rpms, force)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,11 +14,8 @@ type Config interface {
 	Debug() bool
 	RpmUpdate() bool
 
-	// RelocateArgs returns the arguments to be passed to RPM for the repositories
-	RelocateArgs() []string
-
-	// RelocateFile returns the relocated file path
-	RelocateFile(fname string) string
+	// Relocations returns the default relocation rules of the siteroot
+	Relocations() Relocations
 
 	InitYum(*Context) error
 }
@@ -71,24 +67,15 @@ func (cfg *lhcbConfig) DefaultSiteroot() string {
 	return "/opt/LHCbSoft"
 }
 
-// RelocateArgs returns the arguments to be passed to RPM for the repositories
-func (cfg *lhcbConfig) RelocateArgs() []string {
-	return []string{
-		"--relocate", fmt.Sprintf("%s=%s", "/opt/lcg/external", filepath.Join(cfg.siteroot, "lcg", "external")),
-		"--relocate", fmt.Sprintf("%s=%s", "/opt/lcg", filepath.Join(cfg.siteroot, "lcg", "releases")),
-		"--relocate", fmt.Sprintf("%s=%s", "/opt/LHCbSoft", cfg.siteroot),
-		"--badreloc",
+// Relocations returns the default relocation rules of the siteroot
+func (cfg *lhcbConfig) Relocations() Relocations {
+	return Relocations{
+		{Prefix: "/opt/lcg/external", Path: filepath.Join(cfg.siteroot, "lcg", "external")},
+		{Prefix: "/opt/lcg", Path: filepath.Join(cfg.siteroot, "lcg", "releases")},
+		{Prefix: "/opt/LHCbSoft", Path: cfg.siteroot},
 	}
 }
 
-// RelocateFile returns the relocated file path
-func (cfg *lhcbConfig) RelocateFile(fname string) string {
-	fname = strings.Replace(fname, "/opt/lcg/external", filepath.Join(cfg.siteroot, "lcg", "external"), 1)
-	fname = strings.Replace(fname, "/opt/lcg", filepath.Join(cfg.siteroot, "lcg", "releases"), 1)
-	fname = strings.Replace(fname, "/opt/LHCbSoft", cfg.siteroot, 1)
-	return fname
-}
-
 func (cfg *lhcbConfig) InitYum(ctx *Context) error {
 	var err error
 	repourl := cfg.RepoUrl()
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gonuts/logger"
+)
+
+// defaultLockTimeout is the default duration to wait for the siteroot lock
+const defaultLockTimeout = 10 * time.Minute
+
+// LockMode describes how a Context locks its siteroot
+type LockMode int
+
+const (
+	NoLock        LockMode = iota // do not lock the siteroot
+	SharedLock                    // shared lock, for read-only commands
+	ExclusiveLock                 // exclusive lock, for commands modifying the siteroot
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case NoLock:
+		return "none"
+	case SharedLock:
+		return "shared"
+	case ExclusiveLock:
+		return "exclusive"
+	}
+	return fmt.Sprintf("LockMode(%d)", int(m))
+}
+
+// siteLock is an advisory lock on a siteroot, held via flock(2)
+type siteLock struct {
+	f    *os.File
+	mode LockMode
+}
+
+// lockSiteroot acquires an advisory lock on fname.
+// lockSiteroot waits at most timeout for the lock to be released by other
+// processes. if timeout < 0, lockSiteroot waits forever.
+func lockSiteroot(fname string, mode LockMode, timeout time.Duration, msg *logger.Logger) (*siteLock, error) {
+	if mode == NoLock {
+		return nil, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(fname), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if mode == ExclusiveLock {
+		how = syscall.LOCK_EX
+	}
+
+	start := time.Now()
+	waiting := false
+	for {
+		err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			f.Close()
+			return nil, fmt.Errorf("lbpkr: could not lock %q: %v", fname, err)
+		}
+
+		if timeout >= 0 && time.Since(start) >= timeout {
+			f.Close()
+			return nil, fmt.Errorf(
+				"lbpkr: could not acquire %s lock on %q: held by process %s (waited %v)",
+				mode, fname, lockHolder(fname), timeout,
+			)
+		}
+
+		if !waiting {
+			waiting = true
+			msg.Infof("waiting for %s lock on %q (held by process %s)...\n",
+				mode, fname, lockHolder(fname),
+			)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if waiting {
+		msg.Infof("acquired %s lock on %q after %v\n", mode, fname, time.Since(start))
+	}
+
+	// record our PID so other processes know who is holding the lock.
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		msg.Debugf("could not record PID in lock file %q: %v\n", fname, err)
+	}
+
+	msg.Debugf("acquired %s lock on %q\n", mode, fname)
+	return &siteLock{f: f, mode: mode}, nil
+}
+
+// Release releases the lock
+func (l *siteLock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lockHolder returns the PID recorded in the lock file fname
+func lockHolder(fname string) string {
+	buf, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return "<unknown>"
+	}
+	pid := strings.TrimSpace(string(buf))
+	if pid == "" {
+		return "<unknown>"
+	}
+	return pid
+}
@@ -15,22 +15,42 @@ func init() {
 		UsageLine: "lbpkr",
 		Short:     "installs software in MYSITEROOT directory.",
 		Subcommands: []*commander.Command{
+			lbpkr_make_cmd_changelog(),
 			lbpkr_make_cmd_check(),
+			lbpkr_make_cmd_clean(),
+			lbpkr_make_cmd_config(),
 			lbpkr_make_cmd_deps(),
 			lbpkr_make_cmd_dep_graph(),
+			lbpkr_make_cmd_diff(),
+			lbpkr_make_cmd_downgrade(),
+			lbpkr_make_cmd_download(),
+			lbpkr_make_cmd_env(),
+			lbpkr_make_cmd_export(),
+			lbpkr_make_cmd_files(),
+			lbpkr_make_cmd_group(),
+			lbpkr_make_cmd_import(),
 			lbpkr_make_cmd_install(),
 			lbpkr_make_cmd_install_project(),
 			lbpkr_make_cmd_installed(),
 			lbpkr_make_cmd_list(),
 			lbpkr_make_cmd_provides(),
+			lbpkr_make_cmd_reinstall(),
+			lbpkr_make_cmd_relocations(),
 			lbpkr_make_cmd_remove(),
+			lbpkr_make_cmd_repair(),
 			lbpkr_make_cmd_repo_add(),
 			lbpkr_make_cmd_repo_ls(),
 			lbpkr_make_cmd_repo_rm(),
 			lbpkr_make_cmd_rpm(),
+			lbpkr_make_cmd_search(),
+			lbpkr_make_cmd_sync(),
 			lbpkr_make_cmd_self(),
 			lbpkr_make_cmd_update(),
+			lbpkr_make_cmd_updateinfo(),
+			lbpkr_make_cmd_verify(),
 			lbpkr_make_cmd_version(),
+			lbpkr_make_cmd_whatrequires(),
+			lbpkr_make_cmd_why(),
 		},
 		Flag: *flag.NewFlagSet("lbpkr", flag.ContinueOnError),
 	}
@@ -52,5 +72,19 @@ func main() {
 
 func add_default_options(cmd *commander.Command) {
 	cmd.Flag.String("siteroot", "", "path to site installation")
+	cmd.Flag.String("type", "", "config type (lhcb|generic, default: $LBPKR_CONFIG_TYPE or lhcb)")
 	cmd.Flag.Bool("v", false, "enable verbose mode")
+	cmd.Flag.String("format", string(TextFormat), "output format of query commands (text|json)")
+	cmd.Flag.Duration("lock-timeout", defaultLockTimeout, "maximum time to wait for the siteroot lock (<0: wait forever)")
+
+	// options not given on the command line come from the environment and
+	// the lbpkr.conf files
+	run := cmd.Run
+	cmd.Run = func(cmd *commander.Command, args []string) error {
+		err := applySettings(cmd)
+		if err != nil {
+			return err
+		}
+		return run(cmd, args)
+	}
 }
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/gonuts/logger"
+	"github.com/lhcb-org/lbpkr/yum"
 )
 
 func init() {
@@ -578,3 +589,2543 @@ func TestRPMSplit(t *testing.T) {
 		}
 	}
 }
+
+func TestSiteLock(t *testing.T) {
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "test-lbpkr-")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	msg := logger.NewLogger("lbpkr", logger.INFO, ioutil.Discard)
+	fname := tmpdir + "/var/lock/lbpkr.lock"
+
+	// shared locks can be held concurrently
+	sh1, err := lockSiteroot(fname, SharedLock, 0, msg)
+	if err != nil {
+		t.Fatalf("error acquiring 1st shared lock: %v", err)
+	}
+	sh2, err := lockSiteroot(fname, SharedLock, 0, msg)
+	if err != nil {
+		t.Fatalf("error acquiring 2nd shared lock: %v", err)
+	}
+
+	// an exclusive lock has to wait for all shared locks to be released
+	_, err = lockSiteroot(fname, ExclusiveLock, 200*time.Millisecond, msg)
+	if err == nil {
+		t.Fatalf("expected exclusive lock to time out")
+	}
+	if pid := strconv.Itoa(os.Getpid()); !strings.Contains(err.Error(), "held by process "+pid) {
+		t.Fatalf("expected error to name PID %s. got: %v", pid, err)
+	}
+
+	for _, l := range []*siteLock{sh1, sh2} {
+		err = l.Release()
+		if err != nil {
+			t.Fatalf("error releasing shared lock: %v", err)
+		}
+	}
+
+	ex, err := lockSiteroot(fname, ExclusiveLock, 0, msg)
+	if err != nil {
+		t.Fatalf("error acquiring exclusive lock: %v", err)
+	}
+	defer ex.Release()
+
+	_, err = lockSiteroot(fname, SharedLock, 0, msg)
+	if err == nil {
+		t.Fatalf("expected shared lock to fail while exclusive lock is held")
+	}
+
+	// NoLock never blocks
+	nolock, err := lockSiteroot(fname, NoLock, 0, msg)
+	if err != nil || nolock != nil {
+		t.Fatalf("expected NoLock to be a no-op. got lock=%v err=%v", nolock, err)
+	}
+}
+
+func TestFormatPackageList(t *testing.T) {
+	t.Parallel()
+
+	pkgs := packageList{
+		yum.NewPackage("BRUNEL_v45r1", "1.0.0", "1", "0"),
+		yum.NewPackage("GAUDI_v25r5", "1.0.0", "2", "0"),
+	}
+
+	buf := new(bytes.Buffer)
+	err := pkgs.writeText(buf)
+	if err != nil {
+		t.Fatalf("error writing text: %v", err)
+	}
+	if want := "BRUNEL_v45r1-1.0.0-1\nGAUDI_v25r5-1.0.0-2\n"; buf.String() != want {
+		t.Fatalf("invalid text output.\nwant: %q\n got: %q\n", want, buf.String())
+	}
+
+	out, err := json.Marshal(pkgs)
+	if err != nil {
+		t.Fatalf("error marshaling to JSON: %v", err)
+	}
+	var infos []PackageInfo
+	err = json.Unmarshal(out, &infos)
+	if err != nil {
+		t.Fatalf("error unmarshaling JSON: %v", err)
+	}
+	want := []PackageInfo{
+		{Name: "BRUNEL_v45r1", Version: "1.0.0", Release: "1", Epoch: "0"},
+		{Name: "GAUDI_v25r5", Version: "1.0.0", Release: "2", Epoch: "0"},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Fatalf("invalid JSON output.\nwant: %#v\n got: %#v\n", want, infos)
+	}
+
+	buf.Reset()
+	err = packageList(nil).writeText(buf)
+	if err != nil {
+		t.Fatalf("error writing text: %v", err)
+	}
+	if want := "** No Match found **\n"; buf.String() != want {
+		t.Fatalf("invalid text output.\nwant: %q\n got: %q\n", want, buf.String())
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	} {
+		if got := humanSize(table.n); got != table.want {
+			t.Errorf("humanSize(%d): want %q. got %q", table.n, table.want, got)
+		}
+	}
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	err = checkDiskSpace([]diskRequirement{{dir: tmpdir, size: 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// two requirements on the same file system add up
+	const huge = 1 << 61
+	err = checkDiskSpace([]diskRequirement{
+		{dir: tmpdir, size: huge},
+		{dir: tmpdir, size: huge},
+	})
+	if err == nil {
+		t.Fatalf("expected a disk space error")
+	}
+	if !strings.Contains(err.Error(), "short by") ||
+		!strings.Contains(err.Error(), "need 4.0 EiB") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+
+	err = checkDiskSpace([]diskRequirement{{dir: filepath.Join(tmpdir, "not-there"), size: 1}})
+	if err == nil {
+		t.Fatalf("expected an error for a missing directory")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		yes   bool
+		no    bool
+		tty   bool
+		input string
+		want  error
+	}{
+		{tty: false, want: nil},
+		{tty: false, no: true, want: errAborted},
+		{tty: true, yes: true, want: nil},
+		{tty: true, no: true, input: "y\n", want: errAborted},
+		{tty: true, input: "y\n", want: nil},
+		{tty: true, input: "YES\n", want: nil},
+		{tty: true, input: "\n", want: errAborted},
+		{tty: true, input: "n\n", want: errAborted},
+		{tty: true, input: "maybe\nyes\n", want: nil},
+		{tty: true, input: "", want: errAborted},
+	} {
+		out := new(bytes.Buffer)
+		ctx := &Context{msg: logger.NewLogger("lbpkr", logger.INFO, out)}
+		ctx.prompt.yes = table.yes
+		ctx.prompt.no = table.no
+		ctx.prompt.tty = table.tty
+		ctx.prompt.stdin = bufio.NewReader(strings.NewReader(table.input))
+		ctx.prompt.stdout = out
+
+		err := ctx.confirm("is this ok?")
+		if err != table.want {
+			t.Errorf("confirm(yes=%v, no=%v, tty=%v, input=%q): want %v. got %v",
+				table.yes, table.no, table.tty, table.input, table.want, err,
+			)
+		}
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		v    string
+		want int64
+	}{
+		{"1024", 1024},
+		{"2K", 2048},
+		{"1.5M", 3 << 19},
+		{"2G", 2 << 30},
+		{"2GiB", 2 << 30},
+	} {
+		got, err := parseSize(table.v)
+		if err != nil || got != table.want {
+			t.Errorf("parseSize(%q): want %d. got %d (err=%v)", table.v, table.want, got, err)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Errorf("expected an error parsing an invalid size")
+	}
+
+	for _, table := range []struct {
+		v    string
+		want time.Duration
+	}{
+		{"30", 30 * 24 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+	} {
+		got, err := parseAge(table.v)
+		if err != nil || got != table.want {
+			t.Errorf("parseAge(%q): want %v. got %v (err=%v)", table.v, table.want, got, err)
+		}
+	}
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	now := time.Now()
+	for i, name := range []string{"a.rpm", "b.rpm", "c.rpm", "d.rpm", "not-an-rpm.txt"} {
+		fname := filepath.Join(tmpdir, name)
+		err = ioutil.WriteFile(fname, make([]byte, 100), 0644)
+		if err != nil {
+			t.Fatalf("could not create %s: %v", name, err)
+		}
+		mtime := now.Add(-time.Duration(10-i) * 24 * time.Hour)
+		err = os.Chtimes(fname, mtime, mtime)
+		if err != nil {
+			t.Fatalf("could not set mtime of %s: %v", name, err)
+		}
+	}
+
+	// a.rpm is 10 days old, b.rpm 9 days old, ...
+	removed, freed, err := pruneRPMs(tmpdir, 0, 9*24*time.Hour+time.Hour, now)
+	if err != nil {
+		t.Fatalf("error pruning RPMs: %v", err)
+	}
+	if want := []string{"a.rpm"}; !reflect.DeepEqual(removed, want) || freed != 100 {
+		t.Fatalf("pruning by age: want %v. got %v (freed=%d)", want, removed, freed)
+	}
+
+	removed, freed, err = pruneRPMs(tmpdir, 150, 0, now)
+	if err != nil {
+		t.Fatalf("error pruning RPMs: %v", err)
+	}
+	if want := []string{"b.rpm", "c.rpm"}; !reflect.DeepEqual(removed, want) || freed != 200 {
+		t.Fatalf("pruning by size: want %v. got %v (freed=%d)", want, removed, freed)
+	}
+
+	if !path_exists(filepath.Join(tmpdir, "d.rpm")) || !path_exists(filepath.Join(tmpdir, "not-an-rpm.txt")) {
+		t.Fatalf("pruning removed too many files")
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	fname := filepath.Join(tmpdir, "data.txt")
+	err = ioutil.WriteFile(fname, []byte("hello\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatalf("could not stat file: %v", err)
+	}
+
+	link := filepath.Join(tmpdir, "link.txt")
+	err = os.Symlink("data.txt", link)
+	if err != nil {
+		t.Fatalf("could not create symlink: %v", err)
+	}
+
+	const (
+		md5sum    = "b1946ac92492d2347c6235b4d2611184"
+		sha256sum = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	)
+
+	ref := rpmFile{
+		Name:   fname,
+		Size:   6,
+		Mode:   syscall.S_IFREG | 0644,
+		Digest: sha256sum,
+		Algo:   8,
+		MTime:  fi.ModTime().Unix(),
+	}
+
+	for i, table := range []struct {
+		f       func(f rpmFile) rpmFile
+		codes   string
+		missing bool
+	}{
+		{
+			f:     func(f rpmFile) rpmFile { return f },
+			codes: ".........",
+		},
+		{
+			f:     func(f rpmFile) rpmFile { f.Algo = 1; f.Digest = md5sum; return f },
+			codes: ".........",
+		},
+		{
+			f:     func(f rpmFile) rpmFile { f.Size = 7; f.Digest = md5sum; return f },
+			codes: "S.5......",
+		},
+		{
+			f:     func(f rpmFile) rpmFile { f.Mode = syscall.S_IFREG | 0755; f.MTime++; return f },
+			codes: ".M.....T.",
+		},
+		{
+			f:     func(f rpmFile) rpmFile { f.Flags = rpmFileGhost; f.Name += ".ghost"; return f },
+			codes: ".........",
+		},
+		{
+			f:       func(f rpmFile) rpmFile { f.Name += ".missing"; return f },
+			codes:   ".........",
+			missing: true,
+		},
+		{
+			f: func(f rpmFile) rpmFile {
+				return rpmFile{Name: link, Mode: syscall.S_IFLNK | 0777, LinkTo: "other.txt"}
+			},
+			codes: "....L....",
+		},
+	} {
+		codes, missing := verifyFile(table.f(ref))
+		if codes != table.codes || missing != table.missing {
+			t.Errorf("test #%d: want codes=%q missing=%v. got codes=%q missing=%v",
+				i, table.codes, table.missing, codes, missing,
+			)
+		}
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	t.Parallel()
+
+	f, err := ioutil.TempFile("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("hello\n")
+	if err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	f.Close()
+
+	for _, table := range []struct {
+		typ  string
+		want string
+	}{
+		{"md5", "b1946ac92492d2347c6235b4d2611184"},
+		{"sha", "f572d396fae9206628714fb2ce00f72e94f2258f"},
+		{"sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+	} {
+		got, err := fileChecksum(f.Name(), table.typ)
+		if err != nil || got != table.want {
+			t.Errorf("fileChecksum(%q): want %q. got %q (err=%v)", table.typ, table.want, got, err)
+		}
+	}
+
+	if _, err := fileChecksum(f.Name(), "crc32"); err == nil {
+		t.Errorf("expected an error for an unsupported checksum type")
+	}
+}
+
+func TestLbpkrDowngrade(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "test-lbpkr-")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// install a recent version
+	{
+		cmd := newCommand("lbpkr", "install", "-siteroot="+tmpdir, "lbpkr-0.1.20140623-0")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Dir = tmpdir
+
+		err = cmd.Run()
+		if err != nil {
+			t.Fatalf("error running install: %v", err)
+		}
+	}
+
+	// downgrade to an older one
+	{
+		cmd := newCommand("lbpkr", "downgrade", "-siteroot="+tmpdir, "-y", "lbpkr", "0.1.20140620-0")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Dir = tmpdir
+
+		err = cmd.Run()
+		if err != nil {
+			t.Fatalf("error running downgrade: %v", err)
+		}
+	}
+
+	{
+		cmd := newCommand("lbpkr", "installed", "-siteroot="+tmpdir, "lbpkr")
+		cmd.Stderr = os.Stderr
+		cmd.Dir = tmpdir
+
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("error running installed: %v", err)
+		}
+		if want := "lbpkr-0.1.20140620-0\n"; string(out) != want {
+			t.Fatalf("invalid installed packages.\nwant: %q\n got: %q\n", want, string(out))
+		}
+	}
+}
+
+func TestProjectPattern(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		project string
+		name    string
+		want    bool
+	}{
+		{"GAUDI", "GAUDI_v25r5_x86_64_slc6_gcc48_opt", true},
+		{"GAUDI", "GAUDI_v25r5_index", true},
+		{"GAUDI", "GAUDIPARTPROP_v1r0", false},
+		{"GAUDI", "LHCB_v37r3_x86_64_slc6_gcc48_opt", false},
+		{"GAUDI_v25r5", "GAUDI_v25r5_x86_64_slc6_gcc48_dbg", true},
+		{"GAUDI_v25r5", "GAUDI_v25r6_x86_64_slc6_gcc48_dbg", false},
+	} {
+		re := regexp.MustCompile(projectPattern(table.project))
+		if got := re.MatchString(table.name); got != table.want {
+			t.Errorf("project=%q name=%q: want %v. got %v", table.project, table.name, table.want, got)
+		}
+	}
+}
+
+func TestAdvisoryFilter(t *testing.T) {
+	t.Parallel()
+
+	sec := &yum.Advisory{ID: "LHCBSA-2015:0001", Type: "security", Severity: "Critical"}
+	bug := &yum.Advisory{ID: "LHCBBA-2015:0002", Type: "bugfix"}
+
+	for _, table := range []struct {
+		filter advisoryFilter
+		sec    bool
+		bug    bool
+	}{
+		{advisoryFilter{}, true, true},
+		{advisoryFilter{security: true}, true, false},
+		{advisoryFilter{severity: "critical"}, true, false},
+		{advisoryFilter{severity: "Low"}, false, false},
+		{advisoryFilter{ids: splitAdvisories("LHCBBA-2015:0002")}, false, true},
+		{advisoryFilter{ids: splitAdvisories(" LHCBSA-2015:0001, LHCBBA-2015:0002,")}, true, true},
+		{advisoryFilter{security: true, ids: []string{"LHCBBA-2015:0002"}}, false, false},
+	} {
+		if got := table.filter.match(sec); got != table.sec {
+			t.Errorf("filter=%+v: %s: want %v. got %v", table.filter, sec.ID, table.sec, got)
+		}
+		if got := table.filter.match(bug); got != table.bug {
+			t.Errorf("filter=%+v: %s: want %v. got %v", table.filter, bug.ID, table.bug, got)
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		platform string
+		want     string
+	}{
+		{"x86_64_slc6_gcc48_opt", "x86_64-slc6-gcc48-opt"},
+		{"i686_slc5_gcc43_dbg", "i686-slc5-gcc43-dbg"},
+		{"x86_64-slc6-gcc48-opt", "x86_64-slc6-gcc48-opt"},
+	} {
+		if got := cmtconfig(table.platform); got != table.want {
+			t.Errorf("cmtconfig(%q): want %q. got %q", table.platform, table.want, got)
+		}
+	}
+
+	for _, table := range []struct {
+		file rpmFile
+		name string
+		dir  string
+	}{
+		{rpmFile{Name: "/sw/GAUDI/InstallArea/bin/gaudirun.py", Mode: 0100755}, "PATH", "/sw/GAUDI/InstallArea/bin"},
+		{rpmFile{Name: "/sw/GAUDI/InstallArea/bin/README", Mode: 0100644}, "", ""},
+		{rpmFile{Name: "/sw/GAUDI/InstallArea/lib/libGaudiKernel.so", Mode: 0100755}, "LD_LIBRARY_PATH", "/sw/GAUDI/InstallArea/lib"},
+		{rpmFile{Name: "/sw/GAUDI/InstallArea/python/Gaudi/Main.py", Mode: 0100644}, "PYTHONPATH", "/sw/GAUDI/InstallArea/python"},
+		{rpmFile{Name: "/sw/lcg/lib/python2.7/site-packages/six.py", Mode: 0100644}, "PYTHONPATH", "/sw/lcg/lib/python2.7/site-packages"},
+		{rpmFile{Name: "/sw/GAUDI/doc/index.html", Mode: 0100644}, "", ""},
+	} {
+		name, dir := envPathFor(table.file)
+		if name != table.name || dir != table.dir {
+			t.Errorf("%s: want (%q, %q). got (%q, %q)", table.file.Name, table.name, table.dir, name, dir)
+		}
+	}
+
+	env := &Environment{
+		Project:  "GAUDI",
+		Version:  "v25r5",
+		Platform: "x86_64_slc6_gcc48_opt",
+		Vars: []EnvVar{
+			{Name: "CMTCONFIG", Value: "x86_64-slc6-gcc48-opt"},
+			{Name: "PATH", Paths: []string{"/sw/a/bin", "/sw/b/bin"}},
+		},
+	}
+
+	for _, table := range []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{
+			"export CMTCONFIG='x86_64-slc6-gcc48-opt'\n",
+			`export PATH='/sw/a/bin:/sw/b/bin'"${PATH:+:$PATH}"` + "\n",
+		}},
+		{"csh", []string{
+			"setenv CMTCONFIG 'x86_64-slc6-gcc48-opt'\n",
+			`  setenv PATH '/sw/a/bin:/sw/b/bin':"${PATH}"` + "\n",
+		}},
+		{"fish", []string{
+			"set -gx PATH '/sw/a/bin' '/sw/b/bin' $PATH\n",
+		}},
+		{"lmod", []string{
+			`setenv("CMTCONFIG", "x86_64-slc6-gcc48-opt")` + "\n",
+			`prepend_path("PATH", "/sw/b/bin")` + "\n" + `prepend_path("PATH", "/sw/a/bin")` + "\n",
+		}},
+		{"tcl", []string{
+			"#%Module1.0\n",
+			"prepend-path PATH {/sw/b/bin}\nprepend-path PATH {/sw/a/bin}\n",
+		}},
+	} {
+		buf := new(bytes.Buffer)
+		err := env.Write(buf, table.shell)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", table.shell, err)
+			continue
+		}
+		for _, want := range table.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: missing %q in:\n%s", table.shell, want, buf.String())
+			}
+		}
+	}
+
+	err := env.Write(new(bytes.Buffer), "cmd.exe")
+	if err == nil {
+		t.Errorf("expected an error for an unknown shell")
+	}
+
+	if got := shQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("shQuote: got %q", got)
+	}
+}
+
+func TestFindRequireChains(t *testing.T) {
+	t.Parallel()
+
+	// LHCB requires GAUDI and ROOT, GAUDI requires ROOT,
+	// BRUNEL requires LHCB, CYCLE-A and CYCLE-B require each other
+	parents := map[string][]string{
+		"GAUDI":   {"LHCB"},
+		"LHCB":    {"BRUNEL"},
+		"ROOT":    {"GAUDI", "LHCB"},
+		"CYCLE-A": {"CYCLE-B"},
+		"CYCLE-B": {"CYCLE-A"},
+	}
+
+	for _, table := range []struct {
+		name string
+		max  int
+		want [][]string
+	}{
+		{"BRUNEL", 0, [][]string{{"BRUNEL"}}},
+		{"GAUDI", 0, [][]string{{"BRUNEL", "LHCB", "GAUDI"}}},
+		{"ROOT", 0, [][]string{
+			{"BRUNEL", "LHCB", "GAUDI", "ROOT"},
+			{"BRUNEL", "LHCB", "ROOT"},
+		}},
+		{"ROOT", 1, [][]string{{"BRUNEL", "LHCB", "GAUDI", "ROOT"}}},
+		{"CYCLE-A", 0, nil},
+	} {
+		got, _ := findRequireChains(parents, table.name, table.max)
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("%s (max=%d): want %v. got %v", table.name, table.max, table.want, got)
+		}
+	}
+
+	// a stack of diamonds: PKG-i is required by L-i and R-i, both required
+	// by PKG-(i+1). there are 2^n chains down to PKG-0.
+	const n = 24
+	diamonds := make(map[string][]string)
+	for i := 0; i < n; i++ {
+		pkg := fmt.Sprintf("PKG-%d", i)
+		l, r := fmt.Sprintf("L-%d", i), fmt.Sprintf("R-%d", i)
+		diamonds[pkg] = []string{l, r}
+		diamonds[l] = []string{fmt.Sprintf("PKG-%d", i+1)}
+		diamonds[r] = []string{fmt.Sprintf("PKG-%d", i+1)}
+	}
+
+	for _, table := range []struct {
+		name      string
+		max       int
+		want      int
+		truncated bool
+	}{
+		{"PKG-22", 0, 4, false},
+		{"PKG-22", 4, 4, false},
+		{"PKG-22", 3, 3, true},
+		{"PKG-0", 20, 20, true},
+	} {
+		got, truncated := findRequireChains(diamonds, table.name, table.max)
+		if len(got) != table.want || truncated != table.truncated {
+			t.Errorf("%s (max=%d): want %d chains (truncated=%v). got %d chains (truncated=%v)",
+				table.name, table.max, table.want, table.truncated, len(got), truncated,
+			)
+		}
+		for _, chain := range got {
+			if chain[0] != fmt.Sprintf("PKG-%d", n) || chain[len(chain)-1] != table.name {
+				t.Errorf("%s (max=%d): invalid chain %v", table.name, table.max, chain)
+			}
+		}
+	}
+}
+
+func TestChangeLogSince(t *testing.T) {
+	t.Parallel()
+
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("invalid date %q: %v", s, err)
+		}
+		return d
+	}
+
+	// newest first
+	entries := []yum.ChangeLogEntry{
+		{Author: "lhcb-release@cern.ch", Date: day("2015-03-01"), Text: "- rebuilt"},
+		{Author: "Jane Doe <jane.doe@cern.ch> - 1.0.0-3", Date: day("2015-02-01"), Text: "- fix 3"},
+		{Author: "Jane Doe <jane.doe@cern.ch> - 1.0.0-2", Date: day("2015-01-01"), Text: "- fix 2"},
+		{Author: "Jane Doe <jane.doe@cern.ch> - 1.0.0-1", Date: day("2014-12-01"), Text: "- first release"},
+	}
+
+	for _, table := range []struct {
+		since string
+		want  int
+	}{
+		{"1.0.0-2", 2},
+		{"1.0.0-1", 3},
+		{"0:1.0.0-3", 1},
+		{"0.9", 4},
+		{"2015-01-15", 2},
+		{"2016-01-01", 0},
+	} {
+		rpm, date, err := parseChangeLogSince("LHCB", table.since)
+		if err != nil {
+			t.Fatalf("since=%q: %v", table.since, err)
+		}
+		got := changelogSince(entries, "LHCB", rpm, date)
+		if len(got) != table.want {
+			t.Errorf("since=%q: want %d entries. got %d (%v)", table.since, table.want, len(got), got)
+		}
+	}
+
+	if _, _, err := parseChangeLogSince("LHCB", "-1"); err == nil {
+		t.Errorf("expected an error for an invalid -since value")
+	}
+}
+
+func TestFileTree(t *testing.T) {
+	t.Parallel()
+
+	files := &PackageFiles{
+		Package:   "TestPackage-1.2.5-1",
+		Installed: true,
+		Size:      3072,
+		Files: []FileInfo{
+			{Name: "/sw/TestPackage", Type: "dir"},
+			{Name: "/sw/TestPackage/README", Type: "file", Size: 1024},
+			{Name: "/sw/TestPackage/bin", Type: "dir"},
+			{Name: "/sw/TestPackage/bin/test", Type: "file", Size: 2048},
+			{Name: "/sw/TestPackage/bin/test2", Type: "link", LinkTo: "test"},
+		},
+	}
+
+	for _, table := range []struct {
+		r    textWriter
+		want string
+	}{
+		{
+			r: fileTree{files, false},
+			want: `/sw/TestPackage
+├── README
+└── bin
+    ├── test
+    └── test2 -> test
+`,
+		},
+		{
+			r: fileTree{files, true},
+			want: `[3.0 KiB] /sw/TestPackage
+├── [1.0 KiB] README
+└── [2.0 KiB] bin
+    ├── [2.0 KiB] test
+    └── [0 B] test2 -> test
+`,
+		},
+		{
+			r: fileList{files, true},
+			want: `       0 B  /sw/TestPackage
+   1.0 KiB  /sw/TestPackage/README
+       0 B  /sw/TestPackage/bin
+   2.0 KiB  /sw/TestPackage/bin/test
+       0 B  /sw/TestPackage/bin/test2 -> test
+   3.0 KiB  total (TestPackage-1.2.5-1)
+`,
+		},
+	} {
+		buf := new(bytes.Buffer)
+		err := table.r.writeText(buf)
+		if err != nil {
+			t.Fatalf("error rendering %T: %v", table.r, err)
+		}
+		if got := buf.String(); got != table.want {
+			t.Errorf("%T: want:\n%s\ngot:\n%s", table.r, table.want, got)
+		}
+	}
+}
+
+func TestDepGraph(t *testing.T) {
+	t.Parallel()
+
+	g := newDepGraph()
+	for _, n := range []*DepNode{
+		{ID: "BRUNEL-1.0.0-1", Name: "BRUNEL", Repository: "lhcb", Installed: true},
+		{ID: "GAUDI-1.0.0-1", Name: "GAUDI", Repository: "lhcb", Installed: true},
+		{ID: "LHCB-1.0.0-1", Name: "LHCB", Repository: "lhcb", Installed: true},
+		{ID: "ROOT-1.0.0-1", Name: "ROOT", Repository: "lcg"},
+	} {
+		g.addNode(n)
+	}
+	g.addNode(&DepNode{ID: "AIDA-3", Name: "AIDA", Version: "3", Missing: true})
+
+	g.addEdge("BRUNEL-1.0.0-1", "LHCB-1.0.0-1")
+	g.addEdge("LHCB-1.0.0-1", "GAUDI-1.0.0-1")
+	g.addEdge("GAUDI-1.0.0-1", "LHCB-1.0.0-1")
+	g.addEdge("GAUDI-1.0.0-1", "ROOT-1.0.0-1")
+	g.addEdge("ROOT-1.0.0-1", "AIDA-3").Missing = true
+	g.markCycles()
+	g.sort()
+
+	cycles := make([]string, 0)
+	for _, n := range g.Nodes {
+		if n.Cycle {
+			cycles = append(cycles, n.ID)
+		}
+	}
+	if want := []string{"GAUDI-1.0.0-1", "LHCB-1.0.0-1"}; !reflect.DeepEqual(cycles, want) {
+		t.Fatalf("cycles: want %v. got %v", want, cycles)
+	}
+
+	buf := new(bytes.Buffer)
+	err := g.Write(buf, "mermaid", "repository")
+	if err != nil {
+		t.Fatalf("error writing mermaid graph: %v", err)
+	}
+	want := `graph LR
+  n0["AIDA-3"]
+  subgraph c1["lcg"]
+    n4["ROOT-1.0.0-1"]
+  end
+  subgraph c2["lhcb"]
+    n1["BRUNEL-1.0.0-1"]
+    n2["GAUDI-1.0.0-1"]
+    n3["LHCB-1.0.0-1"]
+  end
+  n1 --> n3
+  n2 --> n3
+  n2 --> n4
+  n3 --> n2
+  n4 -.-> n0
+  classDef missing stroke:orange,stroke-dasharray:5 5
+  class n0 missing
+  classDef cycle stroke:red
+  class n2,n3 cycle
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("mermaid graph: want:\n%s\ngot:\n%s", want, got)
+	}
+
+	for _, format := range GraphFormats {
+		err := g.Write(new(bytes.Buffer), format, "platform")
+		if err != nil {
+			t.Errorf("error writing %s graph: %v", format, err)
+		}
+	}
+	if err := g.Write(new(bytes.Buffer), "dot", "arch"); err == nil {
+		t.Errorf("expected an error for an unknown cluster")
+	}
+
+	for _, table := range []struct {
+		format string
+		fname  string
+		want   string
+	}{
+		{"", "graph.dot", "dot"},
+		{"", "graph.GraphML", "graphml"},
+		{"", "graph.json", "json"},
+		{"", "graph.mmd", "mermaid"},
+		{"", "-", "dot"},
+		{"json", "graph.dot", "json"},
+	} {
+		got, err := graphFormat(table.format, table.fname)
+		if err != nil || got != table.want {
+			t.Errorf("graphFormat(%q, %q): want %q. got %q (err=%v)", table.format, table.fname, table.want, got, err)
+		}
+	}
+	if _, err := graphFormat("png", "graph.png"); err == nil {
+		t.Errorf("expected an error for an unknown graph format")
+	}
+}
+
+func TestDiffInstalled(t *testing.T) {
+	t.Parallel()
+
+	old := [][3]string{
+		{"BRUNEL_v46r0", "1.0.0", "1"},
+		{"GAUDI_v25r2", "1.0.0", "1"},
+		{"LBSCRIPTS", "8.2.0", "1"},
+		{"LBSCRIPTS", "8.3.0", "1"},
+		{"ROOT_5.34.18", "1.0.0", "1"},
+	}
+	cur := [][3]string{
+		{"GAUDI_v25r2", "1.0.0", "2"},
+		{"LBSCRIPTS", "8.3.0", "1"},
+		{"LBSCRIPTS", "8.4.0", "1"},
+		{"LHCB_v37r3", "1.0.0", "1"},
+		{"ROOT_5.34.18", "1.0.0", "1"},
+	}
+
+	diff := diffInstalled(old, cur)
+	want := &SiterootDiff{
+		Added:          []PackageChange{{Name: "LHCB_v37r3", New: "1.0.0-1"}},
+		Removed:        []PackageChange{{Name: "BRUNEL_v46r0", Old: "1.0.0-1"}},
+		VersionChanged: []PackageChange{{Name: "LBSCRIPTS", Old: "8.2.0-1", New: "8.4.0-1"}},
+		ReleaseChanged: []PackageChange{{Name: "GAUDI_v25r2", Old: "1.0.0-1", New: "1.0.0-2"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("want:\n%#v\ngot:\n%#v", want, diff)
+	}
+
+	if diff := diffInstalled(old, old); !diff.Empty() {
+		t.Fatalf("expected no difference. got %#v", diff)
+	}
+
+	pkgs, err := parseInstalledPackages([]byte("GAUDI_v25r2 1.0.0 2\nLBSCRIPTS 8.3.0 1\n"))
+	if err != nil {
+		t.Fatalf("error parsing rpm output: %v", err)
+	}
+	if want := cur[:2]; !reflect.DeepEqual(pkgs, want) {
+		t.Fatalf("installed packages: want %v. got %v", want, pkgs)
+	}
+
+	// compared siteroots are only read
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if _, err := DiffSiteroots(tmpdir, tmpdir); err == nil {
+		t.Fatalf("expected an error for a siteroot without RPM database")
+	}
+	if files, err := ioutil.ReadDir(tmpdir); err != nil || len(files) != 0 {
+		t.Fatalf("siteroot modified by diff: %v (err=%v)", files, err)
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	want := [][3]string{
+		{"GAUDI_v25r2_x86_64_slc6_gcc48_opt", "1.0.0", "1"},
+		{"LBSCRIPTS", "8.3.0", "2"},
+	}
+
+	for _, table := range []struct {
+		name string
+		data string
+	}{
+		{
+			name: "snapshot.txt",
+			data: `GAUDI_v25r2_x86_64_slc6_gcc48_opt-1.0.0-1
+LBSCRIPTS-8.3.0-2
+lbpkr INFO    Total matching: 2
+`,
+		},
+		{
+			name: "snapshot.json",
+			data: `[
+  {"name": "GAUDI_v25r2_x86_64_slc6_gcc48_opt", "version": "1.0.0", "release": "1", "epoch": "0"},
+  {"name": "LBSCRIPTS", "version": "8.3.0", "release": "2", "epoch": "0"}
+]`,
+		},
+	} {
+		fname := filepath.Join(tmpdir, table.name)
+		err = ioutil.WriteFile(fname, []byte(table.data), 0644)
+		if err != nil {
+			t.Fatalf("error writing %s: %v", fname, err)
+		}
+		got, err := readSnapshot(fname)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", table.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: want %v. got %v", table.name, want, got)
+		}
+	}
+
+	fname := filepath.Join(tmpdir, "invalid.txt")
+	err = ioutil.WriteFile(fname, []byte("GAUDI\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing %s: %v", fname, err)
+	}
+	if _, err := readSnapshot(fname); err == nil {
+		t.Errorf("expected an error for an invalid snapshot")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	want := &Manifest{
+		Siteroot: "/opt/LHCbSoft",
+		Date:     time.Date(2015, 4, 1, 12, 0, 0, 0, time.UTC),
+		Lbpkr:    Version,
+		Repositories: []RepoInfo{
+			{Name: "lhcb", URL: "http://lhcbproject.web.cern.ch/lhcbproject/dist/rpm/lhcb", Enabled: true},
+		},
+		Packages: []ManifestPackage{
+			{
+				Name: "GAUDI_v25r2_x86_64_slc6_gcc48_opt", Epoch: "0", Version: "1.0.0", Release: "1",
+				Arch: "noarch", Repository: "lhcb",
+				URL:          "http://lhcbproject.web.cern.ch/lhcbproject/dist/rpm/lhcb/GAUDI_v25r2_x86_64_slc6_gcc48_opt-1.0.0-1.noarch.rpm",
+				ChecksumType: "sha256", Checksum: "0123456789abcdef",
+			},
+			{Name: "LBSCRIPTS", Version: "8.3.0", Release: "2"},
+		},
+	}
+
+	fname := filepath.Join(tmpdir, "manifest.json")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatalf("error creating %s: %v", fname, err)
+	}
+	err = WriteManifest(f, want)
+	if err != nil {
+		t.Fatalf("error writing manifest: %v", err)
+	}
+	f.Close()
+
+	got, err := ReadManifest(fname)
+	if err != nil {
+		t.Fatalf("error reading manifest: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("manifest round-trip:\nwant %#v\ngot  %#v", want, got)
+	}
+	if id := got.Packages[1].ID(); id != "LBSCRIPTS-8.3.0-2" {
+		t.Errorf("want ID LBSCRIPTS-8.3.0-2. got %q", id)
+	}
+
+	err = ioutil.WriteFile(fname, []byte("LBSCRIPTS-8.3.0-2\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing %s: %v", fname, err)
+	}
+	if _, err := ReadManifest(fname); err == nil {
+		t.Errorf("expected an error for an invalid manifest")
+	}
+}
+
+func TestVersionRange(t *testing.T) {
+	t.Parallel()
+
+	for _, table := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"v25r2", "v25r2", 0},
+		{"v9r1", "v10r0", -1},
+		{"v25r2", "v25r2p1", -1},
+		{"v26r0", "v25r9", 1},
+		{"1.0.10", "1.0.9", 1},
+		{"8.3.0", "8.3", 1},
+		{"1.0a", "1.0.1", -1},
+	} {
+		if cmp := compareVersions(table.a, table.b); cmp != table.cmp {
+			t.Errorf("compareVersions(%q, %q): want %d. got %d", table.a, table.b, table.cmp, cmp)
+		}
+		if cmp := compareVersions(table.b, table.a); cmp != -table.cmp {
+			t.Errorf("compareVersions(%q, %q): want %d. got %d", table.b, table.a, -table.cmp, cmp)
+		}
+	}
+
+	r, err := parseVersionRange(">=v25r0, <v26r0, !=v25r1")
+	if err != nil {
+		t.Fatalf("error parsing range: %v", err)
+	}
+	for v, want := range map[string]bool{
+		"v24r9":   false,
+		"v25r0":   true,
+		"v25r1":   false,
+		"v25r2p1": true,
+		"v26r0":   false,
+	} {
+		if got := r.Contains(v); got != want {
+			t.Errorf("range %s contains %s: want %v. got %v", r, v, want, got)
+		}
+	}
+
+	r, err = parseVersionRange("v25r2")
+	if err != nil {
+		t.Fatalf("error parsing range: %v", err)
+	}
+	if !r.Contains("v25r2") || r.Contains("v25r3") {
+		t.Errorf("range %s: invalid exact match", r)
+	}
+	if _, err := parseVersionRange(">=v25r0,"); err == nil {
+		t.Errorf("expected an error for an invalid range")
+	}
+}
+
+func TestReadSite(t *testing.T) {
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	fname := filepath.Join(tmpdir, "site.yaml")
+	err = ioutil.WriteFile(fname, []byte(`
+repositories:
+  - name: lhcb
+    url: http://lhcbproject.web.cern.ch/lhcbproject/dist/rpm/lhcb
+projects:
+  - name: GAUDI
+    versions: ">=v25r0, <v26r0"
+    platforms: [x86_64-slc6-gcc48-opt]
+rpms:
+  - name: LBSCRIPTS
+    versions: ">=8.3.0"
+locks:
+  - name: ROOT_5.34.18_x86_64_slc6_gcc48_opt
+    version: 1.0.0
+    release: "1"
+prune: true
+`), 0644)
+	if err != nil {
+		t.Fatalf("error writing %s: %v", fname, err)
+	}
+
+	site, err := ReadSite(fname)
+	if err != nil {
+		t.Fatalf("error reading site: %v", err)
+	}
+	want := &Site{
+		Repositories: []SiteRepository{{Name: "lhcb", URL: "http://lhcbproject.web.cern.ch/lhcbproject/dist/rpm/lhcb"}},
+		Projects:     []SiteProject{{Name: "GAUDI", Versions: ">=v25r0, <v26r0", Platforms: []string{"x86_64-slc6-gcc48-opt"}}},
+		RPMs:         []SiteRPM{{Name: "LBSCRIPTS", Versions: ">=8.3.0"}},
+		Locks:        []SiteLock{{Name: "ROOT_5.34.18_x86_64_slc6_gcc48_opt", Version: "1.0.0", Release: "1"}},
+		Prune:        true,
+	}
+	if !reflect.DeepEqual(site, want) {
+		t.Fatalf("site:\nwant %#v\ngot  %#v", want, site)
+	}
+
+	for _, data := range []string{
+		"projects:\n  - name: GAUDI\n    version: v25r2\n", // unknown field
+		"rpms:\n  - name: LBSCRIPTS\n    versions: '>=8.3.0,'\n",
+		"rpms:\n  - name: ROOT\nlocks:\n  - name: ROOT\n    version: 1.0.0\n",
+		"repositories:\n  - name: lhcb\n",
+	} {
+		err = ioutil.WriteFile(fname, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("error writing %s: %v", fname, err)
+		}
+		if _, err := ReadSite(fname); err == nil {
+			t.Errorf("expected an error for site:\n%s", data)
+		}
+	}
+}
+
+func TestGenericConfig(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	siteroot := filepath.Join(tmpdir, "siteroot")
+	err = os.MkdirAll(filepath.Join(siteroot, "etc"), 0755)
+	if err != nil {
+		t.Fatalf("error creating siteroot: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(siteroot, "etc", "experiment.cfg"), []byte(`
+[experiment]
+name = myexp
+siteroot = /opt/myexp
+repourl = http://example.org/rpm
+relocate = /opt/myexp/ext=externals, /opt/myexp=., /opt/lcg=/cvmfs/lcg
+rpmupdate = true
+
+[repo.myexp]
+url = http://example.org/rpm/myexp
+
+[repo.ext]
+url = http://example.org/rpm/ext
+`), 0644)
+	if err != nil {
+		t.Fatalf("error writing experiment file: %v", err)
+	}
+
+	cfg, err := NewConfigType("generic", siteroot)
+	if err != nil {
+		t.Fatalf("error creating generic config: %v", err)
+	}
+	if got, want := cfg.Name(), "myexp"; got != want {
+		t.Errorf("name: want %q. got %q", want, got)
+	}
+	if got, want := cfg.DefaultSiteroot(), "/opt/myexp"; got != want {
+		t.Errorf("default siteroot: want %q. got %q", want, got)
+	}
+	if got, want := cfg.Siteroot(), siteroot; got != want {
+		t.Errorf("siteroot: want %q. got %q", want, got)
+	}
+	if !cfg.RpmUpdate() || cfg.Debug() {
+		t.Errorf("want rpmupdate=true, debug=false. got rpmupdate=%v, debug=%v", cfg.RpmUpdate(), cfg.Debug())
+	}
+
+	for _, table := range []struct {
+		fname string
+		want  string
+	}{
+		{"/opt/myexp/ext/lib/libfoo.so", siteroot + "/externals/lib/libfoo.so"},
+		{"/opt/myexp/bin/foo", siteroot + "/bin/foo"},
+		{"/opt/myexpfoo/bin/foo", "/opt/myexpfoo/bin/foo"},
+		{"/opt/lcg/ROOT", "/cvmfs/lcg/ROOT"},
+		{"/usr/bin/foo", "/usr/bin/foo"},
+	} {
+		if got := cfg.Relocations().Relocate(table.fname); got != table.want {
+			t.Errorf("relocate %q: want %q. got %q", table.fname, table.want, got)
+		}
+	}
+
+	wantargs := []string{
+		"--relocate", "/opt/myexp/ext=" + siteroot + "/externals",
+		"--relocate", "/opt/myexp=" + siteroot,
+		"--relocate", "/opt/lcg=/cvmfs/lcg",
+		"--badreloc",
+	}
+	if got := cfg.Relocations().Args(); !reflect.DeepEqual(got, wantargs) {
+		t.Errorf("relocate args:\nwant %q\ngot  %q", wantargs, got)
+	}
+
+	repos := cfg.(*genericConfig).repos
+	if want := [][2]string{{"ext", "http://example.org/rpm/ext"}, {"myexp", "http://example.org/rpm/myexp"}}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("repositories: want %v. got %v", want, repos)
+	}
+
+	if _, err := NewConfigType("no-such-type", siteroot); err == nil {
+		t.Errorf("expected an error for an unknown config type")
+	}
+	if _, err := NewConfigType("generic", tmpdir); err == nil {
+		t.Errorf("expected an error for a siteroot without experiment file")
+	}
+}
+
+func TestRelocations(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseRelocations("/opt/LHCbSoft=., /opt/lcg/=lcg/releases, /opt/lcg/external=/cvmfs/ext", "/sw")
+	if err != nil {
+		t.Fatalf("error parsing relocations: %v", err)
+	}
+	want := Relocations{
+		{Prefix: "/opt/LHCbSoft", Path: "/sw"},
+		{Prefix: "/opt/lcg", Path: "/sw/lcg/releases"},
+		{Prefix: "/opt/lcg/external", Path: "/cvmfs/ext"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("relocations:\nwant %v\ngot  %v", want, rules)
+	}
+
+	for _, table := range []struct {
+		fname string
+		want  string
+	}{
+		// longest prefix wins, whatever the order of the rules
+		{"/opt/lcg/external/Python/2.7.6/bin/python", "/cvmfs/ext/Python/2.7.6/bin/python"},
+		{"/opt/lcg/ROOT/5.34.18/bin/root", "/sw/lcg/releases/ROOT/5.34.18/bin/root"},
+		{"/opt/lcg", "/sw/lcg/releases"},
+		{"/opt/LHCbSoft/lhcb/GAUDI/GAUDI_v25r2", "/sw/lhcb/GAUDI/GAUDI_v25r2"},
+		// only whole path components match
+		{"/opt/lcgfoo/bin/foo", "/opt/lcgfoo/bin/foo"},
+		// prefixes in the middle of a path are left alone
+		{"/data/opt/lcg/foo", "/data/opt/lcg/foo"},
+	} {
+		if got := rules.Relocate(table.fname); got != table.want {
+			t.Errorf("relocate %q: want %q. got %q", table.fname, table.want, got)
+		}
+	}
+
+	wantargs := []string{
+		"--relocate", "/opt/LHCbSoft=/sw",
+		"--relocate", "/opt/lcg=/sw/lcg/releases",
+		"--relocate", "/opt/lcg/external=/cvmfs/ext",
+		"--badreloc",
+	}
+	if got := rules.Args(); !reflect.DeepEqual(got, wantargs) {
+		t.Errorf("args:\nwant %q\ngot  %q", wantargs, got)
+	}
+
+	// rpm -q output
+	txt := "/opt/lcg/ROOT/bin/root\n" +
+		"lrwxrwxrwx 1 root root 0 Jan  1 00:00 /opt/LHCbSoft/bin/gaudirun -> /opt/LHCbSoft/lhcb/bin/gaudirun\n" +
+		"URL         : http://lhcb.cern.ch/opt/lcg\n"
+	wanttxt := "/sw/lcg/releases/ROOT/bin/root\n" +
+		"lrwxrwxrwx 1 root root 0 Jan  1 00:00 /sw/bin/gaudirun -> /sw/lhcb/bin/gaudirun\n" +
+		"URL         : http://lhcb.cern.ch/opt/lcg\n"
+	if got := rules.RelocateText(txt); got != wanttxt {
+		t.Errorf("relocate text:\nwant %q\ngot  %q", wanttxt, got)
+	}
+
+	lhcb := NewConfig("/sw").Relocations()
+	if got, want := lhcb.Relocate("/opt/lcg/external/foo"), "/sw/lcg/external/foo"; got != want {
+		t.Errorf("lhcb relocation: want %q. got %q", want, got)
+	}
+
+	for _, s := range []string{
+		"/opt/lcg",
+		"opt/lcg=lcg",
+		"/opt/lcg=lcg, /opt/lcg/=other",
+		"/opt/lcg=",
+	} {
+		if _, err := parseRelocations(s, "/sw"); err == nil {
+			t.Errorf("expected an error for relocations %q", s)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	sysfile := filepath.Join(tmpdir, "system.conf")
+	sitefile := filepath.Join(tmpdir, "siteroot", "etc", "lbpkr.conf")
+	userfile := filepath.Join(tmpdir, "user", "lbpkr", "lbpkr.conf")
+	files := [][2]string{
+		{SystemLevel, sysfile},
+		{SiterootLevel, sitefile},
+		{UserLevel, userfile},
+	}
+
+	err = ioutil.WriteFile(sysfile, []byte(`
+[lbpkr]
+siteroot = /opt/system
+timeout = 10s
+ndls = 2
+`), 0644)
+	if err != nil {
+		t.Fatalf("error writing settings: %v", err)
+	}
+
+	s, err := readSettings(files)
+	if err != nil {
+		t.Fatalf("error reading settings: %v", err)
+	}
+	for _, set := range []struct{ level, name, value string }{
+		{SiterootLevel, "ndls", "4"},
+		{SiterootLevel, "timeout", "20s"},
+		{UserLevel, "ndls", "8"},
+	} {
+		err = s.Set(set.level, set.name, set.value)
+		if err != nil {
+			t.Fatalf("error setting %s=%s (%s): %v", set.name, set.value, set.level, err)
+		}
+	}
+	if err = s.Set(SiterootLevel, "siteroot", "/opt/other"); err == nil {
+		t.Errorf("expected an error setting the siteroot at siteroot level")
+	}
+	if err = s.Set(UserLevel, "ndls", "-1"); err == nil {
+		t.Errorf("expected an error for an invalid ndls value")
+	}
+	if err = s.Set(UserLevel, "relocate", "/opt/lcg=lcg"); err == nil {
+		t.Errorf("expected an error setting the relocation rules at user level")
+	}
+	if err = s.Set(UserLevel, "no-such-setting", "1"); err == nil {
+		t.Errorf("expected an error for an unknown setting")
+	}
+
+	// re-read what was written
+	s, err = readSettings(files)
+	if err != nil {
+		t.Fatalf("error reading settings: %v", err)
+	}
+	os.Unsetenv("LBPKR_NDLS")
+	os.Setenv("LBPKR_TIMEOUT", "1m")
+	defer os.Unsetenv("LBPKR_TIMEOUT")
+
+	for _, table := range []struct {
+		name   string
+		value  string
+		source string
+		ok     bool
+	}{
+		{"ndls", "8", userfile, true},
+		{"timeout", "1m", "$LBPKR_TIMEOUT", true},
+		{"proxy", "", "", false},
+	} {
+		v, src, ok := s.Lookup(table.name)
+		if v != table.value || src != table.source || ok != table.ok {
+			t.Errorf("%s: want (%q, %q, %v). got (%q, %q, %v)",
+				table.name, table.value, table.source, table.ok, v, src, ok,
+			)
+		}
+	}
+
+	// relocation rules only come from the siteroot
+	err = ioutil.WriteFile(userfile, []byte("[lbpkr]\nndls = 8\nrelocate = /opt/lcg=user\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing settings: %v", err)
+	}
+	err = s.Set(SiterootLevel, "relocate", "/opt/lcg=lcg")
+	if err != nil {
+		t.Fatalf("error setting relocation rules: %v", err)
+	}
+	s, err = readSettings(files)
+	if err != nil {
+		t.Fatalf("error reading settings: %v", err)
+	}
+	if v, src, _ := s.Lookup("relocate"); v != "/opt/lcg=lcg" || src != sitefile {
+		t.Errorf("relocate: want (%q, %q). got (%q, %q)", "/opt/lcg=lcg", sitefile, v, src)
+	}
+	if err = checkRelocateChange(filepath.Join(tmpdir, "siteroot")); err != nil {
+		t.Errorf("relocation rules of an empty siteroot should be modifiable: %v", err)
+	}
+
+	// removing the user setting exposes the siteroot one
+	err = s.Set(UserLevel, "ndls", "")
+	if err != nil {
+		t.Fatalf("error removing setting: %v", err)
+	}
+	if v, src, _ := s.Lookup("ndls"); v != "4" || src != sitefile {
+		t.Errorf("ndls: want (%q, %q). got (%q, %q)", "4", sitefile, v, src)
+	}
+
+	// the lhcb config applies the debug and rpmupdate settings
+	os.Setenv("LBPKR_RPMUPDATE", "true")
+	defer os.Unsetenv("LBPKR_RPMUPDATE")
+	cfg, err := NewConfigType("lhcb", filepath.Join(tmpdir, "siteroot"))
+	if err != nil {
+		t.Fatalf("error creating config: %v", err)
+	}
+	if !cfg.RpmUpdate() || cfg.Debug() {
+		t.Errorf("want rpmupdate=true, debug=false. got rpmupdate=%v, debug=%v", cfg.RpmUpdate(), cfg.Debug())
+	}
+	os.Setenv("LBPKR_DEBUG", "maybe")
+	defer os.Unsetenv("LBPKR_DEBUG")
+	if _, err = NewConfigType("lhcb", filepath.Join(tmpdir, "siteroot")); err == nil {
+		t.Errorf("expected an error for an invalid debug value")
+	}
+
+	err = ioutil.WriteFile(sitefile, []byte("[lbpkr]\nformat = xml\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing settings: %v", err)
+	}
+	if _, err = readSettings(files); err == nil {
+		t.Errorf("expected an error for an invalid format value")
+	}
+}
+
+// fakeRPM is a rpm command recording its invocations and reporting a
+// fixed list of installed packages (and of their files).
+type fakeRPM struct {
+	dir string
+}
+
+const fakeRPMScript = `#!/bin/sh
+dir='%s'
+echo "$@" >> "$dir/calls"
+case "$3" in
+-qa)
+	cat "$dir/installed"
+	;;
+-q)
+	if [ "$4" = "--queryformat" ]; then
+		cat "$dir/files-$6" 2>/dev/null
+		exit 0
+	fi
+	awk -v p="$4" '$1==p || $1"-"$2==p || $1"-"$2"-"$3==p {f=1} END {exit !f}' "$dir/installed"
+	;;
+esac
+`
+
+func newFakeRPM(dir string, installed [][3]string) (*fakeRPM, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "rpm"), []byte(fmt.Sprintf(fakeRPMScript, dir)), 0755)
+	if err != nil {
+		return nil, err
+	}
+	rpm := &fakeRPM{dir: dir}
+	return rpm, rpm.setInstalled(installed)
+}
+
+func (rpm *fakeRPM) setInstalled(installed [][3]string) error {
+	var buf bytes.Buffer
+	for _, pkg := range installed {
+		fmt.Fprintf(&buf, "%s %s %s\n", pkg[0], pkg[1], pkg[2])
+	}
+	return ioutil.WriteFile(filepath.Join(rpm.dir, "installed"), buf.Bytes(), 0644)
+}
+
+// calls returns the arguments of the rpm invocations, but the --dbpath ones
+// setFiles sets the files of the installed package id (name-version-release),
+// as listed by rpm -q --queryformat verifyQueryFormat.
+func (rpm *fakeRPM) setFiles(id string, files []string) error {
+	data := "8\n" + strings.Join(files, "\n") + "\n"
+	return ioutil.WriteFile(filepath.Join(rpm.dir, "files-"+id), []byte(data), 0644)
+}
+
+func (rpm *fakeRPM) calls() []string {
+	data, err := ioutil.ReadFile(filepath.Join(rpm.dir, "calls"))
+	if err != nil {
+		return nil
+	}
+	calls := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if f := strings.Fields(line); len(f) > 2 && f[0] == "--dbpath" {
+			calls = append(calls, strings.Join(f[2:], " "))
+		}
+	}
+	return calls
+}
+
+// newTestContext creates a Context on a new siteroot under tmpdir, offline:
+// its only repository (test) holds the packages of yum/testdata/repo.xml,
+// and rpm is a fakeRPM reporting installed as the installed packages.
+// The returned function closes the Context and restores $PATH: tests using
+// newTestContext can not run in parallel.
+func newTestContext(t *testing.T, tmpdir string, installed [][3]string, options ...func(*Context)) (*Context, *fakeRPM, func()) {
+	path := os.Getenv("PATH")
+	rpm, err := newFakeRPM(filepath.Join(tmpdir, "bin"), installed)
+	if err != nil {
+		t.Fatalf("error creating fake rpm: %v", err)
+	}
+	os.Setenv("PATH", rpm.dir+string(os.PathListSeparator)+path)
+
+	repodir := filepath.Join(tmpdir, "repo")
+	primary, err := ioutil.ReadFile(filepath.Join("yum", "testdata", "repo.xml"))
+	if err != nil {
+		t.Fatalf("error reading repository metadata: %v", err)
+	}
+	err = writeTestRepo(repodir, primary, []byte(testComps))
+	if err != nil {
+		t.Fatalf("error creating repository: %v", err)
+	}
+
+	siteroot := filepath.Join(tmpdir, "siteroot")
+	err = os.MkdirAll(filepath.Join(siteroot, "etc"), 0755)
+	if err != nil {
+		t.Fatalf("error creating siteroot: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(siteroot, "etc", "experiment.cfg"), []byte(`
+[experiment]
+name = test
+
+[repo.test]
+url = file://`+repodir+`
+`), 0644)
+	if err != nil {
+		t.Fatalf("error writing experiment file: %v", err)
+	}
+
+	cfg, err := NewConfigType("generic", siteroot)
+	if err != nil {
+		t.Fatalf("error creating config: %v", err)
+	}
+	ctx, err := New(cfg, options...)
+	if err != nil {
+		os.Setenv("PATH", path)
+		t.Fatalf("error creating context: %v", err)
+	}
+
+	err = writeTestRPMs(ctx)
+	if err != nil {
+		t.Fatalf("error creating RPM files: %v", err)
+	}
+
+	return ctx, rpm, func() {
+		ctx.Close()
+		os.Setenv("PATH", path)
+	}
+}
+
+// testComps are the package groups of the test repository
+const testComps = `<?xml version="1.0" encoding="UTF-8"?>
+<comps>
+  <group>
+    <id>cyclic</id>
+    <name>Cyclic dependencies</name>
+    <packagelist>
+      <packagereq type="default">TCyclicDep2</packagereq>
+      <packagereq type="default">TCyclicDep3</packagereq>
+    </packagelist>
+  </group>
+  <group>
+    <id>tp</id>
+    <name>Test packages</name>
+    <packagelist>
+      <packagereq type="mandatory">TP2</packagereq>
+      <packagereq type="default">TPRel</packagereq>
+      <packagereq type="optional">TCyclicDep</packagereq>
+    </packagelist>
+  </group>
+</comps>
+`
+
+// writeTestRepo creates a yum repository under repodir, from the content of
+// its primary.xml metadata file and of its (optional) comps.xml one
+func writeTestRepo(repodir string, primary, comps []byte) error {
+	err := os.MkdirAll(filepath.Join(repodir, "repodata"), 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(repodir, "repodata", "primary.xml"), primary, 0644)
+	if err != nil {
+		return err
+	}
+	group := ""
+	if comps != nil {
+		err = ioutil.WriteFile(filepath.Join(repodir, "repodata", "comps.xml"), comps, 0644)
+		if err != nil {
+			return err
+		}
+		group = `
+  <data type="group">
+    <location href="repodata/comps.xml"/>
+    <timestamp>1</timestamp>
+  </data>`
+	}
+	return ioutil.WriteFile(filepath.Join(repodir, "repodata", "repomd.xml"), []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <data type="primary">
+    <location href="repodata/primary.xml"/>
+    <timestamp>1</timestamp>
+  </data>`+group+`
+</repomd>
+`), 0644)
+}
+
+// writeTestRPMs creates the RPM files of the (file://) repositories of ctx.
+// The content of a RPM file is its RPM name.
+func writeTestRPMs(ctx *Context) error {
+	pkgs, err := ctx.yum.ListPackages(".*", "", "")
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		err = ioutil.WriteFile(strings.TrimPrefix(pkg.Url(), "file://"), []byte(pkg.RPMName()), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txItems returns the action and RPM of the items of a transaction, sorted
+func txItems(tx *Transaction) []string {
+	items := make([]string, 0, len(tx.Items))
+	for _, item := range tx.Items {
+		items = append(items, item.Action.String()+" "+item.Package.RPMName())
+	}
+	sort.Strings(items)
+	return items
+}
+
+func TestDowngradeDeps(t *testing.T) {
+	for i, table := range []struct {
+		installed [][3]string
+		name      string
+		version   string
+		release   string
+		want      []string
+	}{
+		{
+			// installed TestPackage satisfies TestPackage >= 1.2.5: kept
+			installed: [][3]string{{"TP2", "1.2.6", "1"}, {"TestPackage", "1.2.5", "1"}},
+			name:      "TP2", version: "1.2.5", release: "2",
+			want: []string{"downgrade TP2-1.2.5-2"},
+		},
+		{
+			// TestPackage == 1.2.5 required: downgraded
+			installed: [][3]string{{"TP3", "1.19.0", "1"}, {"TestPackage", "1.3.7", "1"}},
+			name:      "TP3",
+			want:      []string{"downgrade TP3-1.18.22-2", "downgrade TestPackage-1.2.5-1"},
+		},
+		{
+			// TestPackage == 1.2.5 required: installed
+			installed: [][3]string{{"TP3", "1.19.0", "1"}},
+			name:      "TP3",
+			want:      []string{"downgrade TP3-1.18.22-2", "install TestPackage-1.2.5-1"},
+		},
+		{
+			// TestPackage == 1.2.5 required: would be an upgrade
+			installed: [][3]string{{"TP3", "1.19.0", "1"}, {"TestPackage", "1.0.0", "1"}},
+			name:      "TP3",
+		},
+		{
+			// no older build
+			installed: [][3]string{{"TP3", "1.18.22", "2"}},
+			name:      "TP3",
+		},
+	} {
+		tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		ctx, _, done := newTestContext(t, tmpdir, table.installed)
+		tx, err := ctx.planDowngrade(table.name, table.version, table.release)
+		done()
+
+		if table.want == nil {
+			if err == nil {
+				t.Errorf("case #%d: expected an error. got %v", i, txItems(tx))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: error planning downgrade: %v", i, err)
+			continue
+		}
+		if got := txItems(tx); !reflect.DeepEqual(got, table.want) {
+			t.Errorf("case #%d:\nwant %v\ngot  %v", i, table.want, got)
+		}
+	}
+}
+
+func TestResolveManifest(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	installed := [][3]string{{"TP2", "1.2.5", "2"}, {"TestPackage", "1.2.5", "1"}}
+	ctx, _, done := newTestContext(t, tmpdir, installed)
+	defer done()
+
+	manifest, err := ctx.Export()
+	if err != nil {
+		t.Fatalf("error exporting manifest: %v", err)
+	}
+	if n := len(manifest.Packages); n != 2 {
+		t.Fatalf("want 2 packages in manifest. got %d", n)
+	}
+	if mpkg := manifest.Packages[1]; mpkg.Repository != "test" || mpkg.Arch != "noarch" || mpkg.Checksum == "" {
+		t.Fatalf("package recorded without origin: %#v", mpkg)
+	}
+
+	pkgs, err := ctx.resolveManifest(manifest)
+	if err != nil {
+		t.Fatalf("error resolving manifest: %v", err)
+	}
+	for i, pkg := range pkgs {
+		if got, want := pkg.RPMName(), manifest.Packages[i].ID(); got != want {
+			t.Errorf("package #%d: want %s. got %s", i, want, got)
+		}
+	}
+
+	for _, table := range []struct {
+		name   string
+		modify func(mpkg *ManifestPackage)
+		err    string
+	}{
+		{"version gone", func(mpkg *ManifestPackage) { mpkg.Release = "3" }, "not available anymore"},
+		{"epoch", func(mpkg *ManifestPackage) { mpkg.Epoch = "1" }, "epoch"},
+		{"arch", func(mpkg *ManifestPackage) { mpkg.Arch = "x86_64" }, "arch"},
+		{"checksum", func(mpkg *ManifestPackage) { mpkg.Checksum = "0123456789abcdef" }, "checksum"},
+	} {
+		m := *manifest
+		m.Packages = append([]ManifestPackage(nil), manifest.Packages...)
+		table.modify(&m.Packages[0])
+		_, err := ctx.resolveManifest(&m)
+		if err == nil || !strings.Contains(err.Error(), table.err) {
+			t.Errorf("%s: expected a %q error. got %v", table.name, table.err, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newTestContext(t, tmpdir, [][3]string{{"TP2", "1.2.5", "2"}, {"TestPackage", "1.2.5", "1"}})
+	defer done()
+	ctx.prompt.tty = false
+
+	manifest, err := ctx.Export()
+	if err != nil {
+		t.Fatalf("error exporting manifest: %v", err)
+	}
+	err = rpm.setInstalled(nil)
+	if err != nil {
+		t.Fatalf("error resetting installed packages: %v", err)
+	}
+
+	err = ctx.Import(manifest)
+	if err != nil {
+		t.Fatalf("error importing manifest: %v", err)
+	}
+	if ctx.options.NoDeps || ctx.options.NoResolve {
+		t.Fatalf("import did not restore the options (nodeps=%v, noresolve=%v)", ctx.options.NoDeps, ctx.options.NoResolve)
+	}
+
+	// exactly the packages of the manifest are installed, rpm checking their dependencies
+	var got []string
+	for _, call := range rpm.calls() {
+		args := strings.Fields(call)
+		if install, _ := rpmModes(args); !install {
+			continue
+		}
+		for _, arg := range args {
+			switch {
+			case arg == "--nodeps":
+				t.Fatalf("import disabled rpm dependency checks: %q", call)
+			case strings.HasSuffix(arg, ".rpm"):
+				got = append(got, filepath.Base(arg))
+			}
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"TP2-1.2.5-2.rpm", "TestPackage-1.2.5-1.rpm"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("installed RPMs:\nwant %q\ngot  %q", want, got)
+	}
+}
+
+func TestDownloadRPMs(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newTestContext(t, tmpdir, nil, EnableDryRun(true))
+	defer done()
+
+	dldir := filepath.Join(tmpdir, "dl")
+	names := func(pkgs []Package) []string {
+		o := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			o = append(o, pkg.RPMName())
+		}
+		return o
+	}
+
+	pkgs, size, err := ctx.downloadSet([]string{"TP2-1.2.5-2"}, dldir, false)
+	if err != nil {
+		t.Fatalf("error computing download set: %v", err)
+	}
+	if got, want := names(pkgs), []string{"TP2-1.2.5-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("download set w/o deps:\nwant %v\ngot  %v", want, got)
+	}
+	if size != pkgs[0].PackageSize() {
+		t.Fatalf("download size w/o deps: want %d. got %d", pkgs[0].PackageSize(), size)
+	}
+
+	pkgs, size, err = ctx.downloadSet([]string{"TP2-1.2.5-2"}, dldir, true)
+	if err != nil {
+		t.Fatalf("error computing download set: %v", err)
+	}
+	if got, want := names(pkgs), []string{"TP2-1.2.5-2", "TestPackage-1.3.7-1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("download set w/ deps:\nwant %v\ngot  %v", want, got)
+	}
+	total := pkgs[0].PackageSize() + pkgs[1].PackageSize()
+	if size != total {
+		t.Fatalf("download size w/ deps: want %d. got %d", total, size)
+	}
+
+	// a dry-run does not create anything
+	err = ctx.DownloadRPMs([]string{"TP2-1.2.5-2"}, dldir, true)
+	if err != nil {
+		t.Fatalf("error running dry-run download: %v", err)
+	}
+	if path_exists(dldir) {
+		t.Fatalf("dry-run download created %q", dldir)
+	}
+	for _, call := range rpm.calls() {
+		if strings.HasPrefix(call, "-K ") {
+			t.Fatalf("dry-run download checked RPM files: %q", call)
+		}
+	}
+
+	// files already downloaded with the expected size are skipped,
+	// truncated ones are not.
+	err = os.MkdirAll(dldir, 0755)
+	if err != nil {
+		t.Fatalf("error creating download dir: %v", err)
+	}
+	for _, pkg := range pkgs {
+		fname := filepath.Join(dldir, pkg.RPMFileName())
+		err = ioutil.WriteFile(fname, nil, 0644)
+		if err != nil {
+			t.Fatalf("error creating RPM file: %v", err)
+		}
+		if pkg.Name() == "TestPackage" {
+			err = os.Truncate(fname, pkg.PackageSize())
+			if err != nil {
+				t.Fatalf("error resizing RPM file: %v", err)
+			}
+		}
+	}
+	_, size, err = ctx.downloadSet([]string{"TP2-1.2.5-2"}, dldir, true)
+	if err != nil {
+		t.Fatalf("error computing download set: %v", err)
+	}
+	if size != pkgs[0].PackageSize() {
+		t.Fatalf("download size w/ existing files: want %d. got %d", pkgs[0].PackageSize(), size)
+	}
+}
+
+func TestInstallDownloadOnly(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newTestContext(t, tmpdir, nil, EnableDownloadOnly(true))
+	defer done()
+	ctx.prompt.tty = false
+
+	err = ctx.InstallRPMs([]string{"TP2-1.2.5-2"})
+	if err != nil {
+		t.Fatalf("error installing with -downloadonly: %v", err)
+	}
+
+	for _, call := range rpm.calls() {
+		mode, _ := rpmModes(strings.Fields(call))
+		if mode {
+			t.Errorf("-downloadonly ran rpm in install mode: %q", call)
+		}
+	}
+	for _, name := range []string{"TP2-1.2.5-2.rpm", "TestPackage-1.3.7-1.rpm"} {
+		if !path_exists(filepath.Join(ctx.tmpdir, name)) {
+			t.Errorf("-downloadonly did not download %s", name)
+		}
+	}
+}
+
+// testPrimary returns the primary.xml metadata of a yum repository holding
+// noarch packages at version 1.0.0-1 with the given requirements.
+func testPrimary(pkgs map[string][]string) []byte {
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	o := new(bytes.Buffer)
+	fmt.Fprintf(o, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common"
+	xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="%d">
+`, len(names))
+	for _, name := range names {
+		fmt.Fprintf(o, `	<package type="rpm">
+		<name>%[1]s</name>
+		<arch>noarch</arch>
+		<version epoch="0" ver="1.0.0" rel="1" />
+		<size package="1024" installed="2048" archive="2048" />
+		<location href="%[1]s-1.0.0-1.noarch.rpm" />
+		<format>
+			<rpm:provides>
+				<rpm:entry name="%[1]s" flags="EQ" epoch="0" ver="1.0.0" rel="1" />
+			</rpm:provides>
+			<rpm:requires>
+`, name)
+		for _, req := range pkgs[name] {
+			fmt.Fprintf(o, "\t\t\t\t<rpm:entry name=%q />\n", req)
+		}
+		fmt.Fprintf(o, "\t\t\t</rpm:requires>\n\t\t</format>\n\t</package>\n")
+	}
+	fmt.Fprintf(o, "</metadata>\n")
+	return o.Bytes()
+}
+
+// newTestSite returns the site specification of the GAUDI project, served by
+// the "proj" repository (created under tmpdir) which is not configured yet.
+func newTestSite(t *testing.T, tmpdir string) *Site {
+	const plat = "_x86_64_slc6_gcc48_opt"
+	repodir := filepath.Join(tmpdir, "proj")
+	err := writeTestRepo(repodir, testPrimary(map[string][]string{
+		"GAUDI_v24r0_index":  nil,
+		"GAUDI_v24r0" + plat: {"LCG_old", "TestPackage"},
+		"GAUDI_v25r0_index":  nil,
+		"GAUDI_v25r0" + plat: {"TestPackage"},
+		"GAUDI_v25r1_index":  nil,
+		"GAUDI_v25r1" + plat: {"TestPackage"},
+		"GAUDI_v26r0_index":  nil,
+		"GAUDI_v26r0" + plat: {"TestPackage"},
+		"LCG_old":            nil,
+	}), nil)
+	if err != nil {
+		t.Fatalf("error creating repository: %v", err)
+	}
+	return &Site{
+		Repositories: []SiteRepository{{Name: "proj", URL: "file://" + repodir}},
+		Projects: []SiteProject{{
+			Name:      "GAUDI",
+			Versions:  ">=v25r0, <v26r0",
+			Platforms: []string{"x86_64-slc6-gcc48-opt"},
+		}},
+	}
+}
+
+// syncItems returns the actions of a sync plan as strings
+func syncItems(plan *SyncPlan) []string {
+	items := make([]string, 0, len(plan.Actions))
+	for _, a := range plan.Actions {
+		items = append(items, strings.Join([]string{a.Action, a.Name, a.Old, a.New}, " "))
+	}
+	return items
+}
+
+func TestPlanSync(t *testing.T) {
+	for i, table := range []struct {
+		installed [][3]string
+		site      func(tmpdir string) *Site
+		prune     bool
+		want      func(tmpdir string) []string
+	}{
+		{
+			// out-of-range project versions are replaced, their
+			// dependencies still required are kept
+			installed: [][3]string{
+				{"GAUDI_v24r0_x86_64_slc6_gcc48_opt", "1.0.0", "1"},
+				{"LCG_old", "1.0.0", "1"},
+				{"TPRel", "4.2.8", "1"},
+				{"TestPackage", "1.3.7", "1"},
+			},
+			site: func(tmpdir string) *Site {
+				return newTestSite(t, tmpdir)
+			},
+			prune: true,
+			want: func(tmpdir string) []string {
+				return []string{
+					"add-repo proj  file://" + filepath.Join(tmpdir, "proj"),
+					"update GAUDI (x86_64_slc6_gcc48_opt) v24r0 v25r1",
+					"remove GAUDI_v24r0_x86_64_slc6_gcc48_opt 1.0.0-1 ",
+					"remove LCG_old 1.0.0-1 ",
+					"remove TPRel 4.2.8-1 ",
+				}
+			},
+		},
+		{
+			// same, without pruning: the replaced project version is
+			// still removed
+			installed: [][3]string{
+				{"GAUDI_v24r0_x86_64_slc6_gcc48_opt", "1.0.0", "1"},
+				{"LCG_old", "1.0.0", "1"},
+				{"TPRel", "4.2.8", "1"},
+				{"TestPackage", "1.3.7", "1"},
+			},
+			site: func(tmpdir string) *Site {
+				return newTestSite(t, tmpdir)
+			},
+			want: func(tmpdir string) []string {
+				return []string{
+					"add-repo proj  file://" + filepath.Join(tmpdir, "proj"),
+					"update GAUDI (x86_64_slc6_gcc48_opt) v24r0 v25r1",
+					"remove GAUDI_v24r0_x86_64_slc6_gcc48_opt 1.0.0-1 ",
+				}
+			},
+		},
+		{
+			// an installed version in range: nothing to do
+			installed: [][3]string{
+				{"GAUDI_v25r0_x86_64_slc6_gcc48_opt", "1.0.0", "1"},
+				{"TestPackage", "1.3.7", "1"},
+			},
+			site: func(tmpdir string) *Site {
+				return newTestSite(t, tmpdir)
+			},
+			prune: true,
+			want: func(tmpdir string) []string {
+				return []string{
+					"add-repo proj  file://" + filepath.Join(tmpdir, "proj"),
+				}
+			},
+		},
+		{
+			// rpms and locks
+			installed: [][3]string{
+				{"Orphan", "1.0", "1"},
+				{"TCyclicDep3", "1.0.0", "1"},
+				{"TP2", "1.2.5", "1"},
+				{"TP3", "1.18.22", "2"},
+				{"TPRel", "4.2.8", "1"},
+				{"TestPackage", "1.2.5", "1"},
+			},
+			site: func(tmpdir string) *Site {
+				return &Site{
+					RPMs: []SiteRPM{
+						{Name: "TP3"},
+						{Name: "TCyclicDep2", Versions: ">=1.0"},
+					},
+					Locks: []SiteLock{
+						{Name: "TP2", Version: "1.2.5", Release: "2"},
+						{Name: "TPRel", Version: "4.2.7", Release: "1"},
+					},
+				}
+			},
+			prune: true,
+			want: func(tmpdir string) []string {
+				return []string{
+					"install TCyclicDep2  1.0.0-1",
+					"update TP2 1.2.5-1 1.2.5-2",
+					"downgrade TPRel 4.2.8-1 4.2.7-1",
+					"remove Orphan 1.0-1 ",
+				}
+			},
+		},
+	} {
+		tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		ctx, _, done := newTestContext(t, tmpdir, table.installed)
+		plan, err := ctx.PlanSync(table.site(tmpdir), table.prune)
+		done()
+
+		if err != nil {
+			t.Errorf("case #%d: error planning sync: %v", i, err)
+			continue
+		}
+		if got, want := syncItems(plan), table.want(tmpdir); !reflect.DeepEqual(got, want) {
+			t.Errorf("case #%d:\nwant %q\ngot  %q", i, want, got)
+		}
+		if path_exists(filepath.Join(tmpdir, "siteroot", "etc", "yum.repos.d", "proj.repo")) {
+			t.Errorf("case #%d: planning configured a repository", i)
+		}
+	}
+}
+
+func TestApplySync(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newTestContext(t, tmpdir, [][3]string{
+		{"GAUDI_v24r0_x86_64_slc6_gcc48_opt", "1.0.0", "1"},
+		{"LCG_old", "1.0.0", "1"},
+		{"TPRel", "4.2.8", "1"},
+		{"TestPackage", "1.3.7", "1"},
+	})
+	defer done()
+	ctx.prompt.tty = false
+
+	plan, err := ctx.PlanSync(newTestSite(t, tmpdir), true)
+	if err != nil {
+		t.Fatalf("error planning sync: %v", err)
+	}
+	err = writeTestRPMs(ctx)
+	if err != nil {
+		t.Fatalf("error creating RPM files: %v", err)
+	}
+	repo := filepath.Join(ctx.yumreposd, "proj.repo")
+
+	// answering no: nothing is changed
+	ctx.prompt.no = true
+	err = ctx.ApplySync(plan)
+	if err != errAborted {
+		t.Fatalf("expected the sync to be aborted. got %v", err)
+	}
+	if path_exists(repo) {
+		t.Fatalf("aborted sync configured a repository")
+	}
+	for _, call := range rpm.calls() {
+		if install, _ := rpmModes(strings.Fields(call)); install || strings.HasPrefix(call, "-e ") {
+			t.Fatalf("aborted sync ran rpm %q", call)
+		}
+	}
+
+	ctx.prompt.no = false
+	err = ctx.ApplySync(plan)
+	if err != nil {
+		t.Fatalf("error applying sync: %v", err)
+	}
+	if !path_exists(repo) {
+		t.Fatalf("sync did not configure repository [proj]")
+	}
+
+	var install, remove []string
+	for _, call := range rpm.calls() {
+		switch args := strings.Fields(call); {
+		case args[0] == "-e":
+			remove = append(remove, args[1:]...)
+		case strings.HasPrefix(args[0], "-i") || strings.HasPrefix(args[0], "-U"):
+			for _, arg := range args[1:] {
+				if strings.HasSuffix(arg, ".rpm") {
+					install = append(install, filepath.Base(arg))
+				}
+			}
+		}
+	}
+	if want := []string{"GAUDI_v25r1_x86_64_slc6_gcc48_opt-1.0.0-1.rpm"}; !reflect.DeepEqual(install, want) {
+		t.Errorf("installed RPMs:\nwant %q\ngot  %q", want, install)
+	}
+	if want := []string{"GAUDI_v24r0_x86_64_slc6_gcc48_opt", "LCG_old", "TPRel"}; !reflect.DeepEqual(remove, want) {
+		t.Errorf("removed RPMs:\nwant %q\ngot  %q", want, remove)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	for i, table := range []struct {
+		installed [][3]string
+		want      map[string]string // group -> status
+	}{
+		{
+			want: map[string]string{"cyclic": "available", "tp": "available"},
+		},
+		{
+			// only the mandatory packages count
+			installed: [][3]string{{"TPRel", "4.2.8", "1"}},
+			want:      map[string]string{"cyclic": "available", "tp": "available"},
+		},
+		{
+			installed: [][3]string{{"TP2", "1.2.5", "2"}},
+			want:      map[string]string{"cyclic": "available", "tp": "installed"},
+		},
+		{
+			// no mandatory packages: the default ones count
+			installed: [][3]string{{"TCyclicDep2", "1.0.0", "1"}},
+			want:      map[string]string{"cyclic": "partial", "tp": "available"},
+		},
+		{
+			installed: [][3]string{{"TCyclicDep2", "1.0.0", "1"}, {"TCyclicDep3", "1.0.0", "1"}},
+			want:      map[string]string{"cyclic": "installed", "tp": "available"},
+		},
+	} {
+		tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		ctx, _, done := newTestContext(t, tmpdir, table.installed)
+		infos, err := ctx.Groups()
+		done()
+		if err != nil {
+			t.Errorf("case #%d: error listing groups: %v", i, err)
+			continue
+		}
+
+		got := make(map[string]string, len(infos))
+		for _, info := range infos {
+			got[info.ID] = info.Status
+		}
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("case #%d:\nwant %v\ngot  %v", i, table.want, got)
+		}
+	}
+}
+
+func TestInstallGroups(t *testing.T) {
+	for i, table := range []struct {
+		ids      []string
+		optional bool
+		want     []string
+	}{
+		{
+			ids:  []string{"tp"},
+			want: []string{"TP2-1.2.5-2.rpm", "TPRel-4.2.8-1.rpm", "TestPackage-1.3.7-1.rpm"},
+		},
+		{
+			ids:      []string{"tp"},
+			optional: true,
+			want: []string{
+				"TCyclicDep-1.0.0-1.rpm", "TCyclicDep2-1.0.0-1.rpm", "TCyclicDep3-1.0.0-1.rpm",
+				"TP2-1.2.5-2.rpm", "TPRel-4.2.8-1.rpm", "TestPackage-1.3.7-1.rpm",
+			},
+		},
+		{
+			ids:  []string{"Cyclic dependencies"},
+			want: []string{"TCyclicDep-1.0.0-1.rpm", "TCyclicDep2-1.0.0-1.rpm", "TCyclicDep3-1.0.0-1.rpm"},
+		},
+	} {
+		tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		ctx, rpm, done := newTestContext(t, tmpdir, nil)
+		ctx.prompt.tty = false
+		err = ctx.InstallGroups(table.ids, table.optional)
+		done()
+		if err != nil {
+			t.Errorf("case #%d: error installing groups: %v", i, err)
+			continue
+		}
+
+		got := make([]string, 0, len(table.want))
+		for _, call := range rpm.calls() {
+			if install, _ := rpmModes(strings.Fields(call)); !install {
+				continue
+			}
+			for _, arg := range strings.Fields(call) {
+				if strings.HasSuffix(arg, ".rpm") {
+					got = append(got, filepath.Base(arg))
+				}
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("case #%d:\nwant %v\ngot  %v", i, table.want, got)
+		}
+	}
+}
+
+// newReinstallContext returns a test Context whose repositories also hold
+// TReinstall-1.0.0-1 and TReinstall-1.0.0-2, with checksums.
+func newReinstallContext(t *testing.T, tmpdir string, installed [][3]string) (*Context, *fakeRPM, func()) {
+	ctx, rpm, done := newTestContext(t, tmpdir, installed)
+	ctx.prompt.tty = false
+
+	repodir := filepath.Join(tmpdir, "reinst")
+	err := os.MkdirAll(repodir, 0755)
+	if err != nil {
+		done()
+		t.Fatalf("error creating repository: %v", err)
+	}
+	o := new(bytes.Buffer)
+	fmt.Fprintf(o, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common"
+	xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="2">
+`)
+	for _, rel := range []string{"1", "2"} {
+		rpmname := "TReinstall-1.0.0-" + rel
+		fname := filepath.Join(repodir, rpmname+".noarch.rpm")
+		err = ioutil.WriteFile(fname, []byte(rpmname), 0644)
+		if err != nil {
+			done()
+			t.Fatalf("error creating RPM file: %v", err)
+		}
+		sum, err := fileChecksum(fname, "sha256")
+		if err != nil {
+			done()
+			t.Fatalf("error computing checksum: %v", err)
+		}
+		fmt.Fprintf(o, `	<package type="rpm">
+		<name>TReinstall</name>
+		<arch>noarch</arch>
+		<version epoch="0" ver="1.0.0" rel="%[1]s" />
+		<checksum type="sha256" pkgid="YES">%[2]s</checksum>
+		<size package="1024" installed="2048" archive="2048" />
+		<location href="%[3]s.noarch.rpm" />
+		<format>
+			<rpm:provides>
+				<rpm:entry name="TReinstall" flags="EQ" epoch="0" ver="1.0.0" rel="%[1]s" />
+			</rpm:provides>
+		</format>
+	</package>
+`, rel, sum, rpmname)
+	}
+	fmt.Fprintf(o, "</metadata>\n")
+	err = writeTestRepo(repodir, o.Bytes(), nil)
+	if err != nil {
+		done()
+		t.Fatalf("error creating repository: %v", err)
+	}
+
+	ctx.yum.Close()
+	ctx.yum, err = yum.NewWithRepositories(ctx.siteroot, map[string]string{"reinst": repodir})
+	if err != nil {
+		done()
+		t.Fatalf("error loading repositories: %v", err)
+	}
+	return ctx, rpm, done
+}
+
+// rpmInstallCalls returns the rpm calls of fake rpm installing RPMs, without
+// the relocation arguments of ctx.
+func rpmInstallCalls(ctx *Context, rpm *fakeRPM) []string {
+	relocs := strings.Join(ctx.relocs.Args(), " ")
+	calls := make([]string, 0)
+	for _, call := range rpm.calls() {
+		if install, _ := rpmModes(strings.Fields(call)); !install {
+			continue
+		}
+		calls = append(calls, strings.TrimSpace(strings.TrimPrefix(call, relocs)))
+	}
+	return calls
+}
+
+func TestReinstallRPMs(t *testing.T) {
+	for i, table := range []struct {
+		installed [][3]string
+		rpms      []string
+		err       string
+		want      []string
+	}{
+		{
+			// the installed NEVRA is reinstalled, not the latest one
+			installed: [][3]string{{"TReinstall", "1.0.0", "1"}},
+			rpms:      []string{"TReinstall"},
+			want:      []string{"-Uvh --replacepkgs TReinstall-1.0.0-1.rpm"},
+		},
+		{
+			installed: [][3]string{{"TReinstall", "1.0.0", "2"}},
+			rpms:      []string{"TReinstall-1.0.0"},
+			want:      []string{"-Uvh --replacepkgs TReinstall-1.0.0-2.rpm"},
+		},
+		{
+			installed: [][3]string{{"TReinstall", "0.9.0", "1"}},
+			rpms:      []string{"TReinstall"},
+			err:       "lbpkr: package TReinstall-0.9.0-1 is not available from the repositories anymore",
+			want:      []string{},
+		},
+		{
+			installed: nil,
+			rpms:      []string{"TReinstall"},
+			err:       `lbpkr: package "TReinstall" is not installed`,
+			want:      []string{},
+		},
+	} {
+		tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		ctx, rpm, done := newReinstallContext(t, tmpdir, table.installed)
+		err = ctx.ReinstallRPMs(table.rpms)
+		got := rpmInstallCalls(ctx, rpm)
+		for j, call := range got {
+			got[j] = strings.Replace(call, ctx.tmpdir+string(os.PathSeparator), "", -1)
+		}
+		done()
+
+		switch {
+		case table.err == "" && err != nil:
+			t.Errorf("case #%d: error reinstalling: %v", i, err)
+			continue
+		case table.err != "" && (err == nil || err.Error() != table.err):
+			t.Errorf("case #%d: expected error %q. got %v", i, table.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("case #%d:\nwant %q\ngot  %q", i, table.want, got)
+		}
+	}
+}
+
+func TestReinstallCorruptedCache(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newReinstallContext(t, tmpdir, [][3]string{{"TReinstall", "1.0.0", "1"}})
+	defer done()
+
+	fname := filepath.Join(ctx.tmpdir, "TReinstall-1.0.0-1.rpm")
+	err = ioutil.WriteFile(fname, []byte("corrupted"), 0644)
+	if err != nil {
+		t.Fatalf("error creating cached RPM: %v", err)
+	}
+
+	err = ctx.ReinstallRPMs([]string{"TReinstall"})
+	if err != nil {
+		t.Fatalf("error reinstalling: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("error reading cached RPM: %v", err)
+	}
+	if got, want := string(data), "TReinstall-1.0.0-1"; got != want {
+		t.Fatalf("corrupted cached RPM not downloaded again: got %q, want %q", got, want)
+	}
+	if got, want := rpmInstallCalls(ctx, rpm), []string{"-Uvh --replacepkgs " + fname}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rpm calls:\nwant %q\ngot  %q", want, got)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newReinstallContext(t, tmpdir, [][3]string{
+		{"TReinstall", "1.0.0", "1"},
+		{"TestPackage", "1.3.7", "1"},
+	})
+	defer done()
+
+	// two files of TReinstall are missing, TestPackage has no file
+	missing := filepath.Join(tmpdir, "missing")
+	err = rpm.setFiles("TReinstall-1.0.0-1", []string{
+		missing + "-1\t5\t100644\t\t0\t0\t",
+		missing + "-2\t5\t100644\t\t0\t0\t",
+	})
+	if err != nil {
+		t.Fatalf("error setting files: %v", err)
+	}
+
+	problems, err := ctx.Verify(".*")
+	if err != nil {
+		t.Fatalf("error verifying: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems. got %d (%v)", len(problems), problems)
+	}
+
+	err = ctx.Repair(".*")
+	if err != nil {
+		t.Fatalf("error repairing: %v", err)
+	}
+	want := []string{"-Uvh --replacepkgs " + filepath.Join(ctx.tmpdir, "TReinstall-1.0.0-1.rpm")}
+	if got := rpmInstallCalls(ctx, rpm); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rpm calls:\nwant %q\ngot  %q", want, got)
+	}
+}
+
+func TestRemoveRPM(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx, rpm, done := newTestContext(t, tmpdir, [][3]string{
+		{"TPRel", "4.2.8", "1"},
+		{"TestPackage", "1.3.7", "1"},
+	})
+	defer done()
+	out := new(bytes.Buffer)
+	ctx.msg = logger.NewLogger("lbpkr", logger.INFO, out)
+	ctx.prompt.tty = false
+
+	// the resolved NEVRAs are displayed before asking for confirmation
+	ctx.prompt.no = true
+	err = ctx.RemoveRPM([][3]string{{"TestPackage", "", ""}}, false)
+	if err != errAborted {
+		t.Fatalf("expected the removal to be aborted. got %v", err)
+	}
+	if want := "[001/001] TestPackage-1.3.7-1\n"; !strings.Contains(out.String(), want) {
+		t.Fatalf("resolved RPM not displayed:\nwant %q\ngot  %q", want, out.String())
+	}
+	for _, call := range rpm.calls() {
+		if strings.HasPrefix(call, "-e ") {
+			t.Fatalf("aborted removal ran rpm %q", call)
+		}
+	}
+
+	ctx.prompt.no = false
+	err = ctx.RemoveRPM([][3]string{{"TP2", "", ""}}, false)
+	if err == nil || err.Error() != `lbpkr: package "TP2" is not installed` {
+		t.Fatalf("expected an error removing a package not installed. got %v", err)
+	}
+
+	err = ctx.RemoveRPM([][3]string{{"TestPackage", "1.3.7", ""}}, false)
+	if err != nil {
+		t.Fatalf("error removing RPM: %v", err)
+	}
+	var got []string
+	for _, call := range rpm.calls() {
+		if strings.HasPrefix(call, "-e ") {
+			got = append(got, call)
+		}
+	}
+	if want := []string{"-e TestPackage"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rpm calls:\nwant %q\ngot  %q", want, got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// Manifest describes the packages installed in a siteroot, so the siteroot
+// can be rebuilt elsewhere with exactly the same packages.
+type Manifest struct {
+	Siteroot     string            `json:"siteroot"`
+	Date         time.Time         `json:"date"`
+	Lbpkr        string            `json:"lbpkr"` // version of lbpkr which wrote the manifest
+	Repositories []RepoInfo        `json:"repositories"`
+	Packages     []ManifestPackage `json:"packages"`
+}
+
+// ManifestPackage is an installed package, with its origin and checksum
+type ManifestPackage struct {
+	Name         string `json:"name"`
+	Epoch        string `json:"epoch"`
+	Version      string `json:"version"`
+	Release      string `json:"release"`
+	Arch         string `json:"arch"`
+	Repository   string `json:"repository,omitempty"`
+	URL          string `json:"url,omitempty"`
+	ChecksumType string `json:"checksum_type,omitempty"`
+	Checksum     string `json:"checksum,omitempty"`
+}
+
+// ID returns the name-version-release of the package
+func (pkg ManifestPackage) ID() string {
+	return pkg.Name + "-" + pkg.Version + "-" + pkg.Release
+}
+
+// Export returns the manifest of the packages installed in the siteroot.
+// Installed packages which are not available anymore from the repositories
+// are recorded without origin nor checksum.
+func (ctx *Context) Export() (*Manifest, error) {
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	repos, err := ctx.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Siteroot:     ctx.siteroot,
+		Date:         time.Now().UTC(),
+		Lbpkr:        Version,
+		Repositories: repos,
+		Packages:     make([]ManifestPackage, 0, len(installed)),
+	}
+	for _, rpm := range installed {
+		mpkg := ManifestPackage{Name: rpm[0], Version: rpm[1], Release: rpm[2]}
+		pkg, err := ctx.yum.FindLatestMatchingName(rpm[0], rpm[1], rpm[2])
+		if err != nil || pkg == nil {
+			ctx.msg.Warnf("%s-%s-%s is not available from the repositories: recording it without origin\n",
+				rpm[0], rpm[1], rpm[2],
+			)
+			manifest.Packages = append(manifest.Packages, mpkg)
+			continue
+		}
+		mpkg.Epoch = pkg.Epoch()
+		mpkg.Arch = pkg.Arch()
+		if repo := pkg.Repository(); repo != nil {
+			mpkg.Repository = repo.Name
+			mpkg.URL = pkg.Url()
+		}
+		mpkg.ChecksumType, mpkg.Checksum = pkg.Checksum()
+		manifest.Packages = append(manifest.Packages, mpkg)
+	}
+	sort.Sort(manifestPackages(manifest.Packages))
+	return manifest, nil
+}
+
+// WriteManifest writes a manifest in the JSON format
+func WriteManifest(w io.Writer, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadManifest reads a manifest written by WriteManifest
+func ReadManifest(fname string) (*Manifest, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var manifest Manifest
+	err = json.NewDecoder(f).Decode(&manifest)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: invalid manifest [%s]: %v", fname, err)
+	}
+	return &manifest, nil
+}
+
+// Import installs exactly the packages of a manifest.
+// Import fails if one of the packages (with its exact epoch, version, release
+// and arch) is not available anymore from the repositories, or if its
+// checksum changed.
+// Dependencies are not resolved: the manifest of a siteroot already holds them.
+// rpm still checks them, unless the Context was created with EnableNoDeps.
+func (ctx *Context) Import(manifest *Manifest) error {
+	pkgs, err := ctx.resolveManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) == 0 {
+		return fmt.Errorf("lbpkr: no package in manifest")
+	}
+
+	// the packages of the manifest are installed together: rpm still
+	// checks their dependencies are satisfied.
+	noresolve := ctx.options.NoResolve
+	ctx.options.NoResolve = true
+	defer func() { ctx.options.NoResolve = noresolve }()
+	return ctx.InstallPackages(pkgs)
+}
+
+// resolveManifest finds the packages of a manifest in the repositories
+func (ctx *Context) resolveManifest(manifest *Manifest) ([]Package, error) {
+	urls := make(map[string]string, len(manifest.Repositories))
+	for _, repo := range manifest.Repositories {
+		urls[repo.Name] = repo.URL
+	}
+	repos, err := ctx.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		if url, ok := urls[repo.Name]; ok && url != repo.URL {
+			ctx.msg.Warnf("repository [%s] has URL %q (manifest: %q)\n", repo.Name, repo.URL, url)
+		}
+	}
+
+	pkgs := make([]Package, 0, len(manifest.Packages))
+	for _, mpkg := range manifest.Packages {
+		pkg, err := ctx.findManifestPackage(mpkg)
+		if err != nil {
+			return nil, err
+		}
+		if typ, sum := pkg.Checksum(); mpkg.Checksum != "" && sum != "" {
+			if typ != mpkg.ChecksumType || sum != mpkg.Checksum {
+				return nil, fmt.Errorf("lbpkr: checksum of %s changed (%s: got %s, want %s:%s)",
+					mpkg.ID(), typ, sum, mpkg.ChecksumType, mpkg.Checksum,
+				)
+			}
+		}
+		pkgs = append(pkgs, Package{pkg, InstallMode})
+	}
+	return pkgs, nil
+}
+
+// findManifestPackage returns the package of the repositories with the name,
+// epoch, version, release and arch of a manifest package.
+// Packages recorded without origin have no epoch nor arch to compare.
+func (ctx *Context) findManifestPackage(mpkg ManifestPackage) (*yum.Package, error) {
+	pkgs, err := ctx.yum.ListPackages(
+		"^"+regexp.QuoteMeta(mpkg.Name)+"$",
+		"^"+regexp.QuoteMeta(mpkg.Version)+"$",
+		"^"+regexp.QuoteMeta(mpkg.Release)+"$",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("lbpkr: pinned package %s is not available anymore from the repositories", mpkg.ID())
+	}
+	for _, pkg := range pkgs {
+		if (mpkg.Epoch == "" || pkg.Epoch() == mpkg.Epoch) && (mpkg.Arch == "" || pkg.Arch() == mpkg.Arch) {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("lbpkr: pinned package %s is not available anymore from the repositories with epoch %q and arch %q (got epoch %q and arch %q)",
+		mpkg.ID(), mpkg.Epoch, mpkg.Arch, pkgs[0].Epoch(), pkgs[0].Arch(),
+	)
+}
+
+type manifestPackages []ManifestPackage
+
+func (p manifestPackages) Len() int      { return len(p) }
+func (p manifestPackages) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p manifestPackages) Less(i, j int) bool {
+	if p[i].Name != p[j].Name {
+		return p[i].Name < p[j].Name
+	}
+	return p[i].ID() < p[j].ID()
+}
@@ -58,3 +58,10 @@ func (p PackagesByDepGraph) Less(i, j int) bool {
 	// order by un-installed dependencies
 	return len(pir) < len(pjr)
 }
+
+// PackagesByName sorts packages by their RPM name
+type PackagesByName []Package
+
+func (p PackagesByName) Len() int           { return len(p) }
+func (p PackagesByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p PackagesByName) Less(i, j int) bool { return p[i].RPMName() < p[j].RPMName() }
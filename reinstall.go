@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ReinstallRPMs reinstalls the exact same version of a list of installed RPMs
+func (ctx *Context) ReinstallRPMs(rpms []string) error {
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return err
+	}
+
+	ids := make([][3]string, 0, len(rpms))
+	for _, rpm := range rpms {
+		args := splitRPM(rpm)
+		found := false
+		for _, pkg := range installed {
+			if pkg[0] != args[0] ||
+				(args[1] != "" && pkg[1] != args[1]) ||
+				(args[2] != "" && pkg[2] != args[2]) {
+				continue
+			}
+			ids = append(ids, pkg)
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("lbpkr: package %q is not installed", rpm)
+		}
+	}
+
+	return ctx.reinstall(ids)
+}
+
+// Repair reinstalls all the installed packages whose name matches pattern and
+// which fail verification.
+func (ctx *Context) Repair(pattern string) error {
+	problems, err := ctx.Verify(pattern)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[[3]string]struct{})
+	ids := make([][3]string, 0)
+	for _, v := range problems {
+		if _, dup := set[v.rpm]; dup {
+			continue
+		}
+		set[v.rpm] = struct{}{}
+		ids = append(ids, v.rpm)
+	}
+
+	if len(ids) <= 0 {
+		ctx.msg.Infof("nothing to repair\n")
+		return nil
+	}
+
+	return ctx.reinstall(ids)
+}
+
+// reinstall re-downloads and reinstalls the exact NEVRA of installed packages
+func (ctx *Context) reinstall(ids [][3]string) error {
+	var err error
+	pkgs := make([]Package, 0, len(ids))
+	for _, id := range ids {
+		pkg, err := ctx.yum.FindLatestProvider(id[0], id[1], id[2])
+		if err != nil {
+			return err
+		}
+		if pkg == nil || pkg.Version() != id[1] || pkg.Release() != id[2] {
+			return fmt.Errorf(
+				"lbpkr: package %s-%s-%s is not available from the repositories anymore",
+				id[0], id[1], id[2],
+			)
+		}
+		pkgs = append(pkgs, Package{pkg, InstallMode})
+	}
+	sort.Sort(PackagesByName(pkgs))
+
+	ctx.msg.Infof("found %d RPMs to reinstall:\n", len(pkgs))
+	for i, pkg := range pkgs {
+		ctx.msg.Infof("\t[%03d/%03d] %s\n", i+1, len(pkgs), pkg.RPMName())
+	}
+
+	if ctx.options.DryRun {
+		ctx.msg.Infof("no RPM reinstalled (dry-run)\n")
+		return nil
+	}
+
+	err = ctx.confirm("is this ok?")
+	if err != nil {
+		return err
+	}
+
+	// discard cached RPMs which do not match their checksum
+	for _, pkg := range pkgs {
+		fname := filepath.Join(ctx.tmpdir, pkg.RPMFileName())
+		if !path_exists(fname) {
+			continue
+		}
+		if ctx.checkPackageChecksum(pkg, fname) != nil {
+			ctx.msg.Debugf("discarding corrupted cached RPM %s\n", fname)
+			err = os.Remove(fname)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = ctx.downloadPackages(pkgs, ctx.tmpdir)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-Uvh", "--replacepkgs"}
+	if ctx.options.Force || ctx.options.NoDeps {
+		args = append(args, "--nodeps")
+	}
+	if ctx.options.JustDb {
+		args = append(args, "--justdb")
+	}
+	for _, pkg := range pkgs {
+		fname := filepath.Join(ctx.tmpdir, pkg.RPMFileName())
+		err = ctx.checkPackageChecksum(pkg, fname)
+		if err != nil {
+			return err
+		}
+		args = append(args, fname)
+	}
+
+	ctx.msg.Infof("reinstalling [%d] RPMs...\n", len(pkgs))
+	out, err := ctx.rpm(true, args...)
+	if err != nil {
+		ctx.msg.Errorf("rpm reinstall command failed: %v\n%v\n", err, string(out))
+		return err
+	}
+	return err
+}
+
+// checkPackageChecksum checks the RPM file fname against the checksum
+// recorded in the repository metadata of pkg.
+func (ctx *Context) checkPackageChecksum(pkg Package, fname string) error {
+	typ, want := pkg.Checksum()
+	if want == "" {
+		ctx.msg.Debugf("no checksum for %s in repository metadata\n", pkg.RPMName())
+		return nil
+	}
+
+	sum, err := fileChecksum(fname, typ)
+	if err != nil {
+		return fmt.Errorf("lbpkr: could not compute checksum of %s: %v", fname, err)
+	}
+
+	if sum != want {
+		return fmt.Errorf("lbpkr: checksum mismatch for %s (%s: got %s, want %s)",
+			fname, typ, sum, want,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// Relocation moves the files packaged under Prefix to Path
+type Relocation struct {
+	Prefix string `json:"prefix"`
+	Path   string `json:"path"`
+}
+
+// match returns whether fname is Prefix or lies under Prefix
+func (r Relocation) match(fname string) bool {
+	return r.Prefix == "/" || fname == r.Prefix || strings.HasPrefix(fname, r.Prefix+"/")
+}
+
+// Relocations is an ordered list of relocation rules.
+// A file is relocated by the rule with the longest prefix matching its path,
+// like rpm does with --relocate.
+type Relocations []Relocation
+
+// Relocate returns the relocated path of a file, as packaged
+func (rules Relocations) Relocate(fname string) string {
+	best := -1
+	for i, r := range rules {
+		if !r.match(fname) {
+			continue
+		}
+		if best < 0 || len(r.Prefix) > len(rules[best].Prefix) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return fname
+	}
+	r := rules[best]
+	if r.Prefix == "/" {
+		return path.Join(r.Path, fname)
+	}
+	return r.Path + fname[len(r.Prefix):]
+}
+
+// absPathRe matches the absolute paths of a text
+var absPathRe = regexp.MustCompile(`(^|\s)/\S*`)
+
+// RelocateText relocates the absolute paths of a text (e.g. the output of
+// rpm -ql), leaving the rest untouched.
+func (rules Relocations) RelocateText(txt string) string {
+	return absPathRe.ReplaceAllStringFunc(txt, func(m string) string {
+		i := strings.Index(m, "/")
+		return m[:i] + rules.Relocate(m[i:])
+	})
+}
+
+// Args returns the arguments to be passed to rpm to apply the rules
+func (rules Relocations) Args() []string {
+	if len(rules) == 0 {
+		return nil
+	}
+	args := make([]string, 0, 2*len(rules)+1)
+	for _, r := range rules {
+		args = append(args, "--relocate", r.Prefix+"="+r.Path)
+	}
+	return append(args, "--badreloc")
+}
+
+// parseRelocations parses a comma-separated list of prefix=path rules.
+// Relative paths are relative to the siteroot.
+func parseRelocations(s, siteroot string) (Relocations, error) {
+	rules := make(Relocations, 0)
+	seen := make(map[string]struct{})
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.Index(rule, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("lbpkr: invalid relocation %q (expected prefix=path)", rule)
+		}
+		prefix := strings.TrimSpace(rule[:i])
+		dst := strings.TrimSpace(rule[i+1:])
+		if !path.IsAbs(prefix) || dst == "" {
+			return nil, fmt.Errorf("lbpkr: invalid relocation %q (expected /absolute/prefix=path)", rule)
+		}
+		prefix = path.Clean(prefix)
+		if _, dup := seen[prefix]; dup {
+			return nil, fmt.Errorf("lbpkr: prefix %q relocated twice", prefix)
+		}
+		seen[prefix] = struct{}{}
+		if !filepath.IsAbs(dst) {
+			dst = filepath.Join(siteroot, dst)
+		}
+		rules = append(rules, Relocation{Prefix: prefix, Path: filepath.Clean(dst)})
+	}
+	return rules, nil
+}
+
+// loadRelocations returns the relocation rules of the siteroot: the
+// relocate setting (usually from ${siteroot}/etc/lbpkr.conf) if it is set, or
+// the default rules of the configuration otherwise.
+// loadRelocations also returns where the rules come from.
+func (ctx *Context) loadRelocations() (Relocations, string, error) {
+	v, src, ok := ctx.settings.Lookup("relocate")
+	if !ok {
+		return ctx.cfg.Relocations(), ctx.cfg.Name(), nil
+	}
+	rules, err := parseRelocations(v, ctx.siteroot)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v [%s]", err, src)
+	}
+	return rules, src, nil
+}
+
+// RelocatedFile is a file, as packaged and as relocated
+type RelocatedFile struct {
+	File      string `json:"file"`
+	Relocated string `json:"relocated"`
+}
+
+// RelocationInfo describes the relocation rules of a siteroot
+type RelocationInfo struct {
+	Source string          `json:"source"` // file defining the rules (or config type)
+	Rules  Relocations     `json:"rules"`
+	Files  []RelocatedFile `json:"files,omitempty"`
+}
+
+func (info *RelocationInfo) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "# relocations (from %s)\n", info.Source)
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, r := range info.Rules {
+		fmt.Fprintf(tw, "%s\t-> %s\n", r.Prefix, r.Path)
+	}
+	if len(info.Files) > 0 {
+		fmt.Fprintf(tw, "\n")
+		for _, f := range info.Files {
+			fmt.Fprintf(tw, "%s\t-> %s\n", f.File, f.Relocated)
+		}
+	}
+	return tw.Flush()
+}
+
+// Relocations returns the relocation rules of the siteroot, and how the
+// given files (as packaged) are relocated.
+func (ctx *Context) Relocations(files ...string) *RelocationInfo {
+	info := &RelocationInfo{
+		Source: ctx.relocsrc,
+		Rules:  ctx.relocs,
+		Files:  make([]RelocatedFile, 0, len(files)),
+	}
+	for _, fname := range files {
+		info.Files = append(info.Files, RelocatedFile{
+			File:      fname,
+			Relocated: ctx.relocs.Relocate(fname),
+		})
+	}
+	return info
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// searchResults are the packages matching a search, best matches first
+type searchResults []yum.SearchResult
+
+func (p searchResults) MarshalJSON() ([]byte, error) {
+	type jsonResult struct {
+		PackageInfo
+		Summary string `json:"summary"`
+		Score   int    `json:"score"`
+	}
+	results := make([]jsonResult, 0, len(p))
+	for _, r := range p {
+		results = append(results, jsonResult{
+			PackageInfo: newPackageInfo(r.Package),
+			Summary:     r.Package.Summary(),
+			Score:       r.Score,
+		})
+	}
+	return json.Marshal(results)
+}
+
+func (p searchResults) writeText(w io.Writer) error {
+	if len(p) <= 0 {
+		_, err := fmt.Fprintf(w, "** No Match found **\n")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, r := range p {
+		fmt.Fprintf(tw, "%s\t%s-%s\t%s\n", r.Package.Name(), r.Package.Version(), r.Package.Release(), r.Package.Summary())
+	}
+	return tw.Flush()
+}
+
+// Search returns the packages matching all the search terms in their name,
+// summary, description or provides, best matches first.
+// Only the latest version of each package is returned, unless all is true.
+func (ctx *Context) Search(terms []string, all bool) ([]yum.SearchResult, error) {
+	results, err := ctx.yum.Search(terms...)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return results, nil
+	}
+
+	// results are sorted by score, then newest first: keep the first one of each name
+	latest := make([]yum.SearchResult, 0, len(results))
+	seen := make(map[string]int, len(results))
+	for _, r := range results {
+		name := r.Package.Name()
+		if i, dup := seen[name]; dup {
+			if yum.RPMLessThan(latest[i].Package, r.Package) {
+				latest[i].Package = r.Package
+			}
+			continue
+		}
+		seen[name] = len(latest)
+		latest = append(latest, r)
+	}
+	return latest, nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/config"
+	"github.com/gonuts/flag"
+)
+
+// Levels of lbpkr.conf files, by increasing precedence
+const (
+	SystemLevel   = "system"   // /etc/lbpkr.conf
+	SiterootLevel = "siteroot" // ${siteroot}/etc/lbpkr.conf
+	UserLevel     = "user"     // ${XDG_CONFIG_HOME:-~/.config}/lbpkr/lbpkr.conf
+)
+
+// settingsSection is the section of lbpkr.conf files holding the settings
+const settingsSection = "lbpkr"
+
+// systemSettings is the system-wide lbpkr.conf file
+var systemSettings = "/etc/lbpkr.conf"
+
+// settingKey describes an option of lbpkr.conf files
+type settingKey struct {
+	name   string
+	env    string // environment variable overriding the files
+	doc    string
+	check  func(v string) error
+	levels []string // levels where the setting may be set (nil: all)
+}
+
+// allowed returns whether the setting may be set at a given level
+func (k settingKey) allowed(level string) bool {
+	if k.levels == nil {
+		return true
+	}
+	for _, l := range k.levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+var settingKeys = []settingKey{
+	// a siteroot does not define where it is
+	{"siteroot", "MYSITEROOT", "default siteroot (system and user levels)", checkAbsPath, []string{SystemLevel, UserLevel}},
+	{"type", "LBPKR_CONFIG_TYPE", "config type", checkConfigType, nil},
+	{"repourl", "LBPKR_REPOURL", "base URL of the LHCb yum repositories", checkURL, nil},
+	{"ndls", "LBPKR_NDLS", "number of concurrent downloads", checkPositiveInt, nil},
+	{"proxy", "LBPKR_PROXY", "HTTP proxy (default: $http_proxy)", checkURL, nil},
+	{"timeout", "LBPKR_TIMEOUT", "timeout of HTTP requests (0: none)", checkDuration, nil},
+	{"lock-timeout", "LBPKR_LOCK_TIMEOUT", "maximum time to wait for the siteroot lock (<0: wait forever)", checkDuration, nil},
+	{"format", "LBPKR_FORMAT", "output format of query commands (text|json)", checkFormat, nil},
+	{"platforms", "CMTCONFIG", "default platforms of projects (comma-separated)", nil, nil},
+	{"debug", "LBPKR_DEBUG", "debug output (true|false)", checkBool, nil},
+	{"rpmupdate", "LBPKR_RPMUPDATE", "install RPMs with rpm -U (true|false)", checkBool, nil},
+	// all the users of a siteroot must relocate files the same way
+	{"relocate", "", "relocation rules (prefix=path, comma-separated; siteroot level)", nil, []string{SiterootLevel}},
+}
+
+// findSettingKey returns the description of an option of lbpkr.conf files
+func findSettingKey(name string) (settingKey, error) {
+	for _, k := range settingKeys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	names := make([]string, 0, len(settingKeys))
+	for _, k := range settingKeys {
+		names = append(names, k.name)
+	}
+	return settingKey{}, fmt.Errorf("lbpkr: unknown setting %q (expected one of: %s)", name, strings.Join(names, ", "))
+}
+
+func checkAbsPath(v string) error {
+	if !filepath.IsAbs(v) {
+		return fmt.Errorf("not an absolute path")
+	}
+	return nil
+}
+
+func checkConfigType(v string) error {
+	for _, typ := range ConfigTypes() {
+		if v == typ {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of: %s", strings.Join(ConfigTypes(), "|"))
+}
+
+func checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("not an absolute URL")
+	}
+	return nil
+}
+
+func checkPositiveInt(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return fmt.Errorf("expected a positive number")
+	}
+	return nil
+}
+
+func checkBool(v string) error {
+	_, err := strconv.ParseBool(v)
+	return err
+}
+
+func checkDuration(v string) error {
+	_, err := time.ParseDuration(v)
+	return err
+}
+
+func checkFormat(v string) error {
+	switch Format(v) {
+	case TextFormat, JSONFormat:
+		return nil
+	}
+	return fmt.Errorf("expected text|json")
+}
+
+// settingsFile is a lbpkr.conf file
+type settingsFile struct {
+	level string
+	fname string
+	cfg   *config.Config // nil if the file does not exist
+}
+
+// Settings holds the options of the lbpkr.conf files of the system, of a
+// siteroot and of the user. Environment variables override the files, and
+// user files override siteroot files, which override the system file.
+type Settings struct {
+	files []settingsFile // by increasing precedence
+}
+
+// settingsFiles returns the lbpkr.conf files of a siteroot, by increasing precedence
+func settingsFiles(siteroot string) [][2]string {
+	files := [][2]string{{SystemLevel, systemSettings}}
+	if siteroot != "" {
+		files = append(files, [2]string{SiterootLevel, filepath.Join(siteroot, "etc", "lbpkr.conf")})
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" && os.Getenv("HOME") != "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	if dir != "" {
+		files = append(files, [2]string{UserLevel, filepath.Join(dir, "lbpkr", "lbpkr.conf")})
+	}
+	return files
+}
+
+// loadSettings reads the lbpkr.conf files of a siteroot
+func loadSettings(siteroot string) (*Settings, error) {
+	return readSettings(settingsFiles(siteroot))
+}
+
+// readSettings reads (level, file name) lbpkr.conf files, by increasing
+// precedence. Missing files are skipped.
+func readSettings(files [][2]string) (*Settings, error) {
+	s := &Settings{files: make([]settingsFile, 0, len(files))}
+	for _, f := range files {
+		sf := settingsFile{level: f[0], fname: f[1]}
+		if path_exists(sf.fname) {
+			cfg, err := config.ReadDefault(sf.fname)
+			if err != nil {
+				return nil, fmt.Errorf("lbpkr: could not read [%s]: %v", sf.fname, err)
+			}
+			for _, k := range settingKeys {
+				if !cfg.HasOption(settingsSection, k.name) || k.check == nil {
+					continue
+				}
+				v, err := cfg.String(settingsSection, k.name)
+				if err != nil {
+					return nil, err
+				}
+				if err := k.check(strings.TrimSpace(v)); err != nil {
+					return nil, fmt.Errorf("lbpkr: invalid %s value %q in [%s]: %v", k.name, v, sf.fname, err)
+				}
+			}
+			sf.cfg = cfg
+		}
+		s.files = append(s.files, sf)
+	}
+	return s, nil
+}
+
+// Lookup returns the value of a setting, and where it comes from (an
+// environment variable or a file).
+func (s *Settings) Lookup(name string) (value, source string, ok bool) {
+	k, err := findSettingKey(name)
+	if err != nil {
+		return "", "", false
+	}
+	if k.env != "" {
+		if v := os.Getenv(k.env); v != "" {
+			if k.name == "siteroot" {
+				v = strings.Split(v, string(os.PathListSeparator))[0]
+			}
+			return v, "$" + k.env, true
+		}
+	}
+	for i := len(s.files) - 1; i >= 0; i-- {
+		f := s.files[i]
+		if f.cfg == nil || !f.cfg.HasOption(settingsSection, name) || !k.allowed(f.level) {
+			continue
+		}
+		v, err := f.cfg.String(settingsSection, name)
+		if err != nil {
+			continue
+		}
+		return strings.TrimSpace(v), f.fname, true
+	}
+	return "", "", false
+}
+
+// Get returns the value of a setting, or "" if it is not set
+func (s *Settings) Get(name string) string {
+	v, _, _ := s.Lookup(name)
+	return v
+}
+
+// file returns the lbpkr.conf file of a given level
+func (s *Settings) file(level string) (*settingsFile, error) {
+	for i := range s.files {
+		if s.files[i].level == level {
+			return &s.files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("lbpkr: no lbpkr.conf file for level %q (expected %s|%s|%s)",
+		level, SystemLevel, SiterootLevel, UserLevel,
+	)
+}
+
+// Set writes a setting into the lbpkr.conf file of a given level.
+// An empty value removes the setting from the file.
+func (s *Settings) Set(level, name, value string) error {
+	k, err := findSettingKey(name)
+	if err != nil {
+		return err
+	}
+	if !k.allowed(level) {
+		return fmt.Errorf("lbpkr: %s can only be set at the %s level(s)", name, strings.Join(k.levels, "|"))
+	}
+	if value != "" && k.check != nil {
+		if err := k.check(value); err != nil {
+			return fmt.Errorf("lbpkr: invalid %s value %q: %v", name, value, err)
+		}
+	}
+
+	f, err := s.file(level)
+	if err != nil {
+		return err
+	}
+	if f.cfg == nil {
+		f.cfg = config.NewDefault()
+	}
+	if value == "" {
+		f.cfg.RemoveOption(settingsSection, name)
+	} else {
+		f.cfg.AddOption(settingsSection, name, value)
+	}
+
+	err = os.MkdirAll(filepath.Dir(f.fname), 0755)
+	if err != nil {
+		return err
+	}
+	return f.cfg.WriteFile(f.fname, 0644, "lbpkr configuration")
+}
+
+// SettingInfo is the value of a setting, as displayed by the config command
+type SettingInfo struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source,omitempty"` // environment variable or file
+	Env    string `json:"env,omitempty"`    // environment variable overriding the files
+	Doc    string `json:"doc"`
+}
+
+// List returns the value of all the settings
+func (s *Settings) List() settingInfos {
+	infos := make(settingInfos, 0, len(settingKeys))
+	for _, k := range settingKeys {
+		v, src, _ := s.Lookup(k.name)
+		infos = append(infos, SettingInfo{Name: k.name, Value: v, Source: src, Env: k.env, Doc: k.doc})
+	}
+	return infos
+}
+
+type settingInfos []SettingInfo
+
+func (p settingInfos) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, s := range p {
+		src := ""
+		if s.Source != "" {
+			src = "(" + s.Source + ")"
+		}
+		fmt.Fprintf(tw, "%s\t= %s\t%s\n", s.Name, s.Value, src)
+	}
+	return tw.Flush()
+}
+
+// applySettings sets the default options of a command (-siteroot, -type,
+// -format and -lock-timeout) which are not given on the command line from
+// the environment and the lbpkr.conf files.
+func applySettings(cmd *commander.Command) error {
+	set := make(map[string]bool)
+	cmd.Flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	s, err := loadSettings("")
+	if err != nil {
+		return err
+	}
+	if !set["siteroot"] {
+		if v := s.Get("siteroot"); v != "" {
+			err = cmd.Flag.Set("siteroot", v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	siteroot := cmd.Flag.Lookup("siteroot").Value.Get().(string)
+	if siteroot != "" {
+		s, err = loadSettings(siteroot)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"type", "format", "lock-timeout"} {
+		if set[name] {
+			continue
+		}
+		v, src, ok := s.Lookup(name)
+		if !ok {
+			continue
+		}
+		err = cmd.Flag.Set(name, v)
+		if err != nil {
+			return fmt.Errorf("lbpkr: invalid %s value %q (%s): %v", name, v, src, err)
+		}
+	}
+	return nil
+}
+
+// applySettings applies the settings of the siteroot to the context:
+// concurrency of downloads and HTTP proxy and timeout.
+func (ctx *Context) applySettings() error {
+	var err error
+	if v, src, ok := ctx.settings.Lookup("ndls"); ok {
+		ctx.ndls, err = strconv.Atoi(v)
+		if err != nil || ctx.ndls <= 0 {
+			return fmt.Errorf("lbpkr: invalid ndls value %q (%s)", v, src)
+		}
+	}
+
+	if v, src, ok := ctx.settings.Lookup("timeout"); ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("lbpkr: invalid timeout value %q (%s): %v", v, src, err)
+		}
+		http.DefaultClient.Timeout = timeout
+	}
+
+	if v, src, ok := ctx.settings.Lookup("proxy"); ok {
+		proxy, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("lbpkr: invalid proxy value %q (%s): %v", v, src, err)
+		}
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			t.Proxy = http.ProxyURL(proxy)
+		}
+	}
+	return nil
+}
+
+// checkRelocateChange checks that the relocation rules of a siteroot can be
+// changed: files already installed were relocated with the current rules.
+func checkRelocateChange(siteroot string) error {
+	if checkSiteroot(siteroot) != nil {
+		// no RPM database yet
+		return nil
+	}
+	installed, err := queryInstalledPackages(siteroot)
+	if err != nil {
+		return err
+	}
+	if len(installed) > 0 {
+		return fmt.Errorf("lbpkr: siteroot [%s] already holds %d packages, relocated with the current rules", siteroot, len(installed))
+	}
+	return nil
+}
+
+// defaultPlatforms returns the default platforms of projects, in the
+// RPM-platform form (x86_64_slc6_gcc48_opt): $CMTCONFIG, or the platforms
+// setting.
+func (ctx *Context) defaultPlatforms() string {
+	return strings.Replace(ctx.settings.Get("platforms"), "-", "_", -1)
+}
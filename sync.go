@@ -0,0 +1,767 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gonuts/logger"
+	"github.com/lhcb-org/lbpkr/yum"
+	"gopkg.in/yaml.v2"
+)
+
+// Site is the declarative specification of a siteroot: the repositories to
+// configure, the projects and RPMs to install and the RPMs locked to a given
+// version.
+type Site struct {
+	Repositories []SiteRepository `yaml:"repositories" json:"repositories"`
+	Projects     []SiteProject    `yaml:"projects" json:"projects"`
+	RPMs         []SiteRPM        `yaml:"rpms" json:"rpms"`
+	Locks        []SiteLock       `yaml:"locks" json:"locks"`
+	Prune        bool             `yaml:"prune" json:"prune"` // remove the RPMs which are not declared
+}
+
+// SiteRepository is a yum repository of a site
+type SiteRepository struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+}
+
+// SiteProject is a project of a site, with a range of versions
+// (ex: ">=v25r0, <v26r0") and a list of platforms (all of them if empty).
+type SiteProject struct {
+	Name      string   `yaml:"name" json:"name"`
+	Versions  string   `yaml:"versions" json:"versions"`
+	Platforms []string `yaml:"platforms" json:"platforms"`
+}
+
+// SiteRPM is a RPM of a site, with a range of versions (ex: ">=8.3.0")
+type SiteRPM struct {
+	Name     string `yaml:"name" json:"name"`
+	Versions string `yaml:"versions" json:"versions"`
+}
+
+// SiteLock pins a RPM to a version (and release)
+type SiteLock struct {
+	Name    string `yaml:"name" json:"name"`
+	Version string `yaml:"version" json:"version"`
+	Release string `yaml:"release" json:"release"`
+}
+
+// ReadSite reads and validates a site specification file
+func ReadSite(fname string) (*Site, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var site Site
+	err = yaml.UnmarshalStrict(data, &site)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: invalid site specification [%s]: %v", fname, err)
+	}
+	err = site.validate()
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: invalid site specification [%s]: %v", fname, err)
+	}
+	return &site, nil
+}
+
+func (site *Site) validate() error {
+	repos := make(map[string]struct{}, len(site.Repositories))
+	for _, repo := range site.Repositories {
+		if repo.Name == "" || repo.URL == "" {
+			return fmt.Errorf("repository with no name or url (name=%q url=%q)", repo.Name, repo.URL)
+		}
+		if _, dup := repos[repo.Name]; dup {
+			return fmt.Errorf("repository %q declared twice", repo.Name)
+		}
+		repos[repo.Name] = struct{}{}
+	}
+	for _, p := range site.Projects {
+		if p.Name == "" {
+			return fmt.Errorf("project with no name")
+		}
+		if _, err := parseVersionRange(p.Versions); err != nil {
+			return fmt.Errorf("project %s: %v", p.Name, err)
+		}
+	}
+	names := make(map[string]string)
+	for _, rpm := range site.RPMs {
+		if rpm.Name == "" {
+			return fmt.Errorf("rpm with no name")
+		}
+		if _, err := parseVersionRange(rpm.Versions); err != nil {
+			return fmt.Errorf("rpm %s: %v", rpm.Name, err)
+		}
+		if _, dup := names[rpm.Name]; dup {
+			return fmt.Errorf("rpm %q declared twice", rpm.Name)
+		}
+		names[rpm.Name] = "rpm"
+	}
+	for _, lock := range site.Locks {
+		if lock.Name == "" || lock.Version == "" {
+			return fmt.Errorf("lock with no name or version (name=%q version=%q)", lock.Name, lock.Version)
+		}
+		if kind, dup := names[lock.Name]; dup {
+			return fmt.Errorf("%q declared twice (as a lock and as a %s)", lock.Name, kind)
+		}
+		names[lock.Name] = "lock"
+	}
+	return nil
+}
+
+// versionConstraint is a comparison with a version (ex: ">=v25r0")
+type versionConstraint struct {
+	op      string
+	version string
+}
+
+// versionRange is a list of constraints all versions of the range satisfy
+type versionRange []versionConstraint
+
+// parseVersionRange parses a comma-separated list of constraints.
+// A constraint without operator is an exact match.
+// An empty range (or "*") holds all versions.
+func parseVersionRange(s string) (versionRange, error) {
+	var r versionRange
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return r, nil
+	}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		op := "=="
+		for _, o := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+			if strings.HasPrefix(c, o) {
+				op = o
+				c = strings.TrimSpace(c[len(o):])
+				break
+			}
+		}
+		if op == "=" {
+			op = "=="
+		}
+		if c == "" {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+		r = append(r, versionConstraint{op: op, version: c})
+	}
+	return r, nil
+}
+
+// Contains returns whether version satisfies all the constraints of the range
+func (r versionRange) Contains(version string) bool {
+	for _, c := range r {
+		cmp := compareVersions(version, c.version)
+		var ok bool
+		switch c.op {
+		case "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r versionRange) String() string {
+	if len(r) == 0 {
+		return "*"
+	}
+	cs := make([]string, 0, len(r))
+	for _, c := range r {
+		cs = append(cs, c.op+c.version)
+	}
+	return strings.Join(cs, ",")
+}
+
+// compareVersions compares two versions the way rpm does: versions are split
+// into runs of digits (compared as numbers) and runs of letters (compared
+// alphabetically), so that v9r1 < v10r0 and v25r2 < v25r2p1.
+// compareVersions returns -1, 0 or 1 if a is older, equal or newer than b.
+func compareVersions(a, b string) int {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	isAlpha := func(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
+	trim := func(s string) string {
+		for s != "" && !isDigit(s[0]) && !isAlpha(s[0]) {
+			s = s[1:]
+		}
+		return s
+	}
+	segment := func(s string, digits bool) (string, string) {
+		i := 0
+		for i < len(s) && ((digits && isDigit(s[i])) || (!digits && isAlpha(s[i]))) {
+			i++
+		}
+		return s[:i], s[i:]
+	}
+
+	for {
+		a, b = trim(a), trim(b)
+		if a == "" || b == "" {
+			break
+		}
+		digits := isDigit(a[0])
+		var sa, sb string
+		sa, a = segment(a, digits)
+		sb, b = segment(b, digits)
+		if sb == "" {
+			// segments of different kinds: numbers are newer
+			if digits {
+				return 1
+			}
+			return -1
+		}
+		if digits {
+			sa = strings.TrimLeft(sa, "0")
+			sb = strings.TrimLeft(sb, "0")
+			if len(sa) != len(sb) {
+				if len(sa) < len(sb) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if sa != sb {
+			if sa < sb {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// Kinds of actions of a sync plan
+const (
+	syncAddRepo   = "add-repo"
+	syncInstall   = "install"
+	syncUpdate    = "update"
+	syncDowngrade = "downgrade"
+	syncRemove    = "remove"
+)
+
+// SyncAction is a step converging a siteroot towards its specification
+type SyncAction struct {
+	Action string `json:"action"` // add-repo, install, update, downgrade or remove
+	Name   string `json:"name"`
+	Old    string `json:"old,omitempty"` // installed version-release
+	New    string `json:"new,omitempty"` // target version-release (or repository URL)
+
+	pkg *yum.Package // package to install (install, update, downgrade)
+	rpm [3]string    // installed package (remove)
+}
+
+// SyncPlan lists the actions converging a siteroot towards its specification
+type SyncPlan struct {
+	Siteroot string       `json:"siteroot"`
+	Actions  []SyncAction `json:"actions"`
+}
+
+// Empty returns whether the siteroot already matches its specification
+func (plan *SyncPlan) Empty() bool {
+	return len(plan.Actions) == 0
+}
+
+func (plan *SyncPlan) writeText(w io.Writer) error {
+	if plan.Empty() {
+		_, err := fmt.Fprintf(w, "** siteroot [%s] is in sync **\n", plan.Siteroot)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, a := range plan.Actions {
+		switch a.Action {
+		case syncAddRepo, syncInstall:
+			fmt.Fprintf(tw, "+ %s\t%s\t%s\n", a.Action, a.Name, a.New)
+		case syncRemove:
+			fmt.Fprintf(tw, "- %s\t%s\t%s\n", a.Action, a.Name, a.Old)
+		default:
+			fmt.Fprintf(tw, "~ %s\t%s\t%s -> %s\n", a.Action, a.Name, a.Old, a.New)
+		}
+	}
+	return tw.Flush()
+}
+
+// planRepositories plans the addition of the repositories of site which are
+// not configured yet.
+func (ctx *Context) planRepositories(site *Site) ([]SyncAction, error) {
+	repos, err := ctx.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]string, len(repos))
+	for _, repo := range repos {
+		urls[repo.Name] = repo.URL
+	}
+
+	actions := make([]SyncAction, 0)
+	for _, repo := range site.Repositories {
+		if url, ok := urls[repo.Name]; ok {
+			if url != repo.URL {
+				ctx.msg.Warnf("repository [%s] has URL %q (site: %q)\n", repo.Name, url, repo.URL)
+			}
+			continue
+		}
+		actions = append(actions, SyncAction{Action: syncAddRepo, Name: repo.Name, New: repo.URL})
+	}
+	return actions, nil
+}
+
+// loadRepositories replaces the yum client of ctx with one also serving the
+// repositories of the add-repo actions, without configuring them.
+func (ctx *Context) loadRepositories(actions []SyncAction) error {
+	urls := make(map[string]string, len(actions))
+	for _, a := range actions {
+		url, err := sanitizePathOrURL(a.New)
+		if err != nil {
+			return err
+		}
+		urls[a.Name] = url
+	}
+
+	client, err := yum.NewWithRepositories(ctx.siteroot, urls)
+	if err != nil {
+		return err
+	}
+	if ctx.msg.Level() < logger.INFO {
+		client.SetLevel(logger.DEBUG)
+	}
+	err = ctx.yum.Close()
+	ctx.yum = client
+	return err
+}
+
+// PlanSync computes the actions converging the siteroot towards site: missing
+// repositories are added, missing RPMs are installed, RPMs with an installed
+// version out of range are updated (or downgraded) and, if prune is true, the
+// RPMs which are neither declared nor required are removed.
+// The RPMs of the missing repositories are taken into account, but these
+// repositories are only added to the configuration by ApplySync.
+func (ctx *Context) PlanSync(site *Site, prune bool) (*SyncPlan, error) {
+	repos, err := ctx.planRepositories(site)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) > 0 {
+		err = ctx.loadRepositories(repos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{Siteroot: ctx.siteroot, Actions: repos}
+	keep := make(map[string]struct{}) // names of the declared installed RPMs
+
+	for _, p := range site.Projects {
+		vrange, err := parseVersionRange(p.Versions)
+		if err != nil {
+			return nil, err
+		}
+		actions, err := ctx.planProject(p, vrange, installed, keep)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, actions...)
+	}
+
+	for _, rpm := range site.RPMs {
+		vrange, err := parseVersionRange(rpm.Versions)
+		if err != nil {
+			return nil, err
+		}
+		action, err := ctx.planRPM(rpm.Name, vrange.String(), func(pkg yum.RPM) bool {
+			return vrange.Contains(pkg.Version())
+		}, installed, keep)
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			plan.Actions = append(plan.Actions, *action)
+		}
+	}
+
+	for _, lock := range site.Locks {
+		lock := lock
+		action, err := ctx.planRPM(lock.Name, "=="+lock.Version, func(pkg yum.RPM) bool {
+			return pkg.Version() == lock.Version && (lock.Release == "" || pkg.Release() == lock.Release)
+		}, installed, keep)
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			plan.Actions = append(plan.Actions, *action)
+		}
+	}
+
+	if prune || site.Prune {
+		plan.Actions = append(plan.Actions, ctx.planPrune(plan.Actions, installed, keep)...)
+	}
+	return plan, nil
+}
+
+// planProject plans the installation of the latest version in range of a
+// project, for each of its platforms with no installed version in range.
+// The installed versions out of range of these platforms are removed.
+func (ctx *Context) planProject(p SiteProject, vrange versionRange, installed [][3]string, keep map[string]struct{}) ([]SyncAction, error) {
+	versions, err := ctx.projectVersions(p.Name, vrange)
+	if err != nil {
+		return nil, err
+	}
+
+	platforms := make([]string, 0, len(p.Platforms))
+	for _, plat := range p.Platforms {
+		// accept CMTCONFIG-like platforms (x86_64-slc6-gcc48-opt)
+		platforms = append(platforms, strings.Replace(plat, "-", "_", -1))
+	}
+	if len(platforms) == 0 {
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("lbpkr: no version of project %s in range %q", p.Name, vrange)
+		}
+		platforms, err = ctx.projectPlatforms(p.Name, versions[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	actions := make([]SyncAction, 0, len(platforms))
+	for _, plat := range platforms {
+		re := regexp.MustCompile("^" + regexp.QuoteMeta(p.Name) + "_([^_]+)_" + regexp.QuoteMeta(plat) + "$")
+		var old []string
+		var oldpkgs [][3]string
+		inrange := false
+		for _, pkg := range installed {
+			sub := re.FindStringSubmatch(pkg[0])
+			if len(sub) == 0 {
+				continue
+			}
+			if vrange.Contains(sub[1]) {
+				keep[pkg[0]] = struct{}{}
+				inrange = true
+				continue
+			}
+			old = append(old, sub[1])
+			oldpkgs = append(oldpkgs, pkg)
+		}
+		if inrange {
+			continue
+		}
+
+		var pkg *yum.Package
+		for _, v := range versions {
+			pkg, err = ctx.yum.FindLatestMatchingName(p.Name+"_"+v+"_"+plat, "", "")
+			if err == nil && pkg != nil {
+				break
+			}
+			pkg = nil
+		}
+		if pkg == nil {
+			return nil, fmt.Errorf("lbpkr: no version of project %s in range %q for platform %s", p.Name, vrange, plat)
+		}
+
+		keep[pkg.Name()] = struct{}{}
+		action := SyncAction{Action: syncInstall, Name: pkg.Name(), New: pkg.Version() + "-" + pkg.Release(), pkg: pkg}
+		if len(old) > 0 {
+			// another version of the project replaces the installed ones,
+			// which are removed once it is installed
+			sort.Strings(old)
+			action.Action = syncUpdate
+			action.Name = p.Name + " (" + plat + ")"
+			action.Old = strings.Join(old, ",")
+			action.New = strings.TrimSuffix(strings.TrimPrefix(pkg.Name(), p.Name+"_"), "_"+plat)
+		}
+		actions = append(actions, action)
+
+		remove := make([]SyncAction, 0, len(oldpkgs))
+		for _, old := range oldpkgs {
+			remove = append(remove, SyncAction{Action: syncRemove, Name: old[0], Old: old[1] + "-" + old[2], rpm: old})
+		}
+		sort.Sort(syncActions(remove))
+		actions = append(actions, remove...)
+	}
+	return actions, nil
+}
+
+// projectVersions returns the available versions of a project in a range,
+// newest first.
+func (ctx *Context) projectVersions(name string, vrange versionRange) ([]string, error) {
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(name) + "_(.+)_index$")
+	pkgs, err := ctx.yum.ListPackages(re.String(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]struct{})
+	versions := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		sub := re.FindStringSubmatch(pkg.Name())
+		if len(sub) == 0 || !vrange.Contains(sub[1]) {
+			continue
+		}
+		if _, dup := set[sub[1]]; dup {
+			continue
+		}
+		set[sub[1]] = struct{}{}
+		versions = append(versions, sub[1])
+	}
+	sort.Sort(sort.Reverse(versionList(versions)))
+	return versions, nil
+}
+
+// projectPlatforms returns the available platforms of a project version
+func (ctx *Context) projectPlatforms(name, version string) ([]string, error) {
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(name+"_"+version) + "_(.+)$")
+	pkgs, err := ctx.yum.ListPackages(re.String(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]struct{})
+	platforms := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		sub := re.FindStringSubmatch(pkg.Name())
+		if len(sub) == 0 || sub[1] == "index" {
+			continue
+		}
+		if _, dup := set[sub[1]]; dup {
+			continue
+		}
+		set[sub[1]] = struct{}{}
+		platforms = append(platforms, sub[1])
+	}
+	sort.Strings(platforms)
+	return platforms, nil
+}
+
+// planRPM plans the installation of the latest available version of the RPM
+// name for which match returns true, unless such a version is installed.
+func (ctx *Context) planRPM(name, vrange string, match func(yum.RPM) bool, installed [][3]string, keep map[string]struct{}) (*SyncAction, error) {
+	var cur yum.RPM
+	for _, pkg := range installed {
+		if pkg[0] != name {
+			continue
+		}
+		rpm := yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil)
+		if match(rpm) {
+			keep[name] = struct{}{}
+			return nil, nil
+		}
+		if cur == nil || yum.RPMLessThan(cur, rpm) {
+			cur = rpm
+		}
+	}
+
+	pkgs, err := ctx.yum.ListPackages("^"+regexp.QuoteMeta(name)+"$", "", "")
+	if err != nil {
+		return nil, err
+	}
+	var target *yum.Package
+	for _, pkg := range pkgs {
+		if !match(pkg) {
+			continue
+		}
+		if target == nil || compareVersions(target.Version(), pkg.Version()) < 0 ||
+			(target.Version() == pkg.Version() && compareVersions(target.Release(), pkg.Release()) < 0) {
+			target = pkg
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("lbpkr: no version of %s in range %q available", name, vrange)
+	}
+
+	keep[name] = struct{}{}
+	action := &SyncAction{Action: syncInstall, Name: name, New: target.Version() + "-" + target.Release(), pkg: target}
+	if cur != nil {
+		action.Action = syncUpdate
+		action.Old = cur.Version() + "-" + cur.Release()
+		if compareVersions(target.Version(), cur.Version()) < 0 ||
+			(target.Version() == cur.Version() && compareVersions(target.Release(), cur.Release()) < 0) {
+			action.Action = syncDowngrade
+		}
+	}
+	return action, nil
+}
+
+// planPrune plans the removal of the installed RPMs which are neither
+// declared (keep) nor required by a declared RPM.
+func (ctx *Context) planPrune(actions []SyncAction, installed [][3]string, keep map[string]struct{}) []SyncAction {
+	rpms := make(map[string]yum.RPM, len(installed))
+	for _, pkg := range installed {
+		rpms[pkg[0]] = yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil)
+	}
+
+	// dependencies of each installed RPM
+	deps := make(map[string][]string)
+	for dep, parents := range ctx.installedRequirers(rpms) {
+		for _, parent := range parents {
+			deps[parent] = append(deps[parent], dep)
+		}
+	}
+
+	required := make(map[string]struct{}, len(keep))
+	todo := make([]string, 0, len(keep))
+	for name := range keep {
+		todo = append(todo, name)
+	}
+	// installed dependencies of the RPMs about to be installed
+	for _, a := range actions {
+		if a.pkg == nil {
+			continue
+		}
+		pdeps, err := ctx.yum.PackageDeps(a.pkg, -1)
+		if err != nil {
+			ctx.msg.Warnf("could not retrieve the dependencies of %s: %v\n", a.pkg.RPMName(), err)
+			continue
+		}
+		for _, dep := range pdeps {
+			todo = append(todo, dep.Name())
+		}
+	}
+	for len(todo) > 0 {
+		name := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if _, dup := required[name]; dup {
+			continue
+		}
+		required[name] = struct{}{}
+		todo = append(todo, deps[name]...)
+	}
+
+	// RPMs already removed by the plan
+	removed := make(map[string]struct{})
+	for _, a := range actions {
+		if a.Action == syncRemove {
+			removed[a.rpm[0]] = struct{}{}
+		}
+	}
+
+	remove := make([]SyncAction, 0)
+	for _, pkg := range installed {
+		if _, ok := required[pkg[0]]; ok || pkg[0] == "lbpkr" {
+			continue
+		}
+		if _, ok := removed[pkg[0]]; ok {
+			continue
+		}
+		remove = append(remove, SyncAction{Action: syncRemove, Name: pkg[0], Old: pkg[1] + "-" + pkg[2], rpm: pkg})
+	}
+	sort.Sort(syncActions(remove))
+	return remove
+}
+
+// ApplySync runs the add-repo, install, update, downgrade and remove actions
+// of a plan
+func (ctx *Context) ApplySync(plan *SyncPlan) error {
+	var err error
+	repos := make([]SyncAction, 0)
+	install := make([]Package, 0)
+	remove := make([][3]string, 0)
+	for _, a := range plan.Actions {
+		switch a.Action {
+		case syncAddRepo:
+			repos = append(repos, a)
+		case syncInstall:
+			install = append(install, Package{a.pkg, InstallMode})
+		case syncUpdate:
+			mode := UpgradeMode
+			if a.pkg.Name() != a.Name {
+				// new version of a project: a package of its own
+				mode = InstallMode
+			}
+			install = append(install, Package{a.pkg, mode})
+		case syncRemove:
+			remove = append(remove, a.rpm)
+		}
+	}
+	if len(repos) == 0 && len(install) == 0 && len(remove) == 0 && !plan.hasDowngrades() {
+		return nil
+	}
+
+	err = ctx.confirm("is this ok?")
+	if err != nil {
+		return err
+	}
+	// the whole plan was confirmed: do not ask again for each of its steps
+	yes := ctx.prompt.yes
+	ctx.prompt.yes = true
+	defer func() { ctx.prompt.yes = yes }()
+
+	for _, a := range repos {
+		err = ctx.AddRepository(a.Name, a.New)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(install) > 0 {
+		err = ctx.InstallPackages(install)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, a := range plan.Actions {
+		if a.Action != syncDowngrade {
+			continue
+		}
+		err = ctx.Downgrade(a.pkg.Name(), a.pkg.Version(), a.pkg.Release())
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 {
+		ctx.msg.Infof("removing %d RPMs not declared by the site\n", len(remove))
+		err = ctx.RemoveRPM(remove, false)
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (plan *SyncPlan) hasDowngrades() bool {
+	for _, a := range plan.Actions {
+		if a.Action == syncDowngrade {
+			return true
+		}
+	}
+	return false
+}
+
+type syncActions []SyncAction
+
+func (p syncActions) Len() int           { return len(p) }
+func (p syncActions) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p syncActions) Less(i, j int) bool { return p[i].Name < p[j].Name }
+
+// versionList sorts versions, oldest first
+type versionList []string
+
+func (p versionList) Len() int           { return len(p) }
+func (p versionList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p versionList) Less(i, j int) bool { return compareVersions(p[i], p[j]) < 0 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+// Action describes what a transaction does with a package
+type Action int
+
+const (
+	InstallAction   Action = iota // package is installed anew
+	UpdateAction                  // package replaces an installed version
+	InstalledAction               // package is already installed: nothing to do
+	DowngradeAction               // package replaces a newer installed version
+)
+
+func (a Action) String() string {
+	switch a {
+	case InstallAction:
+		return "install"
+	case UpdateAction:
+		return "update"
+	case InstalledAction:
+		return "installed"
+	case DowngradeAction:
+		return "downgrade"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// TransactionItem is a package taking part in a transaction
+type TransactionItem struct {
+	Package Package
+	Action  Action
+	Cached  bool // RPM file already downloaded
+}
+
+// Transaction is the set of packages an install or update will process
+type Transaction struct {
+	Items []TransactionItem
+}
+
+// newTransaction creates the transaction installing pkgs into the siteroot
+func (ctx *Context) newTransaction(pkgs []Package) *Transaction {
+	tx := &Transaction{Items: make([]TransactionItem, 0, len(pkgs))}
+	for _, pkg := range pkgs {
+		tx.Items = append(tx.Items, ctx.newTransactionItem(pkg))
+	}
+	sort.Sort(transactionItems(tx.Items))
+	return tx
+}
+
+// newTransactionItem creates the transaction item installing pkg into the siteroot
+func (ctx *Context) newTransactionItem(pkg Package) TransactionItem {
+	item := TransactionItem{Package: pkg}
+	switch {
+	case ctx.isRPMInstalled(pkg.Name(), pkg.Version(), pkg.Release()):
+		item.Action = InstalledAction
+	case pkg.Mode.Has(UpdateMode) || pkg.Mode.Has(UpgradeMode) ||
+		ctx.isRPMInstalled(pkg.Name(), "", ""):
+		item.Action = UpdateAction
+	}
+	fi, err := os.Stat(filepath.Join(ctx.tmpdir, pkg.RPMFileName()))
+	if err == nil && fi.Size() == pkg.PackageSize() {
+		item.Cached = true
+	}
+	return item
+}
+
+// Count returns the number of packages for a given action
+func (tx *Transaction) Count(action Action) int {
+	n := 0
+	for _, item := range tx.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// DownloadSize returns the number of bytes still to be downloaded
+func (tx *Transaction) DownloadSize() int64 {
+	size := int64(0)
+	for _, item := range tx.Items {
+		if item.Action == InstalledAction || item.Cached {
+			continue
+		}
+		size += item.Package.PackageSize()
+	}
+	return size
+}
+
+// InstalledSize returns the number of bytes the transaction will install
+func (tx *Transaction) InstalledSize() int64 {
+	size := int64(0)
+	for _, item := range tx.Items {
+		if item.Action == InstalledAction {
+			continue
+		}
+		size += item.Package.InstalledSize()
+	}
+	return size
+}
+
+// preview displays the content of the transaction
+func (tx *Transaction) preview(ctx *Context) {
+	ctx.msg.Infof("found %d RPMs to install:\n", len(tx.Items))
+
+	buf := new(bytes.Buffer)
+	tw := tabwriter.NewWriter(buf, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "\t#\tpackage\taction\tdownload\tinstalled\n")
+	for i, item := range tx.Items {
+		pkg := item.Package
+		dl := humanSize(pkg.PackageSize())
+		switch {
+		case item.Action == InstalledAction:
+			dl = "-"
+		case item.Cached:
+			dl = "(cached)"
+		}
+		fmt.Fprintf(tw, "\t[%03d/%03d]\t%s\t%v\t%s\t%s\n",
+			i+1, len(tx.Items),
+			pkg.RPMName(),
+			item.Action,
+			dl,
+			humanSize(pkg.InstalledSize()),
+		)
+	}
+	tw.Flush()
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		ctx.msg.Infof("%s\n", line)
+	}
+
+	summary := fmt.Sprintf("%d to install, %d to update",
+		tx.Count(InstallAction), tx.Count(UpdateAction),
+	)
+	if n := tx.Count(DowngradeAction); n > 0 {
+		summary += fmt.Sprintf(", %d to downgrade", n)
+	}
+	ctx.msg.Infof("%s, %d already installed\n", summary, tx.Count(InstalledAction))
+	ctx.msg.Infof("total download size: %s\n", humanSize(tx.DownloadSize()))
+	ctx.msg.Infof("total installed size: %s\n", humanSize(tx.InstalledSize()))
+}
+
+// checkDiskSpace checks there is enough free space in the tmp directory to
+// download the RPMs and in the siteroot to install them.
+func (tx *Transaction) checkDiskSpace(ctx *Context) error {
+	installed := tx.InstalledSize()
+	if ctx.options.JustDb || ctx.options.DlOnly {
+		installed = 0
+	}
+	return checkDiskSpace([]diskRequirement{
+		{dir: ctx.tmpdir, size: tx.DownloadSize()},
+		{dir: ctx.siteroot, size: installed},
+	})
+}
+
+type transactionItems []TransactionItem
+
+func (p transactionItems) Len() int      { return len(p) }
+func (p transactionItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p transactionItems) Less(i, j int) bool {
+	return p[i].Package.RPMName() < p[j].Package.RPMName()
+}
+
+// diskRequirement is the number of bytes needed under a directory
+type diskRequirement struct {
+	dir  string
+	size int64
+}
+
+// checkDiskSpace checks the file systems holding the requirements' directories
+// have enough free space.
+// requirements for directories on the same file system are added up.
+func checkDiskSpace(reqs []diskRequirement) error {
+	type fsreq struct {
+		dirs []string
+		need int64
+		free int64
+	}
+	devs := make(map[uint64]*fsreq)
+	order := make([]uint64, 0, len(reqs))
+	for _, req := range reqs {
+		var st syscall.Stat_t
+		err := syscall.Stat(req.dir, &st)
+		if err != nil {
+			return fmt.Errorf("lbpkr: could not stat %q: %v", req.dir, err)
+		}
+		dev := uint64(st.Dev)
+		fs, ok := devs[dev]
+		if !ok {
+			var sfs syscall.Statfs_t
+			err = syscall.Statfs(req.dir, &sfs)
+			if err != nil {
+				return fmt.Errorf("lbpkr: could not statfs %q: %v", req.dir, err)
+			}
+			fs = &fsreq{free: int64(sfs.Bavail) * int64(sfs.Bsize)}
+			devs[dev] = fs
+			order = append(order, dev)
+		}
+		fs.dirs = append(fs.dirs, req.dir)
+		fs.need += req.size
+	}
+
+	for _, dev := range order {
+		fs := devs[dev]
+		if fs.need <= fs.free {
+			continue
+		}
+		return fmt.Errorf(
+			"lbpkr: not enough disk space for %s: need %s, %s available (short by %s)",
+			strings.Join(fs.dirs, " and "),
+			humanSize(fs.need), humanSize(fs.free), humanSize(fs.need-fs.free),
+		)
+	}
+	return nil
+}
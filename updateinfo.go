@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// advisoryFilter selects advisories from the updateinfo metadata of the repositories
+type advisoryFilter struct {
+	security bool     // only select security advisories
+	severity string   // only select advisories with this severity
+	ids      []string // only select these advisories
+}
+
+// empty returns whether the filter selects all advisories
+func (f advisoryFilter) empty() bool {
+	return !f.security && f.severity == "" && len(f.ids) == 0
+}
+
+func (f advisoryFilter) match(adv *yum.Advisory) bool {
+	if f.security && !adv.IsSecurity() {
+		return false
+	}
+	if f.severity != "" && !strings.EqualFold(f.severity, adv.Severity) {
+		return false
+	}
+	if len(f.ids) == 0 {
+		return true
+	}
+	for _, id := range f.ids {
+		if id == adv.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAdvisories splits a comma-separated list of advisory IDs
+func splitAdvisories(str string) []string {
+	var ids []string
+	for _, id := range strings.Split(str, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// FilterAdvisories restricts updates to the packages fixed by the selected advisories
+func FilterAdvisories(security bool, severity string, ids []string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.advisories = advisoryFilter{
+			security: security,
+			severity: severity,
+			ids:      ids,
+		}
+	}
+}
+
+// AdvisoryInfo is the description of an advisory, as displayed by query commands
+type AdvisoryInfo struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Severity    string          `json:"severity,omitempty"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Issued      string          `json:"issued,omitempty"`
+	Updated     string          `json:"updated,omitempty"`
+	Repository  string          `json:"repository"`
+	References  []yum.Reference `json:"references"`
+	Packages    []string        `json:"packages"`
+	Installed   []string        `json:"installed"` // installed packages affected by the advisory
+}
+
+type advisoryInfos []AdvisoryInfo
+
+func (p advisoryInfos) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, adv := range p {
+		severity := adv.Severity
+		if severity == "" {
+			severity = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", adv.ID, adv.Type, severity, strings.Join(adv.Installed, ", "))
+	}
+	return tw.Flush()
+}
+
+// advisoryDetails displays the full description of advisories
+type advisoryDetails []AdvisoryInfo
+
+func (p advisoryDetails) writeText(w io.Writer) error {
+	for i, adv := range p {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, "advisory:    %s\n", adv.ID)
+		fmt.Fprintf(w, "title:       %s\n", adv.Title)
+		fmt.Fprintf(w, "type:        %s\n", adv.Type)
+		if adv.Severity != "" {
+			fmt.Fprintf(w, "severity:    %s\n", adv.Severity)
+		}
+		fmt.Fprintf(w, "issued:      %s\n", adv.Issued)
+		if adv.Updated != "" {
+			fmt.Fprintf(w, "updated:     %s\n", adv.Updated)
+		}
+		fmt.Fprintf(w, "repository:  %s\n", adv.Repository)
+		for _, ref := range adv.References {
+			fmt.Fprintf(w, "reference:   %s (%s) %s\n", ref.ID, ref.Type, ref.URL)
+		}
+		for _, pkg := range adv.Packages {
+			fmt.Fprintf(w, "package:     %s\n", pkg)
+		}
+		for _, pkg := range adv.Installed {
+			fmt.Fprintf(w, "affects:     %s\n", pkg)
+		}
+		if adv.Description != "" {
+			fmt.Fprintf(w, "description:\n%s\n", adv.Description)
+		}
+	}
+	return nil
+}
+
+// UpdateInfo returns the advisories matching filter.
+// If all is false, only the advisories affecting installed packages are returned.
+func (ctx *Context) UpdateInfo(filter advisoryFilter, all bool) ([]AdvisoryInfo, error) {
+	advs, err := ctx.selectAdvisories(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := ctx.installedRPMs()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]AdvisoryInfo, 0, len(advs))
+	for _, adv := range advs {
+		info := AdvisoryInfo{
+			ID:          adv.ID,
+			Type:        adv.Type,
+			Severity:    adv.Severity,
+			Title:       adv.Title,
+			Description: adv.Description,
+			Issued:      adv.Issued,
+			Updated:     adv.Updated,
+			Repository:  adv.Repository,
+			References:  adv.References,
+			Packages:    make([]string, 0, len(adv.Packages)),
+			Installed:   make([]string, 0),
+		}
+		for _, pkg := range adv.Packages {
+			info.Packages = append(info.Packages, pkg.RPMName())
+			if rpm, ok := installed[pkg.Name()]; ok && adv.Fixes(rpm) != nil {
+				info.Installed = append(info.Installed, rpm.RPMName())
+			}
+		}
+		if !all && len(info.Installed) == 0 {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// selectAdvisories returns the advisories of all repositories matching filter
+func (ctx *Context) selectAdvisories(filter advisoryFilter) ([]*yum.Advisory, error) {
+	advs, err := ctx.yum.Advisories()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]struct{}, len(advs))
+	selected := make([]*yum.Advisory, 0, len(advs))
+	for _, adv := range advs {
+		known[adv.ID] = struct{}{}
+		if filter.match(adv) {
+			selected = append(selected, adv)
+		}
+	}
+
+	for _, id := range filter.ids {
+		if _, ok := known[id]; !ok {
+			return nil, fmt.Errorf("lbpkr: no such advisory %q", id)
+		}
+	}
+	return selected, nil
+}
+
+// filterAdvisoryPackages returns the installed packages fixed by one of the
+// advisories selected by the advisory filter of the context.
+func (ctx *Context) filterAdvisoryPackages(pkgs [][3]string) ([][3]string, error) {
+	advs, err := ctx.selectAdvisories(ctx.advisories)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([][3]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		rpm := yum.NewProvides(pkg[0], pkg[1], pkg[2], "", "EQ", nil)
+		for _, adv := range advs {
+			if fix := adv.Fixes(rpm); fix != nil {
+				ctx.msg.Debugf("%s is affected by %s (fixed in %s)\n", rpm.RPMName(), adv.ID, fix.RPMName())
+				selected = append(selected, pkg)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
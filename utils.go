@@ -3,7 +3,13 @@ package main
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
@@ -248,4 +254,56 @@ func splitRPM(rpm string) [3]string {
 	return [3]string{rpm, "", ""}
 }
 
+// humanSize returns a human readable representation of a size in bytes
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// fileChecksum returns the hex-encoded checksum of a file.
+// typ is the name of the checksum algorithm, as used in yum metadata (e.g. sha256).
+func fileChecksum(fname, typ string) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(typ) {
+	case "md5":
+		h = md5.New()
+	case "sha", "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("lbpkr: unsupported checksum type %q", typ)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// projectPattern returns the regexp matching the RPMs of a project (e.g. GAUDI or GAUDI_v25r5),
+// for all platforms.
+func projectPattern(project string) string {
+	return "^" + regexp.QuoteMeta(project) + "_"
+}
+
 // EOF
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// rpm file flags (see rpm/rpmfiles.h)
+const (
+	rpmFileConfig  = 1 << 0
+	rpmFileDoc     = 1 << 1
+	rpmFileGhost   = 1 << 6
+	rpmFileLicense = 1 << 7
+)
+
+// verifyQueryFormat is the rpm query format to extract the file attributes
+// needed to verify an installed package.
+const verifyQueryFormat = `%{FILEDIGESTALGO}\n` +
+	`[%{FILENAMES}\t%{FILESIZES}\t%{FILEMODES:octal}\t%{FILEDIGESTS}\t%{FILEFLAGS}\t%{FILEMTIMES}\t%{FILELINKTOS}\n]`
+
+// rpmFile describes a file as recorded in the RPM database
+type rpmFile struct {
+	Name   string // relocated file name
+	Size   int64
+	Mode   uint32
+	Digest string
+	Algo   int // digest algorithm (see PGPHASHALGO_xxx)
+	Flags  int
+	MTime  int64
+	LinkTo string
+}
+
+// attr returns the rpm-style attribute marker of the file
+func (f rpmFile) attr() string {
+	switch {
+	case f.Flags&rpmFileConfig != 0:
+		return "c"
+	case f.Flags&rpmFileDoc != 0:
+		return "d"
+	case f.Flags&rpmFileLicense != 0:
+		return "l"
+	}
+	return " "
+}
+
+// VerifyProblem describes an installed file differing from the package metadata
+type VerifyProblem struct {
+	Package string `json:"package"`
+	File    string `json:"file"`
+	Codes   string `json:"codes"` // rpm-style verification codes (e.g. S.5....T.)
+	Missing bool   `json:"missing"`
+	Attr    string `json:"attr,omitempty"`
+
+	rpm [3]string // name, version and release of the package
+}
+
+type verifyProblems []VerifyProblem
+
+func (p verifyProblems) writeText(w io.Writer) error {
+	pkg := ""
+	for _, v := range p {
+		if v.Package != pkg {
+			pkg = v.Package
+			_, err := fmt.Fprintf(w, "%s:\n", pkg)
+			if err != nil {
+				return err
+			}
+		}
+		codes := v.Codes
+		if v.Missing {
+			codes = "missing  "
+		}
+		attr := v.Attr
+		if attr == "" {
+			attr = " "
+		}
+		_, err := fmt.Fprintf(w, "%s  %s %s\n", codes, attr, v.File)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks the files of the installed packages whose name matches pattern
+// against the metadata recorded in the RPM database.
+func (ctx *Context) Verify(pattern string) ([]VerifyProblem, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: invalid pattern %q: %v", pattern, err)
+	}
+
+	installed, err := ctx.listInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	rpms := make(map[string][3]string, len(installed))
+	ids := make([]string, 0, len(installed))
+	for _, pkg := range installed {
+		if !re.MatchString(pkg[0]) {
+			continue
+		}
+		id := pkg[0] + "-" + pkg[1] + "-" + pkg[2]
+		rpms[id] = pkg
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	problems := make([]VerifyProblem, 0)
+	for _, id := range ids {
+		files, err := ctx.rpmFiles(id)
+		if err != nil {
+			return problems, err
+		}
+		for _, f := range files {
+			codes, missing := verifyFile(f)
+			if !missing && !strings.ContainsAny(codes, "SM5LT?") {
+				continue
+			}
+			problems = append(problems, VerifyProblem{
+				Package: id,
+				File:    f.Name,
+				Codes:   codes,
+				Missing: missing,
+				Attr:    strings.TrimSpace(f.attr()),
+				rpm:     rpms[id],
+			})
+		}
+	}
+	ctx.msg.Infof("verified %d packages: %d problems found\n", len(ids), len(problems))
+	return problems, err
+}
+
+// rpmFiles returns the list of files of an installed package, as recorded in the RPM database
+func (ctx *Context) rpmFiles(rpm string) ([]rpmFile, error) {
+	out, err := ctx.rpm(false, "-q", "--queryformat", verifyQueryFormat, rpm)
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: error querying rpm-db for %s: %v\n%v", rpm, err, string(out))
+	}
+
+	var files []rpmFile
+	scan := bufio.NewScanner(bytes.NewBuffer(out))
+	algo := 1 // PGPHASHALGO_MD5
+	if scan.Scan() {
+		if v, err := strconv.Atoi(strings.TrimSpace(scan.Text())); err == nil {
+			algo = v
+		}
+	}
+	for scan.Scan() {
+		line := scan.Text()
+		if line == "" || line == "(contains no files)" {
+			continue
+		}
+		toks := strings.Split(line, "\t")
+		if len(toks) != 7 {
+			return nil, fmt.Errorf("lbpkr: invalid rpm-db line %q", line)
+		}
+		f := rpmFile{
+			Name:   ctx.relocs.Relocate(toks[0]),
+			Digest: toks[3],
+			Algo:   algo,
+			LinkTo: toks[6],
+		}
+		f.Size, err = strconv.ParseInt(toks[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid file size in %q: %v", line, err)
+		}
+		mode, err := strconv.ParseUint(toks[2], 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid file mode in %q: %v", line, err)
+		}
+		f.Mode = uint32(mode)
+		f.Flags, err = strconv.Atoi(toks[4])
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid file flags in %q: %v", line, err)
+		}
+		f.MTime, err = strconv.ParseInt(toks[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("lbpkr: invalid file mtime in %q: %v", line, err)
+		}
+		files = append(files, f)
+	}
+	return files, scan.Err()
+}
+
+// verifyFile checks a file on disk against its metadata.
+// verifyFile returns the rpm-style verification codes (SM5DLUGTP) of the file.
+func verifyFile(f rpmFile) (string, bool) {
+	codes := []byte(".........")
+	if f.Flags&rpmFileGhost != 0 {
+		return string(codes), false
+	}
+
+	var st syscall.Stat_t
+	err := syscall.Lstat(f.Name, &st)
+	if err != nil {
+		return string(codes), true
+	}
+
+	const (
+		sizeCode   = 0
+		modeCode   = 1
+		digestCode = 2
+		linkCode   = 4
+		mtimeCode  = 7
+	)
+
+	if st.Mode != f.Mode {
+		codes[modeCode] = 'M'
+	}
+
+	switch st.Mode & syscall.S_IFMT {
+	case syscall.S_IFREG:
+		if st.Size != f.Size {
+			codes[sizeCode] = 'S'
+		}
+		if int64(st.Mtim.Sec) != f.MTime {
+			codes[mtimeCode] = 'T'
+		}
+		if f.Digest == "" {
+			break
+		}
+		digest, err := fileDigest(f.Name, f.Algo)
+		switch {
+		case err != nil:
+			codes[digestCode] = '?'
+		case digest != f.Digest:
+			codes[digestCode] = '5'
+		}
+
+	case syscall.S_IFLNK:
+		link, err := os.Readlink(f.Name)
+		switch {
+		case err != nil:
+			codes[linkCode] = '?'
+		case link != f.LinkTo:
+			codes[linkCode] = 'L'
+		}
+	}
+
+	return string(codes), false
+}
+
+// rpmDigestAlgos maps the rpm digest algorithms (PGPHASHALGO_xxx) to their names
+var rpmDigestAlgos = map[int]string{
+	1:  "md5",
+	2:  "sha1",
+	8:  "sha256",
+	9:  "sha384",
+	10: "sha512",
+}
+
+// fileDigest returns the hex-encoded digest of a file, using the rpm digest algorithm algo
+func fileDigest(fname string, algo int) (string, error) {
+	name, ok := rpmDigestAlgos[algo]
+	if !ok {
+		return "", fmt.Errorf("lbpkr: unsupported digest algorithm %d", algo)
+	}
+	return fileChecksum(fname, name)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// WhatRequires returns the packages with a requirement satisfied by the
+// package name (and version).
+// If recursive is true, the packages requiring these packages are also
+// returned, and so on.
+// If installed is true, only installed packages are considered.
+func (ctx *Context) WhatRequires(name, version string, recursive, installed bool) ([]*yum.Package, error) {
+	var err error
+
+	keep := func(pkg *yum.Package) bool { return true }
+	if installed {
+		rpms, err := ctx.listInstalledPackages()
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[[3]string]struct{}, len(rpms))
+		for _, rpm := range rpms {
+			set[rpm] = struct{}{}
+			if rpm[0] == name && version == "" {
+				version = rpm[1]
+			}
+		}
+		if version == "" {
+			return nil, fmt.Errorf("lbpkr: package %q is not installed", name)
+		}
+		keep = func(pkg *yum.Package) bool {
+			_, ok := set[[3]string{pkg.Name(), pkg.Version(), pkg.Release()}]
+			return ok
+		}
+	}
+
+	target, err := ctx.yum.FindLatestProvider(name, version, "")
+	if err != nil {
+		return nil, fmt.Errorf("lbpkr: no such package name=%q version=%q (%v)", name, version, err)
+	}
+
+	processed := map[string]struct{}{target.ID(): {}}
+	queue := []*yum.Package{target}
+	pkgs := make([]*yum.Package, 0)
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		requirers, err := ctx.yum.FindRequiring(pkg)
+		if err != nil {
+			return pkgs, err
+		}
+		for _, p := range requirers {
+			if _, dup := processed[p.ID()]; dup || !keep(p) {
+				continue
+			}
+			processed[p.ID()] = struct{}{}
+			pkgs = append(pkgs, p)
+			if recursive {
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	sort.Sort(yum.Packages(pkgs))
+	return pkgs, err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lhcb-org/lbpkr/yum"
+)
+
+// requireChains are chains of installed packages, from an explicitly
+// installed package down to a required one
+type requireChains [][]string
+
+func (p requireChains) writeText(w io.Writer) error {
+	for _, chain := range p {
+		_, err := fmt.Fprintf(w, "%s\n", strings.Join(chain, " -> "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Why returns all the chains of requirements leading from an explicitly
+// installed package (a package no other installed package requires) down to
+// the installed package name.
+// At most max chains are returned (max <= 0: no limit): the number of chains
+// grows exponentially with the number of packages sharing a requirement.
+func (ctx *Context) Why(name string, max int) ([][]string, error) {
+	installed, err := ctx.installedRPMs()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := installed[name]; !ok {
+		return nil, fmt.Errorf("lbpkr: package %q is not installed", name)
+	}
+
+	parents := ctx.installedRequirers(installed)
+	chains, truncated := findRequireChains(parents, name, max)
+	if truncated {
+		ctx.msg.Warnf("only the first %d chains are displayed (see -max)\n", max)
+	}
+	if len(chains) == 0 {
+		// only required through cyclic requirements
+		ctx.msg.Warnf("%s is only required by a cycle of packages\n", name)
+	}
+	return chains, nil
+}
+
+// findRequireChains walks the requirers graph parents up from name, and
+// returns the chains from the roots of the graph down to name.
+// At most max chains are returned (max <= 0: no limit). truncated reports
+// whether other chains were left out.
+func findRequireChains(parents map[string][]string, name string, max int) (chains [][]string, truncated bool) {
+	visiting := make(map[string]bool)
+	var walk func(path []string) bool
+	walk = func(path []string) bool {
+		cur := path[len(path)-1]
+		if len(parents[cur]) == 0 {
+			chain := make([]string, 0, len(path))
+			for i := len(path) - 1; i >= 0; i-- {
+				chain = append(chain, path[i])
+			}
+			if max > 0 && len(chains) == max {
+				truncated = true
+				return false
+			}
+			chains = append(chains, chain)
+			return true
+		}
+		visiting[cur] = true
+		defer delete(visiting, cur)
+		for _, parent := range parents[cur] {
+			if visiting[parent] {
+				// cyclic requirement
+				continue
+			}
+			next := make([]string, len(path), len(path)+1)
+			copy(next, path)
+			if !walk(append(next, parent)) {
+				return false
+			}
+		}
+		return true
+	}
+	walk([]string{name})
+	return chains, truncated
+}
+
+// installedRequirers returns, for each installed package name, the names of
+// the installed packages requiring it.
+func (ctx *Context) installedRequirers(installed map[string]yum.RPM) map[string][]string {
+	parents := make(map[string][]string, len(installed))
+	for name, rpm := range installed {
+		pkg, err := ctx.yum.FindLatestProvider(name, rpm.Version(), rpm.Release())
+		if err != nil {
+			ctx.msg.Debugf("no provider for installed package %s: %v\n", rpm.RPMName(), err)
+			continue
+		}
+		seen := make(map[string]struct{})
+		for _, req := range pkg.Requires() {
+			dep, err := ctx.yum.FindLatestMatchingRequire(req)
+			if err != nil {
+				ctx.msg.Debugf("no package matching requirement %s of %s: %v\n", req.Name(), rpm.RPMName(), err)
+				continue
+			}
+			if _, ok := installed[dep.Name()]; !ok || dep.Name() == name {
+				continue
+			}
+			if _, dup := seen[dep.Name()]; dup {
+				continue
+			}
+			seen[dep.Name()] = struct{}{}
+			parents[dep.Name()] = append(parents[dep.Name()], name)
+		}
+	}
+
+	for _, v := range parents {
+		sort.Strings(v)
+	}
+	return parents
+}
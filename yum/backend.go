@@ -42,4 +42,11 @@ type Backend interface {
 
 	// GetPackages returns all the packages known by a YUM repository
 	GetPackages() []*Package
+
+	// FindRequiring returns the packages with a requirement satisfied by pkg.
+	FindRequiring(pkg *Package) ([]*Package, error)
+
+	// SearchPackages returns the packages with words starting with each of
+	// the (lower-case) search tokens in their name, summary, description or provides.
+	SearchPackages(tokens []string) ([]*Package, error)
 }
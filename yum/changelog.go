@@ -0,0 +1,227 @@
+package yum
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangeLogEntry is an entry of the changelog of a package, as published by a
+// repository in its other (or other_db) metadata
+type ChangeLogEntry struct {
+	Author string    `json:"author"` // usually "name <email> - version-release"
+	Date   time.Time `json:"date"`
+	Text   string    `json:"text"`
+}
+
+// reChangeLogEVR matches the trailing [epoch:]version-release of a changelog author
+var reChangeLogEVR = regexp.MustCompile(`(?:^|\s)(?:(\d+):)?([^\s<>@:-]+)-([^\s<>@-]+)\s*$`)
+
+// RPM returns the [epoch:]version-release the entry refers to (from its
+// author line, with the name of pkg), or nil if the author line holds none.
+func (entry ChangeLogEntry) RPM(name string) RPM {
+	sub := reChangeLogEVR.FindStringSubmatch(entry.Author)
+	if sub == nil {
+		return nil
+	}
+	return NewProvides(name, sub[2], sub[3], sub[1], "EQ", nil)
+}
+
+// ChangeLog returns the changelog of pkg, newest entries first.
+// ChangeLog returns ErrNoMetadata if the repository of pkg does not publish changelogs.
+func (yum *Client) ChangeLog(pkg *Package) ([]ChangeLogEntry, error) {
+	repo := pkg.Repository()
+	if repo == nil {
+		return nil, fmt.Errorf("yum: package %s has no repository", pkg.ID())
+	}
+	return repo.ChangeLog(pkg)
+}
+
+// ChangeLog returns the changelog of pkg, newest entries first.
+// The other_db (or other) metadata is loaded at the first call, following the
+// order of preference of the backends of the repository.
+func (repo *Repository) ChangeLog(pkg *Package) ([]ChangeLogEntry, error) {
+	if repo.other == nil {
+		other, err := repo.loadOther()
+		if err != nil {
+			return nil, err
+		}
+		repo.other = other
+	}
+
+	entries, err := repo.other.ChangeLog(pkg)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(changelogEntries(entries))
+	return entries, nil
+}
+
+// loadOther loads the changelogs metadata of the repository
+func (repo *Repository) loadOther() (otherBackend, error) {
+	fname, isdb, err := repo.backendMetadata("other_db", "other")
+	if err != nil {
+		return nil, err
+	}
+	repo.msg.Debugf("loading changelogs of repository [%s] from [%s]...\n", repo.Name, fname)
+	if isdb {
+		return newOtherSQLite(fname)
+	}
+	return newOtherXML(fname)
+}
+
+// otherBackend gives access to the changelogs of the packages of a repository
+type otherBackend interface {
+	ChangeLog(pkg *Package) ([]ChangeLogEntry, error)
+	Close() error
+}
+
+// otherXML holds the changelogs of an other.xml file, indexed by package ID
+// and by package checksum
+type otherXML struct {
+	entries map[string][]ChangeLogEntry
+}
+
+func newOtherXML(fname string) (otherBackend, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ParseOther(f)
+	if err != nil {
+		return nil, err
+	}
+	return &otherXML{entries: entries}, nil
+}
+
+func (other *otherXML) ChangeLog(pkg *Package) ([]ChangeLogEntry, error) {
+	entries, ok := other.entries[pkg.ID()]
+	if !ok {
+		_, sum := pkg.Checksum()
+		entries = other.entries[sum]
+	}
+	return append([]ChangeLogEntry(nil), entries...), nil
+}
+
+func (other *otherXML) Close() error {
+	return nil
+}
+
+// ParseOther parses the changelogs of an other.xml file.
+// The changelogs are indexed by package ID (name-version-release) and by
+// package checksum (pkgid).
+func ParseOther(r io.Reader) (map[string][]ChangeLogEntry, error) {
+	type xmlPackage struct {
+		PkgID   string `xml:"pkgid,attr"`
+		Name    string `xml:"name,attr"`
+		Version struct {
+			Version string `xml:"ver,attr"`
+			Release string `xml:"rel,attr"`
+		} `xml:"version"`
+		ChangeLogs []struct {
+			Author string `xml:"author,attr"`
+			Date   int64  `xml:"date,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"changelog"`
+	}
+
+	entries := make(map[string][]ChangeLogEntry)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not decode other metadata: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+
+		var pkg xmlPackage
+		err = dec.DecodeElement(&pkg, &start)
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not decode other metadata: %v", err)
+		}
+
+		list := make([]ChangeLogEntry, 0, len(pkg.ChangeLogs))
+		for _, cl := range pkg.ChangeLogs {
+			list = append(list, ChangeLogEntry{
+				Author: strings.TrimSpace(cl.Author),
+				Date:   time.Unix(cl.Date, 0).UTC(),
+				Text:   strings.TrimSpace(cl.Text),
+			})
+		}
+		if pkg.PkgID != "" {
+			entries[pkg.PkgID] = list
+		}
+		entries[pkg.Name+"-"+pkg.Version.Version+"-"+pkg.Version.Release] = list
+	}
+	return entries, nil
+}
+
+// otherSQLite gives access to the changelogs of an other.sqlite DB
+type otherSQLite struct {
+	db *sql.DB
+}
+
+func newOtherSQLite(fname string) (otherBackend, error) {
+	db, err := sql.Open("sqlite3", fname)
+	if err != nil {
+		return nil, err
+	}
+	return &otherSQLite{db: db}, nil
+}
+
+func (other *otherSQLite) ChangeLog(pkg *Package) ([]ChangeLogEntry, error) {
+	_, sum := pkg.Checksum()
+	rows, err := other.db.Query(
+		`select c.author, c.date, c.changelog from changelog c, packages p
+         where c.pkgKey = p.pkgKey and p.pkgId = ?`,
+		sum,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]ChangeLogEntry, 0)
+	for rows.Next() {
+		var (
+			author []byte
+			date   int64
+			text   []byte
+		)
+		err = rows.Scan(&author, &date, &text)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ChangeLogEntry{
+			Author: strings.TrimSpace(string(author)),
+			Date:   time.Unix(date, 0).UTC(),
+			Text:   strings.TrimSpace(string(text)),
+		})
+	}
+	return entries, rows.Err()
+}
+
+func (other *otherSQLite) Close() error {
+	return other.db.Close()
+}
+
+// changelogEntries sorts changelog entries, newest first
+type changelogEntries []ChangeLogEntry
+
+func (p changelogEntries) Len() int           { return len(p) }
+func (p changelogEntries) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p changelogEntries) Less(i, j int) bool { return p[i].Date.After(p[j].Date) }
@@ -0,0 +1,172 @@
+package yum
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Group package types, as listed in comps metadata
+const (
+	MandatoryPackage   = "mandatory"
+	DefaultPackage     = "default"
+	OptionalPackage    = "optional"
+	ConditionalPackage = "conditional"
+)
+
+// Group is a package group (a curated set of packages) published by a
+// repository in its comps metadata
+type Group struct {
+	ID          string
+	Name        string
+	Description string
+	Default     bool
+	UserVisible bool
+	Packages    []GroupPackage
+	Repository  string
+}
+
+// GroupPackage is a package member of a group
+type GroupPackage struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`               // mandatory, default, optional or conditional
+	Requires string `json:"requires,omitempty"` // package triggering a conditional package
+}
+
+// PackageNames returns the names of the packages of the group with one of the given types
+func (g *Group) PackageNames(types ...string) []string {
+	names := make([]string, 0, len(g.Packages))
+	for _, pkg := range g.Packages {
+		if str_in_slice(pkg.Type, types) {
+			names = append(names, pkg.Name)
+		}
+	}
+	return names
+}
+
+// ParseComps parses the groups of a comps.xml file
+func ParseComps(r io.Reader) ([]*Group, error) {
+	type xmlText struct {
+		Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+		Value string `xml:",chardata"`
+	}
+
+	type xmlGroup struct {
+		ID          string    `xml:"id"`
+		Names       []xmlText `xml:"name"`
+		Descrs      []xmlText `xml:"description"`
+		Default     string    `xml:"default"`
+		UserVisible string    `xml:"uservisible"`
+		Packages    []struct {
+			Type     string `xml:"type,attr"`
+			Requires string `xml:"requires,attr"`
+			Name     string `xml:",chardata"`
+		} `xml:"packagelist>packagereq"`
+	}
+
+	var doc struct {
+		XMLName xml.Name   `xml:"comps"`
+		Groups  []xmlGroup `xml:"group"`
+	}
+
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not decode comps: %v", err)
+	}
+
+	// text returns the untranslated version of a comps string
+	text := func(v []xmlText) string {
+		for _, t := range v {
+			if t.Lang == "" {
+				return strings.TrimSpace(t.Value)
+			}
+		}
+		if len(v) > 0 {
+			return strings.TrimSpace(v[0].Value)
+		}
+		return ""
+	}
+
+	// boolean parses a comps boolean, with a default value
+	boolean := func(v string, def bool) bool {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		return def
+	}
+
+	groups := make([]*Group, 0, len(doc.Groups))
+	for _, g := range doc.Groups {
+		grp := &Group{
+			ID:          strings.TrimSpace(g.ID),
+			Name:        text(g.Names),
+			Description: text(g.Descrs),
+			Default:     boolean(g.Default, false),
+			UserVisible: boolean(g.UserVisible, true),
+			Packages:    make([]GroupPackage, 0, len(g.Packages)),
+		}
+		for _, pkg := range g.Packages {
+			typ := pkg.Type
+			if typ == "" {
+				typ = MandatoryPackage
+			}
+			grp.Packages = append(grp.Packages, GroupPackage{
+				Name:     strings.TrimSpace(pkg.Name),
+				Type:     typ,
+				Requires: pkg.Requires,
+			})
+		}
+		groups = append(groups, grp)
+	}
+	return groups, nil
+}
+
+// Groups returns the package groups published by all the repositories, sorted by ID
+func (yum *Client) Groups() ([]*Group, error) {
+	files, err := yum.Metadata("group_gz", "group")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([]*Group, 0)
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		f, err := os.Open(files[name])
+		if err != nil {
+			return nil, err
+		}
+		list, err := ParseComps(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("yum: invalid comps for repository [%s]: %v", name, err)
+		}
+		for _, grp := range list {
+			if _, dup := seen[grp.ID]; dup {
+				continue
+			}
+			seen[grp.ID] = struct{}{}
+			grp.Repository = name
+			groups = append(groups, grp)
+		}
+	}
+	sort.Sort(groupsByID(groups))
+	return groups, nil
+}
+
+type groupsByID []*Group
+
+func (p groupsByID) Len() int           { return len(p) }
+func (p groupsByID) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p groupsByID) Less(i, j int) bool { return p[i].ID < p[j].ID }
@@ -0,0 +1,231 @@
+package yum
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Types of the files of a package
+const (
+	RegularFile = "file"
+	Directory   = "dir"
+	GhostFile   = "ghost"
+)
+
+// PackageFile is a file of a package, as published by a repository in its
+// filelists (or filelists_db) metadata
+type PackageFile struct {
+	Name string `json:"name"` // path of the file, as packaged (not relocated)
+	Type string `json:"type"` // file, dir or ghost
+}
+
+// Files returns the files of pkg, sorted by name.
+// Files returns ErrNoMetadata if the repository of pkg does not publish file lists.
+func (yum *Client) Files(pkg *Package) ([]PackageFile, error) {
+	repo := pkg.Repository()
+	if repo == nil {
+		return nil, fmt.Errorf("yum: package %s has no repository", pkg.ID())
+	}
+	return repo.Files(pkg)
+}
+
+// Files returns the files of pkg, sorted by name.
+// The filelists_db (or filelists) metadata is loaded at the first call,
+// following the order of preference of the backends of the repository.
+func (repo *Repository) Files(pkg *Package) ([]PackageFile, error) {
+	if repo.filelists == nil {
+		fname, isdb, err := repo.backendMetadata("filelists_db", "filelists")
+		if err != nil {
+			return nil, err
+		}
+		repo.msg.Debugf("loading file lists of repository [%s] from [%s]...\n", repo.Name, fname)
+		if isdb {
+			repo.filelists, err = newFilelistsSQLite(fname)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			repo.filelists = &filelistsXML{fname: fname}
+		}
+	}
+
+	files, err := repo.filelists.Files(pkg)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(packageFiles(files))
+	return files, nil
+}
+
+// filelistsBackend gives access to the files of the packages of a repository
+type filelistsBackend interface {
+	Files(pkg *Package) ([]PackageFile, error)
+	Close() error
+}
+
+// filelistsXML gives access to the files of a filelists.xml file.
+// As file lists are large, the file is scanned at each query instead of
+// being held in memory.
+type filelistsXML struct {
+	fname string
+}
+
+func (fl *filelistsXML) Files(pkg *Package) ([]PackageFile, error) {
+	f, err := os.Open(fl.fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, sum := pkg.Checksum()
+	return ParseFileLists(f, func(pkgid, id string) bool {
+		return id == pkg.ID() || (sum != "" && pkgid == sum)
+	})
+}
+
+func (fl *filelistsXML) Close() error {
+	return nil
+}
+
+// ParseFileLists returns the files of the first package of a filelists.xml
+// file for which match returns true.
+// match is called with the checksum (pkgid) and the ID (name-version-release)
+// of each package.
+func ParseFileLists(r io.Reader, match func(pkgid, id string) bool) ([]PackageFile, error) {
+	type xmlPackage struct {
+		Version struct {
+			Version string `xml:"ver,attr"`
+			Release string `xml:"rel,attr"`
+		} `xml:"version"`
+		Files []struct {
+			Type string `xml:"type,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"file"`
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not decode filelists metadata: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+
+		var pkg xmlPackage
+		err = dec.DecodeElement(&pkg, &start)
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not decode filelists metadata: %v", err)
+		}
+
+		var pkgid, name string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "pkgid":
+				pkgid = attr.Value
+			case "name":
+				name = attr.Value
+			}
+		}
+		if !match(pkgid, name+"-"+pkg.Version.Version+"-"+pkg.Version.Release) {
+			continue
+		}
+
+		files := make([]PackageFile, 0, len(pkg.Files))
+		for _, f := range pkg.Files {
+			typ := f.Type
+			if typ == "" {
+				typ = RegularFile
+			}
+			files = append(files, PackageFile{Name: strings.TrimSpace(f.Name), Type: typ})
+		}
+		return files, nil
+	}
+}
+
+// filelistsSQLite gives access to the files of a filelists.sqlite DB
+type filelistsSQLite struct {
+	db *sql.DB
+}
+
+func newFilelistsSQLite(fname string) (filelistsBackend, error) {
+	db, err := sql.Open("sqlite3", fname)
+	if err != nil {
+		return nil, err
+	}
+	return &filelistsSQLite{db: db}, nil
+}
+
+func (fl *filelistsSQLite) Files(pkg *Package) ([]PackageFile, error) {
+	_, sum := pkg.Checksum()
+	rows, err := fl.db.Query(
+		`select f.dirname, f.filenames, f.filetypes from filelist f, packages p
+         where f.pkgKey = p.pkgKey and p.pkgId = ?`,
+		sum,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]PackageFile, 0)
+	for rows.Next() {
+		var dirname, names, types string
+		err = rows.Scan(&dirname, &names, &types)
+		if err != nil {
+			return nil, err
+		}
+		list, err := decodeFileList(dirname, names, types)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, list...)
+	}
+	return files, rows.Err()
+}
+
+func (fl *filelistsSQLite) Close() error {
+	return fl.db.Close()
+}
+
+// decodeFileList decodes a row of the filelist table of a filelists.sqlite
+// DB: the '/'-separated names of the files of a directory, and their types
+// ('f': file, 'd': directory, 'g': ghost).
+func decodeFileList(dirname, names, types string) ([]PackageFile, error) {
+	list := strings.Split(names, "/")
+	if len(list) != len(types) {
+		return nil, fmt.Errorf("yum: invalid filelist entry for [%s] (%d names, %d types)", dirname, len(list), len(types))
+	}
+
+	files := make([]PackageFile, 0, len(list))
+	for i, name := range list {
+		typ := RegularFile
+		switch types[i] {
+		case 'd':
+			typ = Directory
+		case 'g':
+			typ = GhostFile
+		}
+		files = append(files, PackageFile{
+			Name: strings.TrimSuffix(dirname, "/") + "/" + name,
+			Type: typ,
+		})
+	}
+	return files, nil
+}
+
+type packageFiles []PackageFile
+
+func (p packageFiles) Len() int           { return len(p) }
+func (p packageFiles) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p packageFiles) Less(i, j int) bool { return p[i].Name < p[j].Name }
@@ -0,0 +1,158 @@
+package yum
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoMetadata is returned when a repository does not provide the requested metadata
+var ErrNoMetadata = fmt.Errorf("yum: no such metadata")
+
+// Metadata returns the path to the local (uncompressed) copy of the first
+// metadata file of the repository matching one of types (e.g. "updateinfo",
+// or "group_gz" and "group").
+// The file is downloaded from the repository if missing or outdated.
+// Metadata returns ErrNoMetadata if the repository provides none of types.
+func (repo *Repository) Metadata(types ...string) (string, error) {
+	data, err := repo.localMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		return "", err
+	}
+
+	for _, typ := range types {
+		rmd, ok := md[typ]
+		if !ok {
+			continue
+		}
+		return repo.fetchMetadata(rmd)
+	}
+	return "", ErrNoMetadata
+}
+
+// backendMetadata returns the local copy of the metadata of the repository
+// matching its preferred backend: dbtype (e.g. "other_db") for the SQLite
+// backend, or xmltype (e.g. "other") for the XML one.
+// isdb reports whether the returned file is a SQLite DB.
+func (repo *Repository) backendMetadata(dbtype, xmltype string) (fname string, isdb bool, err error) {
+	for _, backend := range repo.Backends {
+		typ := ""
+		switch backend {
+		case "RepositorySQLiteBackend":
+			typ = dbtype
+		case "RepositoryXMLBackend":
+			typ = xmltype
+		default:
+			continue
+		}
+
+		fname, err = repo.Metadata(typ)
+		if err == ErrNoMetadata {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return fname, typ == dbtype, nil
+	}
+	return "", false, ErrNoMetadata
+}
+
+// fetchMetadata downloads and decompresses the metadata file described by md into the cache directory
+func (repo *Repository) fetchMetadata(md RepoMD) (string, error) {
+	fname := path.Base(md.Location)
+	ext := path.Ext(fname)
+	switch ext {
+	case ".gz", ".bz2":
+		fname = strings.TrimSuffix(fname, ext)
+	}
+	fname = filepath.Join(repo.CacheDir, fname)
+
+	if fi, err := os.Stat(fname); err == nil && !fi.ModTime().Before(md.Timestamp) {
+		return fname, nil
+	}
+
+	repo.msg.Debugf("downloading [%s] from repository [%s]...\n", md.Location, repo.Name)
+	r, err := getRemoteData(repo.RepoUrl + "/" + md.Location)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	switch ext {
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		src = gz
+	case ".bz2":
+		src = bzip2.NewReader(r)
+	}
+
+	tmp := fname + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	_, err = io.Copy(f, src)
+	if err != nil {
+		return "", err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Chtimes(tmp, md.Timestamp, md.Timestamp)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp, fname)
+	if err != nil {
+		return "", err
+	}
+	return fname, nil
+}
+
+// Metadata returns the local copies of the first metadata file matching one of
+// types, for each repository providing it, indexed by repository name.
+func (yum *Client) Metadata(types ...string) (map[string]string, error) {
+	files := make(map[string]string)
+	names := make([]string, 0, len(yum.repos))
+	for name := range yum.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fname, err := yum.repos[name].Metadata(types...)
+		if err == ErrNoMetadata {
+			yum.msg.Debugf("repository [%s] does not provide %v metadata\n", name, types)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not retrieve %v metadata of repository [%s]: %v", types, name, err)
+		}
+		files[name] = fname
+	}
+	return files, nil
+}
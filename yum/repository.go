@@ -30,6 +30,8 @@ type Repository struct {
 	CacheDir       string
 	Backends       []string
 	Backend        Backend
+	other          otherBackend     // changelogs, loaded on demand
+	filelists      filelistsBackend // file lists, loaded on demand
 }
 
 // NewRepository create a new Repository with name and from url.
@@ -70,6 +72,18 @@ func NewRepository(name, url, cachedir string, backends []string, setupBackend,
 
 // Close cleans up after use
 func (repo *Repository) Close() error {
+	if repo.other != nil {
+		err := repo.other.Close()
+		if err != nil {
+			repo.msg.Errorf("problem closing changelogs of repository [%s]: %v\n", repo.Name, err)
+		}
+	}
+	if repo.filelists != nil {
+		err := repo.filelists.Close()
+		if err != nil {
+			repo.msg.Errorf("problem closing file lists of repository [%s]: %v\n", repo.Name, err)
+		}
+	}
 	return repo.Backend.Close()
 }
 
@@ -88,6 +102,16 @@ func (repo *Repository) GetPackages() []*Package {
 	return repo.Backend.GetPackages()
 }
 
+// FindRequiring returns the packages with a requirement satisfied by pkg.
+func (repo *Repository) FindRequiring(pkg *Package) ([]*Package, error) {
+	return repo.Backend.FindRequiring(pkg)
+}
+
+// SearchPackages returns the packages matching all the search tokens.
+func (repo *Repository) SearchPackages(tokens []string) ([]*Package, error) {
+	return repo.Backend.SearchPackages(tokens)
+}
+
 // setupBackendFromRemote checks which backend should be used and updates the DB files.
 func (repo *Repository) setupBackendFromRemote() error {
 	repo.msg.Debugf("setupBackendFromRemote...\n")
@@ -197,7 +197,13 @@ type Package struct {
 
 	group      string
 	arch       string
+	summary    string
+	descr      string
 	location   string
+	size       int64  // size of the RPM file
+	instsize   int64  // size of the installed files
+	csumtype   string // checksum algorithm (e.g. sha256)
+	csum       string // hex-encoded checksum of the RPM file
 	requires   []*Requires
 	provides   []*Provides
 	repository *Repository
@@ -251,6 +257,16 @@ func (pkg *Package) Group() string {
 	return pkg.group
 }
 
+// Summary returns the one-line summary of the package
+func (pkg *Package) Summary() string {
+	return pkg.summary
+}
+
+// Description returns the description of the package
+func (pkg *Package) Description() string {
+	return pkg.descr
+}
+
 func (pkg *Package) Arch() string {
 	return pkg.arch
 }
@@ -259,6 +275,21 @@ func (pkg *Package) Location() string {
 	return pkg.location
 }
 
+// PackageSize returns the size (in bytes) of the RPM file
+func (pkg *Package) PackageSize() int64 {
+	return pkg.size
+}
+
+// InstalledSize returns the size (in bytes) of the files installed by the RPM
+func (pkg *Package) InstalledSize() int64 {
+	return pkg.instsize
+}
+
+// Checksum returns the checksum algorithm and the hex-encoded checksum of the RPM file
+func (pkg *Package) Checksum() (string, string) {
+	return pkg.csumtype, pkg.csum
+}
+
 func (pkg *Package) Requires() []*Requires {
 	return pkg.requires
 }
@@ -267,6 +298,30 @@ func (pkg *Package) Provides() []*Provides {
 	return pkg.provides
 }
 
+// allProvides returns the functionalities provided by pkg, including the
+// implicit provide of its own name, version and release.
+func (pkg *Package) allProvides() []*Provides {
+	for _, p := range pkg.provides {
+		if p.Name() == pkg.Name() && p.Version() == pkg.Version() {
+			return pkg.provides
+		}
+	}
+	self := NewProvides(pkg.Name(), pkg.Version(), pkg.Release(), pkg.Epoch(), "EQ", pkg)
+	return append([]*Provides{self}, pkg.provides...)
+}
+
+// requiresAny returns whether one of the requirements of pkg is satisfied by provides
+func (pkg *Package) requiresAny(provides []*Provides) bool {
+	for _, req := range pkg.requires {
+		for _, prov := range provides {
+			if req.Name() == prov.Name() && req.ProvideMatches(prov) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (pkg *Package) Repository() *Repository {
 	return pkg.repository
 }
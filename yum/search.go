@@ -0,0 +1,179 @@
+package yum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a package matching a search, with its match score
+type SearchResult struct {
+	Package *Package
+	Score   int // the higher, the better the match
+}
+
+// Search returns the packages of all repositories matching all the terms in
+// their name, summary, description or provides, best matches first.
+// Terms are case-insensitive and match the beginning of words.
+func (yum *Client) Search(terms ...string) ([]SearchResult, error) {
+	tokens := make([]string, 0, len(terms))
+	for _, term := range terms {
+		tokens = append(tokens, searchTokens(term)...)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("yum: no search term")
+	}
+
+	seen := make(map[string]struct{})
+	results := make([]SearchResult, 0)
+	for _, repo := range yum.repos {
+		pkgs, err := repo.SearchPackages(tokens)
+		if err != nil {
+			return nil, fmt.Errorf("yum: search failed (repo=%s): %v", repo.RepoUrl, err)
+		}
+		for _, pkg := range pkgs {
+			if _, dup := seen[pkg.ID()]; dup {
+				continue
+			}
+			seen[pkg.ID()] = struct{}{}
+			score := matchScore(pkg, tokens)
+			if score <= 0 {
+				continue
+			}
+			results = append(results, SearchResult{Package: pkg, Score: score})
+		}
+	}
+	sort.Sort(searchResults(results))
+	return results, nil
+}
+
+// searchTokens splits text into lower-case words of letters and digits
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// packageSearchText returns the searchable text of a package
+func packageSearchText(pkg *Package) string {
+	text := []string{pkg.Name(), pkg.Summary(), pkg.Description()}
+	for _, p := range pkg.Provides() {
+		text = append(text, p.Name())
+	}
+	return strings.Join(text, " ")
+}
+
+// matchScore returns how well pkg matches all the search tokens (0: no match)
+func matchScore(pkg *Package, tokens []string) int {
+	name := strings.ToLower(pkg.Name())
+	nameTokens := searchTokens(pkg.Name())
+	var provTokens []string
+	for _, p := range pkg.Provides() {
+		provTokens = append(provTokens, searchTokens(p.Name())...)
+	}
+	summary := searchTokens(pkg.Summary())
+	descr := searchTokens(pkg.Description())
+
+	score := 0
+	for _, tok := range tokens {
+		switch {
+		case name == tok:
+			score += 100
+		case strings.HasPrefix(name, tok):
+			score += 60
+		case hasTokenPrefix(nameTokens, tok):
+			score += 40
+		case hasTokenPrefix(provTokens, tok):
+			score += 20
+		case hasTokenPrefix(summary, tok):
+			score += 10
+		case hasTokenPrefix(descr, tok):
+			score += 5
+		default:
+			return 0
+		}
+	}
+	return score
+}
+
+// hasTokenPrefix returns whether one of tokens starts with prefix
+func hasTokenPrefix(tokens []string, prefix string) bool {
+	for _, tok := range tokens {
+		if strings.HasPrefix(tok, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchIndex is an in-memory index of packages by the words of their searchable text
+type searchIndex struct {
+	words []string              // sorted list of indexed words
+	pkgs  map[string][]*Package // packages indexed by word
+}
+
+func newSearchIndex(pkgs []*Package) *searchIndex {
+	idx := &searchIndex{pkgs: make(map[string][]*Package)}
+	for _, pkg := range pkgs {
+		seen := make(map[string]struct{})
+		for _, word := range searchTokens(packageSearchText(pkg)) {
+			if _, dup := seen[word]; dup {
+				continue
+			}
+			seen[word] = struct{}{}
+			if _, ok := idx.pkgs[word]; !ok {
+				idx.words = append(idx.words, word)
+			}
+			idx.pkgs[word] = append(idx.pkgs[word], pkg)
+		}
+	}
+	sort.Strings(idx.words)
+	return idx
+}
+
+// lookup returns the packages with a word starting with each of tokens
+func (idx *searchIndex) lookup(tokens []string) []*Package {
+	var matches map[*Package]struct{}
+	for _, tok := range tokens {
+		found := make(map[*Package]struct{})
+		i := sort.SearchStrings(idx.words, tok)
+		for ; i < len(idx.words) && strings.HasPrefix(idx.words[i], tok); i++ {
+			for _, pkg := range idx.pkgs[idx.words[i]] {
+				if matches == nil {
+					found[pkg] = struct{}{}
+					continue
+				}
+				if _, ok := matches[pkg]; ok {
+					found[pkg] = struct{}{}
+				}
+			}
+		}
+		matches = found
+		if len(matches) == 0 {
+			break
+		}
+	}
+
+	pkgs := make([]*Package, 0, len(matches))
+	for pkg := range matches {
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+type searchResults []SearchResult
+
+func (p searchResults) Len() int      { return len(p) }
+func (p searchResults) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p searchResults) Less(i, j int) bool {
+	if p[i].Score != p[j].Score {
+		return p[i].Score > p[j].Score
+	}
+	pi, pj := p[i].Package, p[j].Package
+	if pi.Name() != pj.Name() {
+		return pi.Name() < pj.Name()
+	}
+	// newest first
+	return RPMLessThan(pj, pi)
+}
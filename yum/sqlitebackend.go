@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"syscall"
 
 	"github.com/gonuts/logger"
 	_ "github.com/mattn/go-sqlite3"
@@ -23,6 +25,7 @@ type RepositorySQLiteBackend struct {
 	Primary      string
 	Repository   *Repository
 	db           *sql.DB
+	index        *sql.DB // full-text search index
 	msg          *logger.Logger
 }
 
@@ -55,6 +58,13 @@ func (repo *RepositorySQLiteBackend) Close() error {
 			repo.msg.Errorf("problem disconnecting db: %v\n", err)
 		}
 	}
+	if repo.index != nil {
+		e := repo.index.Close()
+		if e != nil {
+			repo.msg.Errorf("problem disconnecting search index: %v\n", e)
+		}
+		repo.index = nil
+	}
 	repo.msg.Debugf("removing [%s]...\n", repo.Primary)
 	if path_exists(repo.Primary) {
 		err = os.RemoveAll(repo.Primary)
@@ -226,7 +236,7 @@ func (repo *RepositorySQLiteBackend) FindLatestMatchingRequire(requirement *Requ
 
 // GetPackages returns all the packages known by a YUM repository
 func (repo *RepositorySQLiteBackend) GetPackages() []*Package {
-	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href from packages"
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, checksum_type, pkgId, summary, description from packages"
 	stmt, err := repo.db.Prepare(query)
 	if err != nil {
 		repo.msg.Errorf("db-error: %v\n", err)
@@ -276,6 +286,270 @@ func (repo *RepositorySQLiteBackend) GetPackages() []*Package {
 	return pkgs
 }
 
+// FindRequiring returns the packages with a requirement satisfied by pkg.
+func (repo *RepositorySQLiteBackend) FindRequiring(pkg *Package) ([]*Package, error) {
+	provides := pkg.allProvides()
+	names := make(map[string]struct{}, len(provides))
+	seen := make(map[int]struct{})
+	pkgs := make([]*Package, 0)
+	for _, prov := range provides {
+		if _, dup := names[prov.Name()]; dup {
+			continue
+		}
+		names[prov.Name()] = struct{}{}
+
+		candidates, err := repo.loadPackagesRequiring(prov.Name(), seen)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range candidates {
+			if p.requiresAny(provides) {
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// SearchPackages returns the packages matching all the search tokens.
+// The full-text search index is built at the first search and kept in the cache.
+// If full-text search is not available, packages are looked up by substring.
+func (repo *RepositorySQLiteBackend) SearchPackages(tokens []string) ([]*Package, error) {
+	var keys []int
+	err := repo.createSearchIndex()
+	if err == nil {
+		keys, err = repo.searchIndex(tokens)
+	}
+	if err != nil {
+		repo.msg.Debugf("full-text search unavailable (%v): falling back to substring search\n", err)
+		keys, err = repo.searchLike(tokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pkgs := make([]*Package, 0, len(keys))
+	for _, pkgkey := range keys {
+		pkg, err := repo.loadPackageByKey(pkgkey)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// searchTable is the name of the full-text search table of the index
+const searchTable = "lbpkr_search"
+
+// searchPrefix is the prefix of the files of the search index in the cache directory
+const searchPrefix = "lbpkr-search"
+
+// createSearchIndex opens the full-text search index of the DB, building it if needed.
+// The index lives in a DB of its own, next to the cached primary DB and named
+// after its checksum, so that it is rebuilt only when the metadata changes.
+// The cached primary DB is left untouched.
+// If the index cannot be stored (no checksum, read-only cache), it is built in memory.
+func (repo *RepositorySQLiteBackend) createSearchIndex() error {
+	if repo.index != nil {
+		return nil
+	}
+
+	fname, err := repo.searchIndexFile()
+	if err == nil && !path_exists(fname) {
+		err = repo.buildSearchIndex(fname)
+	}
+	if err != nil {
+		repo.msg.Debugf("could not store search index of [%s]: %v\n", repo.Primary, err)
+		return repo.openSearchIndex(":memory:", true)
+	}
+	return repo.openSearchIndex("file:"+fname+"?mode=ro", false)
+}
+
+// searchIndexFile returns the name of the search index file for the current DB
+func (repo *RepositorySQLiteBackend) searchIndexFile() (string, error) {
+	data, err := repo.Repository.localMetadata()
+	if err != nil {
+		return "", err
+	}
+	md, err := repo.Repository.checkRepoMD(data)
+	if err != nil {
+		return "", err
+	}
+	sum := strings.TrimSpace(md[repo.YumDataType()].Checksum)
+	if sum == "" || strings.ContainsAny(sum, `/\`) {
+		return "", fmt.Errorf("yum: no checksum for [%s]", repo.YumDataType())
+	}
+	return filepath.Join(repo.Repository.CacheDir, searchPrefix+"-"+sum+".sqlite"), nil
+}
+
+// openSearchIndex opens the search index DB. If fill is true, the search table is created.
+func (repo *RepositorySQLiteBackend) openSearchIndex(dsn string, fill bool) error {
+	index, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+
+	if fill {
+		// each connection to ":memory:" opens a new, empty, DB
+		index.SetMaxOpenConns(1)
+		err = repo.fillSearchIndex(index)
+		if err != nil {
+			index.Close()
+			return err
+		}
+	}
+	repo.index = index
+	return nil
+}
+
+// buildSearchIndex creates the search index file fname.
+// Concurrent builds are serialized by a lock file, and the index is only
+// moved into place once complete. Indices of former DBs are removed.
+func (repo *RepositorySQLiteBackend) buildSearchIndex(fname string) error {
+	dir := filepath.Dir(fname)
+	lock, err := os.OpenFile(filepath.Join(dir, searchPrefix+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	// another process may have built it while we were waiting for the lock
+	if path_exists(fname) {
+		return nil
+	}
+
+	repo.msg.Debugf("creating search index of [%s]...\n", repo.Primary)
+	tmp := fname + ".tmp"
+	os.Remove(tmp)
+	index, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+	err = repo.fillSearchIndex(index)
+	if e := index.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, fname)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	olds, err := filepath.Glob(filepath.Join(dir, searchPrefix+"-*.sqlite"))
+	if err != nil {
+		return err
+	}
+	for _, old := range olds {
+		if old != fname {
+			os.Remove(old)
+		}
+	}
+	return nil
+}
+
+// fillSearchIndex creates the full-text search table of index from the
+// packages of the DB
+func (repo *RepositorySQLiteBackend) fillSearchIndex(index *sql.DB) error {
+	_, err := index.Exec("create virtual table " + searchTable + " using fts4(text, tokenize=simple)")
+	if err != nil {
+		return err
+	}
+
+	rows, err := repo.db.Query(`select p.pkgkey,
+             p.name || ' ' || ifnull(p.summary, '') || ' ' || ifnull(p.description, '') || ' ' ||
+             ifnull((select group_concat(r.name, ' ') from provides r where r.pkgkey = p.pkgkey), '')
+             from packages p`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tx, err := index.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("insert into " + searchTable + "(docid, text) values (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for rows.Next() {
+		var pkgkey int
+		var text string
+		err = rows.Scan(&pkgkey, &text)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(pkgkey, text)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// searchIndex returns the keys of the packages matching all tokens, using the full-text search index
+func (repo *RepositorySQLiteBackend) searchIndex(tokens []string) ([]int, error) {
+	query := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		query = append(query, tok+"*")
+	}
+	return queryKeys(
+		repo.index,
+		"select docid from "+searchTable+" where text match ?",
+		strings.Join(query, " "),
+	)
+}
+
+// searchLike returns the keys of the packages containing all tokens
+func (repo *RepositorySQLiteBackend) searchLike(tokens []string) ([]int, error) {
+	query := "select pkgkey from packages p where 1"
+	args := make([]interface{}, 0, 4*len(tokens))
+	for _, tok := range tokens {
+		query += ` and (lower(p.name) like ? or lower(p.summary) like ? or lower(p.description) like ?
+             or p.pkgkey in (select r.pkgkey from provides r where lower(r.name) like ?))`
+		like := "%" + tok + "%"
+		args = append(args, like, like, like, like)
+	}
+	return queryKeys(repo.db, query, args...)
+}
+
+// queryKeys runs a query returning package keys
+func queryKeys(db *sql.DB, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]int, 0)
+	for rows.Next() {
+		var pkgkey int
+		err = rows.Scan(&pkgkey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pkgkey)
+	}
+	return keys, rows.Err()
+}
+
 func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Package, error) {
 	var pkg Package
 	pkg.repository = repo.Repository
@@ -287,6 +561,12 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 	var group []byte
 	var arch []byte
 	var location []byte
+	var size sql.NullInt64
+	var instsize sql.NullInt64
+	var chksumtype []byte
+	var chksum []byte
+	var summary []byte
+	var descr []byte
 	err := rows.Scan(
 		&pkgkey,
 		&name,
@@ -296,6 +576,12 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 		&group,
 		&arch,
 		&location,
+		&size,
+		&instsize,
+		&chksumtype,
+		&chksum,
+		&summary,
+		&descr,
 	)
 	if err != nil {
 		repo.msg.Errorf("scan error: %v\n", err)
@@ -309,6 +595,12 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 	pkg.group = string(group)
 	pkg.arch = string(arch)
 	pkg.location = string(location)
+	pkg.size = size.Int64
+	pkg.instsize = instsize.Int64
+	pkg.csumtype = string(chksumtype)
+	pkg.csum = string(chksum)
+	pkg.summary = string(summary)
+	pkg.descr = string(descr)
 
 	err = repo.loadRequires(pkgkey, &pkg)
 	if err != nil {
@@ -454,7 +746,7 @@ func (repo *RepositorySQLiteBackend) loadPackagesByName(name, version string) ([
 	var err error
 	pkgs := make([]*Package, 0)
 	args := []interface{}{name}
-	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href" +
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, checksum_type, pkgId, summary, description" +
 		" from packages where name = ?"
 	if version != "" {
 		query += " and version = ?"
@@ -568,7 +860,8 @@ func (repo *RepositorySQLiteBackend) loadPackagesProviding(prov *Provides) ([]*P
 		prov.Name(),
 		prov.Version(),
 	}
-	query := `select p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href
+	query := `select p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href,
+             p.size_package, p.size_installed, p.checksum_type, p.pkgId, p.summary, p.description
              from packages p, provides r
              where p.pkgkey = r.pkgkey
              and r.name = ?
@@ -615,6 +908,87 @@ func (repo *RepositorySQLiteBackend) loadPackagesProviding(prov *Provides) ([]*P
 	return pkgs, err
 }
 
+// loadPackagesRequiring loads the packages with a requirement named name.
+// packages whose key is already in seen are skipped.
+func (repo *RepositorySQLiteBackend) loadPackagesRequiring(name string, seen map[int]struct{}) ([]*Package, error) {
+	var err error
+	query := `select distinct p.pkgkey from packages p, requires r
+             where p.pkgkey = r.pkgkey
+             and r.name = ?`
+
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]int, 0)
+	for rows.Next() {
+		var pkgkey int
+		err = rows.Scan(&pkgkey)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := seen[pkgkey]; dup {
+			continue
+		}
+		seen[pkgkey] = struct{}{}
+		keys = append(keys, pkgkey)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]*Package, 0, len(keys))
+	for _, pkgkey := range keys {
+		pkg, err := repo.loadPackageByKey(pkgkey)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, err
+}
+
+// loadPackageByKey loads the package with the given key
+func (repo *RepositorySQLiteBackend) loadPackageByKey(pkgkey int) (*Package, error) {
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, checksum_type, pkgId, summary, description" +
+		" from packages where pkgkey = ?"
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(pkgkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		err = rows.Err()
+		if err == nil {
+			err = fmt.Errorf("no package with key %d", pkgkey)
+		}
+		return nil, err
+	}
+	return repo.newPackageFromScan(rows)
+}
+
 // decompress decompresses src into dst
 func (repo *RepositorySQLiteBackend) decompress(dst io.Writer, src io.Reader) error {
 	var err error
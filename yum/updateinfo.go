@@ -0,0 +1,163 @@
+package yum
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Advisory is an erratum published by a repository in its updateinfo metadata
+type Advisory struct {
+	ID          string
+	Type        string // security, bugfix, enhancement, ...
+	Severity    string // Critical, Important, Moderate, Low (security advisories)
+	Title       string
+	Description string
+	Issued      string
+	Updated     string
+	References  []Reference
+	Packages    []*Provides // packages fixing the issues of the advisory
+	Repository  string
+}
+
+// Reference is an external reference (CVE, bug report, ...) of an advisory
+type Reference struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// IsSecurity returns whether the advisory is a security advisory
+func (adv *Advisory) IsSecurity() bool {
+	return strings.ToLower(adv.Type) == "security"
+}
+
+// Fixes returns the package of the advisory fixing the (installed) RPM rpm,
+// or nil if rpm is not affected by the advisory.
+func (adv *Advisory) Fixes(rpm RPM) *Provides {
+	for _, pkg := range adv.Packages {
+		if pkg.Name() == rpm.Name() && RPMLessThan(rpm, pkg) {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// ParseUpdateInfo parses the advisories of an updateinfo.xml file
+func ParseUpdateInfo(r io.Reader) ([]*Advisory, error) {
+	type xmlPackage struct {
+		Name    string `xml:"name,attr"`
+		Version string `xml:"version,attr"`
+		Release string `xml:"release,attr"`
+		Epoch   string `xml:"epoch,attr"`
+	}
+
+	type xmlUpdate struct {
+		Type     string `xml:"type,attr"`
+		ID       string `xml:"id"`
+		Title    string `xml:"title"`
+		Severity string `xml:"severity"`
+		Issued   struct {
+			Date string `xml:"date,attr"`
+		} `xml:"issued"`
+		Updated struct {
+			Date string `xml:"date,attr"`
+		} `xml:"updated"`
+		Description string `xml:"description"`
+		References  []struct {
+			ID    string `xml:"id,attr"`
+			Type  string `xml:"type,attr"`
+			Title string `xml:"title,attr"`
+			Href  string `xml:"href,attr"`
+		} `xml:"references>reference"`
+		Packages []xmlPackage `xml:"pkglist>collection>package"`
+	}
+
+	var doc struct {
+		XMLName xml.Name    `xml:"updates"`
+		Updates []xmlUpdate `xml:"update"`
+	}
+
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not decode updateinfo: %v", err)
+	}
+
+	advs := make([]*Advisory, 0, len(doc.Updates))
+	for _, u := range doc.Updates {
+		adv := &Advisory{
+			ID:          strings.TrimSpace(u.ID),
+			Type:        u.Type,
+			Severity:    strings.TrimSpace(u.Severity),
+			Title:       strings.TrimSpace(u.Title),
+			Description: strings.TrimSpace(u.Description),
+			Issued:      u.Issued.Date,
+			Updated:     u.Updated.Date,
+			References:  make([]Reference, 0, len(u.References)),
+			Packages:    make([]*Provides, 0, len(u.Packages)),
+		}
+		for _, ref := range u.References {
+			adv.References = append(adv.References, Reference{
+				ID:    ref.ID,
+				Type:  ref.Type,
+				Title: ref.Title,
+				URL:   ref.Href,
+			})
+		}
+		for _, pkg := range u.Packages {
+			adv.Packages = append(adv.Packages,
+				NewProvides(pkg.Name, pkg.Version, pkg.Release, pkg.Epoch, "EQ", nil),
+			)
+		}
+		advs = append(advs, adv)
+	}
+	return advs, nil
+}
+
+// Advisories returns the advisories published by all the repositories, sorted by ID
+func (yum *Client) Advisories() ([]*Advisory, error) {
+	files, err := yum.Metadata("updateinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	advs := make([]*Advisory, 0)
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		f, err := os.Open(files[name])
+		if err != nil {
+			return nil, err
+		}
+		list, err := ParseUpdateInfo(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("yum: invalid updateinfo for repository [%s]: %v", name, err)
+		}
+		for _, adv := range list {
+			if _, dup := seen[adv.ID]; dup {
+				continue
+			}
+			seen[adv.ID] = struct{}{}
+			adv.Repository = name
+			advs = append(advs, adv)
+		}
+	}
+	sort.Sort(advisories(advs))
+	return advs, nil
+}
+
+type advisories []*Advisory
+
+func (p advisories) Len() int           { return len(p) }
+func (p advisories) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p advisories) Less(i, j int) bool { return p[i].ID < p[j].ID }
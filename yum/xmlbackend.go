@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/gonuts/logger"
 )
@@ -17,6 +18,8 @@ type RepositoryXMLBackend struct {
 	Name       string
 	Packages   map[string][]*Package
 	Provides   map[string][]*Provides
+	Requirers  map[string][]*Package // packages indexed by the names of their requirements
+	index      *searchIndex          // search index, built at the first search
 	DBName     string
 	Primary    string
 	Repository *Repository
@@ -29,6 +32,7 @@ func NewRepositoryXMLBackend(repo *Repository) (*RepositoryXMLBackend, error) {
 		Name:       "RepositoryXMLBackend",
 		Packages:   make(map[string][]*Package),
 		Provides:   make(map[string][]*Provides),
+		Requirers:  make(map[string][]*Package),
 		DBName:     dbname,
 		Primary:    filepath.Join(repo.CacheDir, dbname),
 		Repository: repo,
@@ -183,7 +187,13 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 		)
 		pkg.arch = xml.Arch
 		pkg.group = xml.Format.Group
+		pkg.summary = strings.TrimSpace(xml.Summary)
+		pkg.descr = strings.TrimSpace(xml.Descr)
 		pkg.location = xml.Location.Href
+		pkg.size = xml.Size.Package
+		pkg.instsize = xml.Size.Installed
+		pkg.csumtype = xml.Checksum.Type
+		pkg.csum = strings.TrimSpace(xml.Checksum.Value)
 		for _, v := range xml.Format.Provides {
 			prov := NewProvides(
 				v.Name,
@@ -210,6 +220,7 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 				v.Pre,
 			)
 			pkg.requires = append(pkg.requires, req)
+			repo.Requirers[req.Name()] = append(repo.Requirers[req.Name()], pkg)
 		}
 		pkg.repository = repo.Repository
 
@@ -313,6 +324,33 @@ func (repo *RepositoryXMLBackend) GetPackages() []*Package {
 	return pkgs
 }
 
+// FindRequiring returns the packages with a requirement satisfied by pkg.
+func (repo *RepositoryXMLBackend) FindRequiring(pkg *Package) ([]*Package, error) {
+	provides := pkg.allProvides()
+	seen := make(map[*Package]struct{})
+	pkgs := make([]*Package, 0)
+	for _, prov := range provides {
+		for _, p := range repo.Requirers[prov.Name()] {
+			if _, dup := seen[p]; dup {
+				continue
+			}
+			seen[p] = struct{}{}
+			if p.requiresAny(provides) {
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// SearchPackages returns the packages matching all the search tokens.
+func (repo *RepositoryXMLBackend) SearchPackages(tokens []string) ([]*Package, error) {
+	if repo.index == nil {
+		repo.index = newSearchIndex(repo.GetPackages())
+	}
+	return repo.index.lookup(tokens), nil
+}
+
 func init() {
 	g_backends["RepositoryXMLBackend"] = func(repo *Repository) (Backend, error) {
 		return NewRepositoryXMLBackend(repo)
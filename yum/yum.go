@@ -73,6 +73,42 @@ func New(siteroot string) (*Client, error) {
 	return newClient(siteroot, backends, checkForUpdates, manualConfig)
 }
 
+// NewWithRepositories returns a new YUM Client, rooted at siteroot, serving
+// the repositories configured under siteroot and the extra repositories repos
+// (a map of reponame/repourl).
+// The extra repositories are not added to the yum configuration.
+func NewWithRepositories(siteroot string, repos map[string]string) (*Client, error) {
+	checkForUpdates := true
+	manualConfig := true
+	backends := []string{
+		"RepositorySQLiteBackend",
+		"RepositoryXMLBackend",
+	}
+	client, err := newClient(siteroot, backends, checkForUpdates, manualConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := client.loadConfig()
+	if err != nil {
+		client.msg.Errorf("could not load yum config: %v\n", err)
+		return nil, err
+	}
+	for name, repourl := range repos {
+		if strings.HasPrefix(repourl, "/") {
+			repourl = "file://" + repourl
+		}
+		urls[name] = repourl
+	}
+
+	err = client.initRepositories(urls, checkForUpdates, backends)
+	if err != nil {
+		client.msg.Errorf("could not initialize repositories: %v\n", err)
+		return nil, err
+	}
+	return client, err
+}
+
 // Close cleans up after use
 func (yum *Client) Close() error {
 	var err error
@@ -96,6 +132,21 @@ func (yum *Client) SetLevel(lvl logger.Level) {
 	}
 }
 
+// CacheDir returns the directory holding the metadata of the repositories
+func (yum *Client) CacheDir() string {
+	return yum.lbyumcache
+}
+
+// Repositories returns the sorted list of names of the configured repositories
+func (yum *Client) Repositories() []string {
+	names := make([]string, 0, len(yum.repourls))
+	for name := range yum.repourls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // FindLatestMatchingName locates a package by name and returns the latest available version
 func (yum *Client) FindLatestMatchingName(name, version, release string) (*Package, error) {
 	var err error
@@ -162,6 +213,30 @@ func (yum *Client) FindLatestMatchingRequire(requirement *Requires) (*Package, e
 	return pkg, err
 }
 
+// FindRequiring returns the packages of all repositories with a requirement satisfied by pkg.
+func (yum *Client) FindRequiring(pkg *Package) ([]*Package, error) {
+	// do not report self-requirements
+	seen := map[string]struct{}{pkg.ID(): {}}
+	pkgs := make([]*Package, 0)
+	for _, repo := range yum.repos {
+		found, err := repo.FindRequiring(pkg)
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not find packages requiring %s (repo=%s): %v",
+				pkg.ID(), repo.RepoUrl, err,
+			)
+		}
+		for _, p := range found {
+			if _, dup := seen[p.ID()]; dup {
+				continue
+			}
+			seen[p.ID()] = struct{}{}
+			pkgs = append(pkgs, p)
+		}
+	}
+	sort.Sort(Packages(pkgs))
+	return pkgs, nil
+}
+
 // FindLatestProvider returns the requested package (found by "provides") or an error.
 func (yum *Client) FindLatestProvider(name, version, release string) (*Package, error) {
 	req := NewRequires(name, version, release, "", "EQ", "")
@@ -171,17 +246,24 @@ func (yum *Client) FindLatestProvider(name, version, release string) (*Package,
 
 // ListPackages lists all packages satisfying pattern (a regexp)
 func (yum *Client) ListPackages(name, version, release string) ([]*Package, error) {
-	var err error
-	re_name := regexp.MustCompile(name)
-	re_vers := regexp.MustCompile(version)
-	re_rel := regexp.MustCompile(release)
+	re_name, err := regexp.Compile(name)
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid name pattern %q: %v", name, err)
+	}
+	re_vers, err := regexp.Compile(version)
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid version pattern %q: %v", version, err)
+	}
+	re_rel, err := regexp.Compile(release)
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid release pattern %q: %v", release, err)
+	}
 	pkgs := make([]*Package, 0)
 	for _, repo := range yum.repos {
 		for _, pkg := range repo.GetPackages() {
 			if re_name.MatchString(pkg.Name()) &&
 				re_vers.MatchString(pkg.Version()) &&
-				// FIXME: sprintf is ugly
-				re_rel.MatchString(fmt.Sprintf("%d", pkg.Release())) {
+				re_rel.MatchString(pkg.Release()) {
 				pkgs = append(pkgs, pkg)
 			}
 		}
@@ -1,6 +1,10 @@
 package yum
 
 import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
@@ -468,6 +472,32 @@ func TestLoadConfig(t *testing.T) {
 			t.Fatalf("expected ROOT release=%q. got=%q (siteroot=%q)\n", exp, pkg.Release(), siteroot)
 		}
 
+		if pkg.PackageSize() <= 0 || pkg.InstalledSize() <= 0 {
+			t.Fatalf("expected ROOT sizes to be loaded. got package=%d installed=%d (siteroot=%q)\n",
+				pkg.PackageSize(), pkg.InstalledSize(), siteroot,
+			)
+		}
+
+		if typ, sum := pkg.Checksum(); typ != "sha256" || len(sum) != 64 {
+			t.Fatalf("expected ROOT sha256 checksum. got type=%q checksum=%q (siteroot=%q)\n", typ, sum, siteroot)
+		}
+
+		requirers, err := yum.FindRequiring(pkg)
+		if err != nil {
+			t.Fatalf("could not find packages requiring ROOT: %v (siteroot=%q)\n", err, siteroot)
+		}
+		if len(requirers) != 1 || requirers[0].ID() != "LCGCMT_62b_x86_64_slc5_gcc46_opt-1.0.0-1" {
+			t.Fatalf("expected ROOT to be required by LCGCMT. got=%v (siteroot=%q)\n", requirers, siteroot)
+		}
+
+		results, err := yum.Search("root", "GCC46")
+		if err != nil {
+			t.Fatalf("could not search ROOT packages: %v (siteroot=%q)\n", err, siteroot)
+		}
+		if len(results) == 0 || results[0].Package.Name() != "ROOT_5.32.02_x86_64_slc5_gcc46_opt" {
+			t.Fatalf("expected ROOT to be the best match. got=%v (siteroot=%q)\n", results, siteroot)
+		}
+
 		req := NewRequires(
 			"BRUNEL_v43r1p1_x86_64_slc5_gcc43_opt",
 			"1.0.0",
@@ -591,3 +621,453 @@ func TestLoadConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUpdateInfo(t *testing.T) {
+	f, err := os.Open("testdata/updateinfo.xml")
+	if err != nil {
+		t.Fatalf("could not open updateinfo: %v\n", err)
+	}
+	defer f.Close()
+
+	advs, err := ParseUpdateInfo(f)
+	if err != nil {
+		t.Fatalf("could not parse updateinfo: %v\n", err)
+	}
+
+	if len(advs) != 2 {
+		t.Fatalf("expected 2 advisories. got=%d\n", len(advs))
+	}
+
+	sec := advs[0]
+	if sec.ID != "LHCBSA-2015:0001" || !sec.IsSecurity() || sec.Severity != "Important" {
+		t.Fatalf("invalid security advisory: %#v\n", sec)
+	}
+	if len(sec.References) != 1 || sec.References[0].ID != "CVE-2015-0001" || sec.References[0].Type != "cve" {
+		t.Fatalf("invalid references: %#v\n", sec.References)
+	}
+	if sec.Issued != "2015-01-12 00:00:00" {
+		t.Fatalf("invalid issue date: %q\n", sec.Issued)
+	}
+
+	bug := advs[1]
+	if bug.IsSecurity() || len(bug.Packages) != 2 || len(bug.References) != 0 {
+		t.Fatalf("invalid bugfix advisory: %#v\n", bug)
+	}
+
+	for _, table := range []struct {
+		rpm   RPM
+		fixed string
+	}{
+		{NewProvides("TestPackage", "1.0.0", "1", "0", "EQ", nil), "TestPackage-1.0.0-2"},
+		{NewProvides("TestPackage", "1.0.0", "2", "0", "EQ", nil), ""},
+		{NewProvides("TestPackage", "1.1.0", "1", "0", "EQ", nil), ""},
+		{NewProvides("TestPackageB", "1.0.0", "1", "0", "EQ", nil), ""},
+	} {
+		fixed := ""
+		if pkg := sec.Fixes(table.rpm); pkg != nil {
+			fixed = pkg.RPMName()
+		}
+		if fixed != table.fixed {
+			t.Errorf("%s: expected fix %q. got=%q\n", table.rpm.ID(), table.fixed, fixed)
+		}
+	}
+}
+
+func TestParseComps(t *testing.T) {
+	f, err := os.Open("testdata/comps.xml")
+	if err != nil {
+		t.Fatalf("could not open comps: %v\n", err)
+	}
+	defer f.Close()
+
+	groups, err := ParseComps(f)
+	if err != nil {
+		t.Fatalf("could not parse comps: %v\n", err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups. got=%d\n", len(groups))
+	}
+
+	grp := groups[0]
+	if grp.ID != "lcg-externals" || grp.Name != "LCG externals" || !grp.Default || !grp.UserVisible {
+		t.Fatalf("invalid group: %#v\n", grp)
+	}
+
+	for _, table := range []struct {
+		types []string
+		names []string
+	}{
+		{[]string{MandatoryPackage}, []string{"TestPackage"}},
+		{[]string{MandatoryPackage, DefaultPackage}, []string{"TestPackage", "TestPackageB"}},
+		{[]string{OptionalPackage}, []string{"TestPackageC"}},
+		{[]string{ConditionalPackage}, []string{"TestPackageD"}},
+	} {
+		names := grp.PackageNames(table.types...)
+		if !reflect.DeepEqual(names, table.names) {
+			t.Errorf("types=%v: expected %v. got=%v\n", table.types, table.names, names)
+		}
+	}
+
+	tools := groups[1]
+	if tools.Default || tools.UserVisible {
+		t.Fatalf("invalid group flags: %#v\n", tools)
+	}
+	if names := tools.PackageNames(MandatoryPackage); !reflect.DeepEqual(names, []string{"TestPackageB"}) {
+		t.Fatalf("expected packagereq without type to be mandatory. got=%v\n", names)
+	}
+}
+
+func TestFindRequiring(t *testing.T) {
+	yum, err := getTestClient(t)
+	if err != nil {
+		t.Fatalf("could not create test repo: %v\n", err)
+	}
+	defer yum.Close()
+
+	for _, table := range []struct {
+		name    string
+		version string
+		want    []string
+	}{
+		{"TestPackage", "1.0.0", []string{}},
+		{"TestPackage", "1.2.5", []string{"TP2-1.2.5-2", "TP3-1.18.22-2"}},
+		{"TestPackage", "1.3.7", []string{"TP2-1.2.5-2"}},
+		{"TCyclicDep", "1.0.0", []string{"TCyclicDep2-1.0.0-1"}},
+	} {
+		// the test packages all provide (and require) /bin/sh:
+		// only consider the implicit provide of the package name.
+		pkg := NewPackage(table.name, table.version, "1", "0")
+		pkgs, err := yum.FindRequiring(pkg)
+		if err != nil {
+			t.Fatalf("could not find packages requiring %s: %v\n", pkg.ID(), err)
+		}
+
+		got := make([]string, 0, len(pkgs))
+		for _, p := range pkgs {
+			got = append(got, p.ID())
+		}
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("%s: expected %v. got=%v\n", pkg.ID(), table.want, got)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	yum, err := getTestClient(t)
+	if err != nil {
+		t.Fatalf("could not create test repo: %v\n", err)
+	}
+	defer yum.Close()
+
+	for _, table := range []struct {
+		terms []string
+		want  []string
+	}{
+		{
+			terms: []string{"tp"},
+			want: []string{
+				"TP2-1.2.5-2", "TP2-1.2.5-1", "TP3-1.18.22-2",
+				"TPRel-4.2.8-1", "TPRel-4.2.7-2", "TPRel-4.2.7-1",
+			},
+		},
+		{
+			terms: []string{"TESTPACKAGE"},
+			want:  []string{"TestPackage-1.3.7-1", "TestPackage-1.2.5-1", "TestPackage-1.0.0-1"},
+		},
+		{
+			terms: []string{"tcyclic", "dep3"},
+			want:  []string{},
+		},
+		{
+			terms: []string{"tcyclicdep3"},
+			want:  []string{"TCyclicDep3-1.0.0-1"},
+		},
+		{
+			terms: []string{"no-such-package"},
+			want:  []string{},
+		},
+	} {
+		results, err := yum.Search(table.terms...)
+		if err != nil {
+			t.Fatalf("could not search %v: %v\n", table.terms, err)
+		}
+		got := make([]string, 0, len(results))
+		for _, r := range results {
+			got = append(got, r.Package.ID())
+		}
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("search %v: expected %v. got=%v\n", table.terms, table.want, got)
+		}
+	}
+
+	if _, err := yum.Search(" - "); err == nil {
+		t.Fatalf("expected an error for an empty search\n")
+	}
+}
+
+func TestSearchSQLite(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-yum-")
+	if err != nil {
+		t.Fatalf("could not create tempdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	primary := filepath.Join(tmpdir, "primary.sqlite")
+	db, err := sql.Open("sqlite3", primary)
+	if err != nil {
+		t.Fatalf("could not create DB: %v\n", err)
+	}
+	for _, stmt := range []string{
+		`create table packages (pkgKey integer primary key, pkgId text, name text, arch text,
+             version text, epoch text, release text, summary text, description text,
+             rpm_group text, size_package integer, size_installed integer,
+             location_href text, checksum_type text)`,
+		"create table provides (name text, flags text, epoch text, version text, release text, pkgKey integer)",
+		"create table requires (name text, flags text, epoch text, version text, release text, pkgKey integer, pre boolean)",
+		`insert into packages values
+             (1, 'id1', 'ROOT', 'noarch', '5.34', '0', '1', 'data analysis framework', '', 'LHCb', 1, 1, 'ROOT-5.34-1.rpm', 'sha256'),
+             (2, 'id2', 'GAUDI', 'noarch', 'v25r0', '0', '1', 'event processing framework', '', 'LHCb', 1, 1, 'GAUDI-v25r0-1.rpm', 'sha256'),
+             (3, 'id3', 'LCGCMT', 'noarch', '66', '0', '1', 'externals', '', 'LHCb', 1, 1, 'LCGCMT-66-1.rpm', 'sha256')`,
+		"insert into provides values ('libCore.so', '', '', '', '', 1)",
+	} {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			t.Fatalf("could not fill DB: %v\n", err)
+		}
+	}
+	db.Close()
+
+	// the cached DB is read-only: the index is stored next to it
+	err = os.Chmod(primary, 0444)
+	if err != nil {
+		t.Fatalf("could not chmod DB: %v\n", err)
+	}
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", tmpdir,
+		[]string{"RepositorySQLiteBackend"},
+		false, // setupBackend
+		false, // checkForUpdates
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	writeRepoMD := func(sum string) {
+		data := `<repomd><data type="primary_db"><checksum type="sha256">` + sum + `</checksum></data></repomd>`
+		err := ioutil.WriteFile(repo.LocalRepoMdXml, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("could not write repomd.xml: %v\n", err)
+		}
+	}
+	indexFile := func(sum string) string {
+		return filepath.Join(tmpdir, searchPrefix+"-"+sum+".sqlite")
+	}
+
+	search := func() {
+		backend, err := NewRepositorySQLiteBackend(repo)
+		if err != nil {
+			t.Fatalf("could not create backend: %v\n", err)
+		}
+		backend.db, err = sql.Open("sqlite3", "file:"+primary+"?mode=ro")
+		if err != nil {
+			t.Fatalf("could not open DB: %v\n", err)
+		}
+		defer backend.db.Close()
+
+		for _, table := range []struct {
+			tokens []string
+			want   []string
+		}{
+			{tokens: []string{"framework"}, want: []string{"GAUDI", "ROOT"}},
+			{tokens: []string{"frame", "event"}, want: []string{"GAUDI"}},
+			{tokens: []string{"libcore"}, want: []string{"ROOT"}},
+			{tokens: []string{"nothing"}, want: []string{}},
+		} {
+			pkgs, err := backend.SearchPackages(table.tokens)
+			if err != nil {
+				t.Fatalf("could not search %v: %v\n", table.tokens, err)
+			}
+			if backend.index == nil {
+				t.Fatalf("search %v: no full-text search index\n", table.tokens)
+			}
+			got := make([]string, 0, len(pkgs))
+			for _, pkg := range pkgs {
+				got = append(got, pkg.Name())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, table.want) {
+				t.Errorf("search %v: expected %v. got=%v\n", table.tokens, table.want, got)
+			}
+		}
+
+		// the cached DB is left untouched
+		var n int
+		err = backend.db.QueryRow("select count(*) from sqlite_master where name = ?", searchTable).Scan(&n)
+		if err != nil {
+			t.Fatalf("could not query DB: %v\n", err)
+		}
+		if n != 0 {
+			t.Fatalf("search index created in the cached DB\n")
+		}
+		backend.index.Close()
+	}
+
+	// the index is built at the first search
+	writeRepoMD("abc123")
+	search()
+	fi1, err := os.Stat(indexFile("abc123"))
+	if err != nil {
+		t.Fatalf("search index not stored: %v\n", err)
+	}
+
+	// and reused afterwards
+	search()
+	fi2, err := os.Stat(indexFile("abc123"))
+	if err != nil {
+		t.Fatalf("search index removed: %v\n", err)
+	}
+	if !os.SameFile(fi1, fi2) {
+		t.Fatalf("search index rebuilt for unchanged metadata\n")
+	}
+
+	// new metadata: the index is rebuilt and the former one removed
+	writeRepoMD("def456")
+	search()
+	if !path_exists(indexFile("def456")) {
+		t.Fatalf("search index not rebuilt for new metadata\n")
+	}
+	if path_exists(indexFile("abc123")) {
+		t.Fatalf("former search index not removed\n")
+	}
+
+	// no checksum: the index is kept in memory
+	os.Remove(repo.LocalRepoMdXml)
+	os.Remove(indexFile("def456"))
+	search()
+	olds, err := filepath.Glob(filepath.Join(tmpdir, searchPrefix+"-*.sqlite"))
+	if err != nil {
+		t.Fatalf("could not glob: %v\n", err)
+	}
+	if len(olds) != 0 {
+		t.Fatalf("search index stored without checksum: %v\n", olds)
+	}
+}
+
+func TestListPackagesInvalidPattern(t *testing.T) {
+	yum, err := getTestClient(t)
+	if err != nil {
+		t.Fatalf("could not create test repo: %v\n", err)
+	}
+	defer yum.Close()
+
+	for _, pattern := range [][3]string{
+		{"TP(", "", ""},
+		{"TP", "[1", ""},
+		{"TP", "", "*"},
+	} {
+		_, err := yum.ListPackages(pattern[0], pattern[1], pattern[2])
+		if err == nil {
+			t.Errorf("expected an error for patterns %q\n", pattern)
+		}
+	}
+}
+
+func TestParseOther(t *testing.T) {
+	f, err := os.Open("testdata/other.xml")
+	if err != nil {
+		t.Fatalf("could not open other.xml: %v\n", err)
+	}
+	defer f.Close()
+
+	changelogs, err := ParseOther(f)
+	if err != nil {
+		t.Fatalf("could not parse other.xml: %v\n", err)
+	}
+
+	entries := changelogs["TestPackage-1.2.5-1"]
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 changelog entries. got=%d\n", len(entries))
+	}
+	if !reflect.DeepEqual(entries, changelogs["0a1b2c3d4e5f60718293a4b5c6d7e8f901234567"]) {
+		t.Fatalf("expected changelogs to be indexed by pkgid\n")
+	}
+
+	entry := entries[1]
+	if entry.Author != "Jane Doe <jane.doe@cern.ch> - 1.2.5-1" {
+		t.Fatalf("invalid author: %q\n", entry.Author)
+	}
+	if entry.Text != "- fixed a buffer overflow\n- updated the documentation" {
+		t.Fatalf("invalid text: %q\n", entry.Text)
+	}
+	if entry.Date.Year() != 2015 {
+		t.Fatalf("invalid date: %v\n", entry.Date)
+	}
+	if rpm := entry.RPM("TestPackage"); rpm == nil || rpm.Version() != "1.2.5" || rpm.Release() != "1" {
+		t.Fatalf("invalid changelog version: %v\n", rpm)
+	}
+
+	if rpm := changelogs["TP2-1.2.5-2"][0].RPM("TP2"); rpm != nil {
+		t.Fatalf("expected no version for author without version. got=%v\n", rpm)
+	}
+}
+
+func TestParseFileLists(t *testing.T) {
+	for _, table := range []struct {
+		pkgid string
+		id    string
+		want  []PackageFile
+	}{
+		{
+			id: "TestPackage-1.2.5-1",
+			want: []PackageFile{
+				{"/opt/LHCbSoft/TestPackage", Directory},
+				{"/opt/LHCbSoft/TestPackage/bin", Directory},
+				{"/opt/LHCbSoft/TestPackage/bin/test", RegularFile},
+				{"/opt/LHCbSoft/TestPackage/README", RegularFile},
+			},
+		},
+		{
+			pkgid: "1a1b2c3d4e5f60718293a4b5c6d7e8f901234567",
+			want: []PackageFile{
+				{"/opt/lcg/TP2/lib/libTP2.so", RegularFile},
+				{"/opt/lcg/TP2/TP2.log", GhostFile},
+			},
+		},
+		{
+			id:   "TP3-1.18.22-2",
+			want: nil,
+		},
+	} {
+		f, err := os.Open("testdata/filelists.xml")
+		if err != nil {
+			t.Fatalf("could not open filelists.xml: %v\n", err)
+		}
+		files, err := ParseFileLists(f, func(pkgid, id string) bool {
+			return id == table.id || pkgid == table.pkgid
+		})
+		f.Close()
+		if err != nil {
+			t.Fatalf("could not parse filelists.xml: %v\n", err)
+		}
+		if !reflect.DeepEqual(files, table.want) {
+			t.Errorf("%s%s: expected %v. got=%v\n", table.id, table.pkgid, table.want, files)
+		}
+	}
+
+	files, err := decodeFileList("/opt/lcg/TP2", "lib/TP2.log/libTP2.so", "dgf")
+	if err != nil {
+		t.Fatalf("could not decode filelist: %v\n", err)
+	}
+	want := []PackageFile{
+		{"/opt/lcg/TP2/lib", Directory},
+		{"/opt/lcg/TP2/TP2.log", GhostFile},
+		{"/opt/lcg/TP2/libTP2.so", RegularFile},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("expected %v. got=%v\n", want, files)
+	}
+
+	if _, err := decodeFileList("/opt/lcg/TP2", "lib/libTP2.so", "d"); err == nil {
+		t.Fatalf("expected an error for mismatched names and types\n")
+	}
+}